@@ -0,0 +1,641 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// showAgencyHandler shows the public page of an agency with its agents and the published listings of
+// all of them.
+func (app *application) showAgencyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-updated_at"
+	input.Filters.SortSafelist = []string{"-updated_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	agency, err := app.agencyWithAgents(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	userIDs := make([]int64, 0, len(agency.Agents))
+	for _, agent := range agency.Agents {
+		userIDs = append(userIDs, agent.UserID)
+	}
+
+	properties, metadata, err := app.models.Properties.GetPublishedForUsers(userIDs, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"agency": agency, "properties": properties, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createAgencyHandler creates an agency with the authenticated user as its admin.
+func (app *application) createAgencyHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		LogoURL     string `json:"logo_url"`
+		Phone       string `json:"phone"`
+		Email       string `json:"email"`
+		Website     string `json:"website"`
+		City        string `json:"city"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	agency := &data.Agency{
+		Name:        input.Name,
+		Description: input.Description,
+		LogoURL:     input.LogoURL,
+		Phone:       input.Phone,
+		Email:       input.Email,
+		Website:     input.Website,
+		City:        input.City,
+	}
+
+	v := validator.New()
+	if data.ValidateAgency(v, agency); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Agencies.Insert(agency, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrAlreadyInAgency):
+			v.AddError("agency", "you already belong to an agency")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/agencies/%d", agency.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"agency": agency}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showAccountAgencyHandler shows the agency the authenticated user belongs to with its agents and, for
+// its admins, its pending invitations.
+func (app *application) showAccountAgencyHandler(w http.ResponseWriter, r *http.Request) {
+	profile, ok := app.agencyMember(w, r)
+	if !ok {
+		return
+	}
+
+	agency, err := app.agencyWithAgents(*profile.AgencyID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelop{"agency": agency, "role": profile.AgencyRole}
+
+	if profile.IsAgencyAdmin() {
+		invitations, err := app.models.Agencies.GetPendingInvitations(agency.ID, "")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		response["invitations"] = invitations
+	}
+
+	err = app.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateAgencyHandler updates the details of the agency administered by the authenticated user.
+func (app *application) updateAgencyHandler(w http.ResponseWriter, r *http.Request) {
+	profile, ok := app.agencyAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	agency, err := app.models.Agencies.Get(*profile.AgencyID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		LogoURL     *string `json:"logo_url"`
+		Phone       *string `json:"phone"`
+		Email       *string `json:"email"`
+		Website     *string `json:"website"`
+		City        *string `json:"city"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		agency.Name = *input.Name
+	}
+	if input.Description != nil {
+		agency.Description = *input.Description
+	}
+	if input.LogoURL != nil {
+		agency.LogoURL = *input.LogoURL
+	}
+	if input.Phone != nil {
+		agency.Phone = *input.Phone
+	}
+	if input.Email != nil {
+		agency.Email = *input.Email
+	}
+	if input.Website != nil {
+		agency.Website = *input.Website
+	}
+	if input.City != nil {
+		agency.City = *input.City
+	}
+
+	v := validator.New()
+	if data.ValidateAgency(v, agency); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Agencies.Update(agency)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"agency": agency}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createAgencyInvitationHandler invites an email address to join the agency administered by the
+// authenticated user as an agent. The invitee is emailed, and notified on their event stream if they
+// already have an account.
+func (app *application) createAgencyInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	profile, ok := app.agencyAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	invitation := &data.AgencyInvitation{
+		AgencyID:   *profile.AgencyID,
+		AgencyName: profile.AgencyName,
+		Email:      input.Email,
+		InvitedBy:  profile.UserID,
+	}
+
+	v := validator.New()
+	if data.ValidateEmail(v, invitation.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Agencies.InsertInvitation(invitation)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateInvitation):
+			v.AddError("email", "has already been invited")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.background(func() {
+		invitee, err := app.models.Users.GetByEmail(invitation.Email)
+		if err == nil {
+			app.publish(invitee.ID, "agency.invitation", invitation)
+		} else if !errors.Is(err, data.ErrRecordNotFound) {
+			app.logger.Println(err)
+		}
+
+		templateData := map[string]interface{}{
+			"InviterName": profile.Name,
+			"Invitation":  invitation,
+		}
+
+		err = app.mailer.Send(invitation.Email, "agency_invitation.tmpl", templateData)
+		if err != nil {
+			app.logger.Println(err)
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listAgencyInvitationsHandler lists the pending agency invitations sent to the authenticated user's
+// email address.
+func (app *application) listAgencyInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	invitations, err := app.models.Agencies.GetPendingInvitations(0, app.contextGetUser(r).Email)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"invitations": invitations}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// acceptAgencyInvitationHandler accepts an invitation sent to the authenticated user, making them an
+// agent of the agency. The token emailed with the invitation must be sent, proving that the user received
+// it. The user who sent the invitation is notified.
+func (app *application) acceptAgencyInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	invitation, ok := app.inviteeInvitation(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.Token); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if !invitation.TokenMatches(input.Token) {
+		v.AddError("token", "invalid invitation token")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Agencies.AcceptInvitation(invitation, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrAlreadyInAgency):
+			v.AddError("agency", "you already belong to an agency")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.publish(invitation.InvitedBy, "agency.invitation_accepted", envelop{"invitation": invitation, "agent_id": user.ID})
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// declineAgencyInvitationHandler declines an invitation sent to the authenticated user.
+func (app *application) declineAgencyInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	invitation, ok := app.inviteeInvitation(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Agencies.CloseInvitation(invitation, data.InvitationDeclined)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.publish(invitation.InvitedBy, "agency.invitation_declined", invitation)
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revokeAgencyInvitationHandler revokes a pending invitation to the agency administered by the
+// authenticated user.
+func (app *application) revokeAgencyInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	profile, ok := app.agencyAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	invitation, err := app.models.Agencies.GetInvitation(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if invitation.AgencyID != *profile.AgencyID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	err = app.models.Agencies.CloseInvitation(invitation, data.InvitationRevoked)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// removeAgencyMemberHandler removes an agent from the agency administered by the authenticated user, or
+// lets an agent leave their agency by removing themselves. Admins cannot be removed, and the listings of
+// the agent stay with them.
+func (app *application) removeAgencyMemberHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	profile, ok := app.agencyMember(w, r)
+	if !ok {
+		return
+	}
+
+	if id != profile.UserID && !profile.IsAgencyAdmin() {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	err = app.models.Agencies.RemoveMember(*profile.AgencyID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if id != profile.UserID {
+		app.publish(id, "agency.member_removed", envelop{"agency_id": *profile.AgencyID, "agency_name": profile.AgencyName})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "agent successfully removed from agency"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// reassignAgencyListingHandler moves a listing of a member of the agency administered by the authenticated
+// user to another member. Both agents are notified.
+func (app *application) reassignAgencyListingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	profile, ok := app.agencyAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		AgentID int64 `json:"agent_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	property, err := app.models.Properties.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	owner, err := app.models.Agents.GetProfile(property.UserID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if owner == nil || owner.AgencyID == nil || *owner.AgencyID != *profile.AgencyID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	agent, err := app.models.Agents.GetProfile(input.AgentID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(agent != nil && agent.AgencyID != nil && *agent.AgencyID == *profile.AgencyID, "agent_id", "must be a member of your agency")
+	v.Check(input.AgentID != property.UserID, "agent_id", "must not be the current agent")
+	if v.Valid() && property.Status == data.StatusPublished && app.requiresVerification(property.City) {
+		v.Check(agent.Verified, "agent_id", fmt.Sprintf("must have a verified licence to take over listings in %s", property.City))
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	previousID := property.UserID
+
+	err = app.models.Agencies.ReassignListing(property, *profile.AgencyID, agent.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	property.Agent = agent
+	property.AgentVerified = agent.Verified
+
+	app.publish(previousID, "property.reassigned", property)
+	app.publish(agent.UserID, "property.reassigned", property)
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"property": property}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// agencyWithAgents fetches an agency together with its agents.
+func (app *application) agencyWithAgents(id int64) (*data.Agency, error) {
+	agency, err := app.models.Agencies.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	agency.Agents, err = app.models.Agents.GetAllForAgency(agency.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return agency, nil
+}
+
+// agencyMember fetches the agent profile of the authenticated user, sending a 404 Not Found response and
+// returning false if they do not belong to an agency.
+func (app *application) agencyMember(w http.ResponseWriter, r *http.Request) (*data.AgentProfile, bool) {
+	profile, err := app.models.Agents.GetProfile(app.contextGetUser(r).ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	if profile == nil || profile.AgencyID == nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return profile, true
+}
+
+// agencyAdmin fetches the agent profile of the authenticated user like agencyMember, additionally sending
+// a 403 Forbidden response and returning false if they are not an admin of their agency.
+func (app *application) agencyAdmin(w http.ResponseWriter, r *http.Request) (*data.AgentProfile, bool) {
+	profile, ok := app.agencyMember(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !profile.IsAgencyAdmin() {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return profile, true
+}
+
+// inviteeInvitation fetches the agency invitation identified by the id parameter, sending a 404 Not Found
+// response and returning false if there is none or it was not sent to the authenticated user's email address.
+func (app *application) inviteeInvitation(w http.ResponseWriter, r *http.Request) (*data.AgencyInvitation, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	invitation, err := app.models.Agencies.GetInvitation(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !strings.EqualFold(invitation.Email, app.contextGetUser(r).Email) {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return invitation, true
+}
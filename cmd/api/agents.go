@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// showAgentHandler shows the public profile of an agent with their published listings.
+func (app *application) showAgentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-updated_at"
+	input.Filters.SortSafelist = []string{"-updated_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	profile, err := app.models.Agents.GetProfile(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	properties, metadata, err := app.models.Properties.GetPublishedForUsers([]int64{profile.UserID}, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"agent": profile, "properties": properties, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showAgentProfileHandler shows the authenticated user's agent profile. Users who have not set up a
+// profile get an empty one with version 0.
+func (app *application) showAgentProfileHandler(w http.ResponseWriter, r *http.Request) {
+	profile, err := app.agentProfile(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"agent": profile}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateAgentProfileHandler creates or replaces the authenticated user's agent profile. Sending the version
+// of the current profile guards against overwriting changes made in the meantime.
+func (app *application) updateAgentProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Bio           string   `json:"bio"`
+		PhotoURL      string   `json:"photo_url"`
+		LicenceNumber string   `json:"licence_number"`
+		Phone         string   `json:"phone"`
+		ServiceAreas  []string `json:"service_areas"`
+		Version       int32    `json:"version"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	profile, err := app.agentProfile(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	profile.Bio = input.Bio
+	profile.PhotoURL = input.PhotoURL
+	profile.LicenceNumber = input.LicenceNumber
+	profile.Phone = input.Phone
+	profile.ServiceAreas = input.ServiceAreas
+	profile.Version = input.Version
+
+	v := validator.New()
+	if data.ValidateAgentProfile(v, profile); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Agents.SetProfile(profile)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"agent": profile}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// agentProfile fetches the agent profile of a user, returning an empty profile if they have none.
+func (app *application) agentProfile(user *data.User) (*data.AgentProfile, error) {
+	profile, err := app.models.Agents.GetProfile(user.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return &data.AgentProfile{UserID: user.ID, Name: user.Name, ServiceAreas: []string{}}, nil
+		}
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// setPropertyAgent adds the profile of the agent who listed a property to it, if they have one.
+func (app *application) setPropertyAgent(property *data.Property) error {
+	profile, err := app.models.Agents.GetProfile(property.UserID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	property.Agent = profile
+
+	return nil
+}
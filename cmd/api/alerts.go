@@ -0,0 +1,57 @@
+package main
+
+import (
+	"time"
+)
+
+// runSavedSearchAlerts periodically matches new and updated published properties against saved
+// searches and emails the instant alerts and daily or weekly digests that are due.
+func (app *application) runSavedSearchAlerts(interval time.Duration) {
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			app.sendSavedSearchAlerts()
+		}
+	})
+}
+
+// sendSavedSearchAlerts runs a single round of saved search matching and notification.
+func (app *application) sendSavedSearchAlerts() {
+	// Properties updated within the current second may still be committing, so only look at
+	// whole seconds that have fully elapsed.
+	cutoff := time.Now().Add(-time.Second).Truncate(time.Second)
+
+	_, err := app.models.SavedSearches.MatchNew(cutoff)
+	if err != nil {
+		app.logger.Println(err)
+		return
+	}
+
+	now := time.Now()
+
+	digests, err := app.models.SavedSearches.GetDueDigests(now)
+	if err != nil {
+		app.logger.Println(err)
+		return
+	}
+
+	for _, digest := range digests {
+		err = app.mailer.Send(digest.Email, "saved_search_alert.tmpl", digest)
+		if err != nil {
+			app.logger.Println(err)
+			continue
+		}
+
+		propertyIDs := make([]int64, len(digest.Properties))
+		for i, property := range digest.Properties {
+			propertyIDs[i] = property.ID
+		}
+
+		err = app.models.SavedSearches.MarkNotified(digest.SavedSearchID, propertyIDs, now)
+		if err != nil {
+			app.logger.Println(err)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/events"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// showAuctionHandler shows an auction with its current high bid and the minimum next bid. Auctions of
+// listings the requester cannot view are not found.
+func (app *application) showAuctionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	auction, err := app.models.Auctions.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if _, ok := app.viewableProperty(w, r, auction.PropertyID); !ok {
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"auction": auction}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showPropertyAuctionHandler shows the scheduled auction of a property the requester can view, or its
+// latest closed auction.
+func (app *application) showPropertyAuctionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if _, ok := app.viewableProperty(w, r, id); !ok {
+		return
+	}
+
+	auction, err := app.models.Auctions.GetForProperty(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"auction": auction}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createAuctionHandler schedules an auction of one of the authenticated user's published listings.
+func (app *application) createAuctionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PropertyID       int64     `json:"property_id"`
+		StartsAt         time.Time `json:"starts_at"`
+		EndsAt           time.Time `json:"ends_at"`
+		StartingPrice    float64   `json:"starting_price"`
+		ReservePrice     float64   `json:"reserve_price"`
+		MinIncrement     float64   `json:"min_increment"`
+		ExtensionSeconds *int      `json:"extension_seconds"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	property, err := app.models.Properties.Get(input.PropertyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if property.UserID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	auction := &data.Auction{
+		PropertyID:       property.ID,
+		PropertyTitle:    property.Title,
+		SellerID:         property.UserID,
+		StartsAt:         input.StartsAt,
+		EndsAt:           input.EndsAt,
+		StartingPrice:    input.StartingPrice,
+		ReservePrice:     input.ReservePrice,
+		MinIncrement:     input.MinIncrement,
+		ExtensionSeconds: app.config.auctions.extensionSeconds,
+	}
+
+	if input.ExtensionSeconds != nil {
+		auction.ExtensionSeconds = *input.ExtensionSeconds
+	}
+
+	v := validator.New()
+	v.Check(property.Status == data.StatusPublished, "property", "must be published")
+	if data.ValidateAuction(v, auction); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Auctions.Insert(auction)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateAuction):
+			v.AddError("property", "already has a scheduled auction")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"auction": auction, "reserve_price": auction.ReservePrice}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showAccountAuctionHandler shows an auction of one of the authenticated user's listings with its reserve
+// price and bids.
+func (app *application) showAccountAuctionHandler(w http.ResponseWriter, r *http.Request) {
+	auction, ok := app.sellerAuction(w, r)
+	if !ok {
+		return
+	}
+
+	bids, err := app.models.Auctions.GetBids(auction.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"auction": auction, "reserve_price": auction.ReservePrice, "bids": bids}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// cancelAuctionHandler cancels a scheduled auction of one of the authenticated user's listings that has
+// not received any bids.
+func (app *application) cancelAuctionHandler(w http.ResponseWriter, r *http.Request) {
+	auction, ok := app.sellerAuction(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+	v.Check(auction.Status == data.AuctionScheduled, "status", fmt.Sprintf("auction has already been %s", auction.Status))
+	if v.Check(auction.BidCount == 0, "auction", "cannot be cancelled once it has received bids"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.models.Auctions.Cancel(auction)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.broadcast(events.AuctionTopic(auction.ID), "auction.cancelled", auction)
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"auction": auction}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createBidHandler places the authenticated user's bid on a live auction. The new high bid is pushed to the
+// subscribers of the auction's topic, and the outbid user and the seller are notified.
+func (app *application) createBidHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	auction, err := app.models.Auctions.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Amount float64 `json:"amount"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	bid := &data.Bid{
+		AuctionID: auction.ID,
+		BidderID:  app.contextGetUser(r).ID,
+		Amount:    input.Amount,
+	}
+
+	v := validator.New()
+	if data.ValidateBid(v, auction, bid); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	outbidID, err := app.models.Auctions.PlaceBid(auction, bid)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrAuctionNotLive), errors.Is(err, data.ErrPropertyUnavailable):
+			v.AddError("auction", "is not open for bidding")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrHighestBidder):
+			v.AddError("amount", "you are already the highest bidder")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrBidTooLow):
+			v.AddError("amount", fmt.Sprintf("must be at least %.2f", auction.MinimumBid))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.broadcast(events.AuctionTopic(auction.ID), "auction.bid", auction)
+	app.publish(auction.SellerID, "auction.bid", envelop{"auction": auction, "bid": bid})
+	if outbidID != 0 && outbidID != bid.BidderID {
+		app.notifyAuction(auction, outbidID, "outbid")
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"bid": bid, "auction": auction}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// runAuctionClose periodically closes the auctions that have ended, determining their winners, and notifies
+// the subscribers of each auction, its winner and its seller, and the users who favourited a property put
+// under offer.
+func (app *application) runAuctionClose(interval time.Duration) {
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			closed, underOffer, err := app.models.Auctions.CloseDue()
+			if err != nil {
+				app.logger.Println(err)
+				continue
+			}
+
+			for _, auction := range closed {
+				app.broadcast(events.AuctionTopic(auction.ID), "auction.closed", auction)
+				app.notifyAuction(auction, auction.SellerID, "closed")
+				if auction.WinnerID != 0 {
+					app.notifyAuction(auction, auction.WinnerID, "won")
+				}
+			}
+
+			for _, propertyID := range underOffer {
+				app.notifyStatusChange(propertyID, data.StatusPublished)
+			}
+		}
+	})
+}
+
+// notifyAuction tells a user that they were outbid on an auction, that they won it, or, for its seller,
+// that it has closed, on their event stream and by email.
+func (app *application) notifyAuction(auction *data.Auction, userID int64, event string) {
+	if userID == 0 {
+		return
+	}
+
+	app.publish(userID, "auction."+event, auction)
+
+	app.background(func() {
+		user, err := app.models.Users.Get(userID)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		templateData := map[string]interface{}{
+			"Name":    user.Name,
+			"Event":   event,
+			"Auction": auction,
+		}
+
+		err = app.mailer.Send(user.Email, "auction.tmpl", templateData)
+		if err != nil {
+			app.logger.Println(err)
+		}
+	})
+}
+
+// sellerAuction fetches the auction identified by the id parameter, sending a 404 Not Found response if
+// there is none or a 403 Forbidden response if the authenticated user is not its seller, and returning
+// false in either case.
+func (app *application) sellerAuction(w http.ResponseWriter, r *http.Request) (*data.Auction, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	auction, err := app.models.Auctions.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if auction.SellerID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return auction, true
+}
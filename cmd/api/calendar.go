@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/ical"
+	"github.com/emzola/realty/internal/mailer"
+	"github.com/emzola/realty/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// calendarProdID identifies the application in the calendars it produces.
+const calendarProdID = "-//Realty//Realty API " + version + "//EN"
+
+// createCalendarTokenHandler issues a secret calendar feed URL for the authenticated user, revoking any
+// URL issued before.
+func (app *application) createCalendarTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	err := app.models.Tokens.DeleteAllForUser(data.ScopeCalendar, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, app.config.calendar.tokenTTL, data.ScopeCalendar)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	feed := envelop{
+		"token":  token.Plaintext,
+		"url":    fmt.Sprintf("/v1/calendar/%s.ics", token.Plaintext),
+		"expiry": token.Expiry,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"calendar": feed}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showCalendarHandler renders the viewings and open houses of the user owning a calendar token as an
+// iCalendar feed. Cancelled events stay in the feed with a cancelled status so that subscribed calendars remove them.
+func (app *application) showCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	token := strings.TrimSuffix(params.ByName("token"), ".ics")
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeCalendar, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -app.config.calendar.pastDays)
+
+	viewings, err := app.models.Viewings.GetForCalendar(user.ID, since)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	openHouses, err := app.models.OpenHouses.GetForCalendar(user.ID, since)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	calendar := &ical.Calendar{
+		ProdID: calendarProdID,
+		Name:   "Realty viewings",
+		Method: ical.MethodPublish,
+	}
+
+	for _, viewing := range viewings {
+		calendar.Events = append(calendar.Events, viewingEvent(viewing))
+	}
+
+	for _, openHouse := range openHouses {
+		calendar.Events = append(calendar.Events, openHouseEvent(openHouse))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="realty.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+
+	err = calendar.Encode(w)
+	if err != nil {
+		app.logger.Println(err)
+	}
+}
+
+// viewingEvent converts a viewing into a calendar event. The UID is derived from the viewing ID, and the
+// sequence from its version, which increases when the viewing changes.
+func viewingEvent(viewing *data.Viewing) *ical.Event {
+	event := &ical.Event{
+		UID:          fmt.Sprintf("viewing-%d@realty", viewing.ID),
+		Sequence:     int(viewing.Version) - 1,
+		Created:      viewing.CreatedAt,
+		LastModified: viewing.CreatedAt,
+		Start:        viewing.StartsAt,
+		End:          viewing.EndsAt,
+		Summary:      "Viewing: " + viewing.PropertyTitle,
+		Description:  fmt.Sprintf("Viewing of %s (/v1/properties/%d).", viewing.PropertyTitle, viewing.PropertyID),
+		Status:       ical.StatusConfirmed,
+	}
+
+	if viewing.Note != "" {
+		event.Description += "\nNote: " + viewing.Note
+	}
+
+	if viewing.Status == data.ViewingCancelled {
+		event.Status = ical.StatusCancelled
+		if viewing.CancelledAt != nil {
+			event.LastModified = *viewing.CancelledAt
+		}
+	}
+
+	return event
+}
+
+// openHouseEvent converts an open house into a calendar event. Open houses the user is waitlisted for are
+// tentative, and those they no longer attend are cancelled. The sequence counts the changes to both the open
+// house and the user's RSVP, so that calendar clients pick up a change to either.
+func openHouseEvent(openHouse *data.OpenHouse) *ical.Event {
+	sequence := int(openHouse.Version) - 1
+	if openHouse.RSVPVersion > 1 {
+		sequence += int(openHouse.RSVPVersion) - 1
+	}
+
+	lastModified := openHouse.CreatedAt
+	if openHouse.LastModified.After(lastModified) {
+		lastModified = openHouse.LastModified
+	}
+
+	event := &ical.Event{
+		UID:          fmt.Sprintf("open-house-%d@realty", openHouse.ID),
+		Sequence:     sequence,
+		Created:      openHouse.CreatedAt,
+		LastModified: lastModified,
+		Start:        openHouse.StartsAt,
+		End:          openHouse.EndsAt,
+		Summary:      "Open house: " + openHouse.PropertyTitle,
+		Description:  fmt.Sprintf("Open house at %s (/v1/properties/%d).", openHouse.PropertyTitle, openHouse.PropertyID),
+		Status:       ical.StatusConfirmed,
+	}
+
+	switch {
+	case openHouse.Status == data.OpenHouseCancelled || openHouse.RSVPStatus == data.RSVPCancelled:
+		event.Status = ical.StatusCancelled
+	case openHouse.RSVPStatus == data.RSVPWaitlisted:
+		event.Status = ical.StatusTentative
+	}
+
+	return event
+}
+
+// viewingAttachment returns an .ics attachment containing a viewing, for notification emails. Cancelled
+// viewings are sent with the CANCEL method so that mail clients remove the event from the calendar.
+func viewingAttachment(viewing *data.Viewing) mailer.Attachment {
+	event := viewingEvent(viewing)
+
+	method := ical.MethodPublish
+	if event.Status == ical.StatusCancelled {
+		method = ical.MethodCancel
+	}
+
+	calendar := &ical.Calendar{
+		ProdID: calendarProdID,
+		Method: method,
+		Events: []*ical.Event{event},
+	}
+
+	return mailer.Attachment{
+		Filename:    fmt.Sprintf("viewing-%d.ics", viewing.ID),
+		ContentType: "text/calendar; charset=utf-8; method=" + method,
+		Content:     []byte(calendar.String()),
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// listCalendarFeedsHandler lists the external calendar feeds imported into one of the authenticated user's properties.
+func (app *application) listCalendarFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.ownedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	feeds, err := app.models.CalendarFeeds.GetAllForProperty(property.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"feeds": feeds}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createCalendarFeedHandler adds an external calendar feed to one of the authenticated user's properties and
+// imports it straight away in the background.
+func (app *application) createCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.ownedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	feed := &data.CalendarFeed{
+		PropertyID: property.ID,
+		Name:       input.Name,
+		URL:        input.URL,
+	}
+
+	v := validator.New()
+	if data.ValidateCalendarFeed(v, feed); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.CalendarFeeds.Insert(feed)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateFeed):
+			v.AddError("url", "this feed has already been added to the property")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	synced := *feed
+	app.background(func() {
+		app.syncCalendarFeed(&synced)
+	})
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"feed": feed}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteCalendarFeedHandler removes an external calendar feed from one of the authenticated user's properties,
+// together with the blocks imported from it.
+func (app *application) deleteCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.CalendarFeeds.Delete(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "feed successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// runCalendarFeedSync periodically imports the calendar feeds that have not been synced for an interval, a
+// batch at a time.
+func (app *application) runCalendarFeedSync(interval time.Duration, batchSize int) {
+	app.background(func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			feeds, err := app.models.CalendarFeeds.GetDue(time.Now().Add(-interval), batchSize)
+			if err != nil {
+				app.logger.Println(err)
+				continue
+			}
+
+			for _, feed := range feeds {
+				app.syncCalendarFeed(feed)
+			}
+		}
+	})
+}
+
+// syncCalendarFeed fetches a calendar feed and reconciles the blocks imported from it. Failures are recorded
+// on the feed, leaving the blocks imported by the last successful sync in place.
+func (app *application) syncCalendarFeed(feed *data.CalendarFeed) {
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.feeds.timeout)
+	defer cancel()
+
+	result, err := app.feeds.Fetch(ctx, feed)
+	switch {
+	case err != nil:
+		err = app.models.CalendarFeeds.MarkSynced(feed, err.Error())
+	case result.NotModified:
+		err = app.models.CalendarFeeds.MarkSynced(feed, "")
+	default:
+		feed.ETag, feed.LastModified = result.ETag, result.LastModified
+		_, err = app.models.CalendarFeeds.Reconcile(feed, result.Blocks)
+	}
+
+	if err != nil {
+		app.logger.Println(err)
+	}
+}
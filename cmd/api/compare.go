@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// comparePropertiesHandler returns a side-by-side comparison matrix of up to four properties.
+func (app *application) comparePropertiesHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	ids := app.readIDCSV(qs.Get("ids"), "ids", v)
+	currency := strings.ToUpper(app.readString(qs, "currency", app.config.currency.base))
+
+	if data.ValidateComparison(v, ids, currency, app.config.currency.rates); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	properties, err := app.models.Properties.GetMany(ids)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(properties) != len(ids) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	for _, property := range properties {
+		if !app.canViewProperty(r, property) {
+			app.notFoundResponse(w, r)
+			return
+		}
+	}
+
+	comparison := data.NewComparison(data.SortByIDs(properties, ids), currency, app.config.currency.rates)
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"comparison": comparison}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readIDCSV converts a comma-separated list of IDs into a slice. If a value couldn't be
+// converted, then an error message is recorded in the provided Validator instance.
+func (app *application) readIDCSV(csv, key string, v *validator.Validator) []int64 {
+	if csv == "" {
+		return nil
+	}
+
+	values := strings.Split(csv, ",")
+	ids := make([]int64, 0, len(values))
+
+	for _, value := range values {
+		id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			v.AddError(key, "must be a comma-separated list of IDs")
+			return nil
+		}
+		ids = append(ids, id)
+	}
+
+	return ids
+}
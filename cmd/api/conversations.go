@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// startConversationHandler posts a message to the agent of a published property, starting a conversation
+// about the property or continuing the one the authenticated user already has.
+func (app *application) startConversationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	property, err := app.models.Properties.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if property.Status != data.StatusPublished {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Body string `json:"body"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	message := &data.Message{SenderID: user.ID, Body: input.Body}
+
+	v := validator.New()
+	v.Check(property.UserID != 0, "property", "has no agent to message")
+	v.Check(property.UserID != user.ID, "property", "must not be your own listing")
+	if data.ValidateMessage(v, message); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	conversation, err := app.models.Conversations.GetOrCreate(property.ID, user.ID, property.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	message.ConversationID = conversation.ID
+
+	err = app.models.Conversations.InsertMessage(message)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	conversation.LastMessageAt = message.CreatedAt
+	conversation.Messages = []*data.Message{message}
+
+	app.publishMessage(conversation, message)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/account/conversations/%d", conversation.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"conversation": conversation}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listConversationsHandler lists the conversations of the authenticated user, most recently active first,
+// with the number of unread messages in each and in total.
+func (app *application) listConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-last_message_at"
+	input.Filters.SortSafelist = []string{"-last_message_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	conversations, metadata, err := app.models.Conversations.GetAllForUser(user.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	unread, err := app.models.Conversations.CountUnread(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"conversations": conversations, "unread_count": unread, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showConversationHandler shows a conversation of the authenticated user with a page of its messages,
+// oldest first, and marks the messages sent to the user up to the end of the page as read.
+func (app *application) showConversationHandler(w http.ResponseWriter, r *http.Request) {
+	conversation, ok := app.participantConversation(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 50, v)
+	input.Filters.Sort = "created_at"
+	input.Filters.SortSafelist = []string{"created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	messages, metadata, err := app.models.Conversations.GetMessages(conversation.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	conversation.Messages = messages
+
+	err = app.markConversationRead(conversation, app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"conversation": conversation, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createMessageHandler posts a message to a conversation of the authenticated user.
+func (app *application) createMessageHandler(w http.ResponseWriter, r *http.Request) {
+	conversation, ok := app.participantConversation(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Body string `json:"body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	message := &data.Message{
+		ConversationID: conversation.ID,
+		SenderID:       app.contextGetUser(r).ID,
+		Body:           input.Body,
+	}
+
+	v := validator.New()
+	if data.ValidateMessage(v, message); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Conversations.InsertMessage(message)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.publishMessage(conversation, message)
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"message": message}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// participantConversation fetches the conversation identified by the id parameter, sending an error response
+// and returning false if it does not exist or the authenticated user is not one of its participants.
+func (app *application) participantConversation(w http.ResponseWriter, r *http.Request) (*data.Conversation, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	conversation, err := app.models.Conversations.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !conversation.HasParticipant(app.contextGetUser(r).ID) {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return conversation, true
+}
+
+// publishMessage sends a new message to the event streams of both participants of a conversation, so that
+// the sender's other sessions also receive it.
+func (app *application) publishMessage(conversation *data.Conversation, message *data.Message) {
+	payload := envelop{"conversation_id": conversation.ID, "property_id": conversation.PropertyID, "message": message}
+
+	app.publish(conversation.Recipient(message.SenderID), "message.created", payload)
+	app.publish(message.SenderID, "message.created", payload)
+}
+
+// markConversationRead marks the messages of a conversation sent to a user as read, up to the last of the
+// messages they were shown, and tells the other participant on their event stream.
+func (app *application) markConversationRead(conversation *data.Conversation, readerID int64) error {
+	if len(conversation.Messages) == 0 {
+		return nil
+	}
+
+	readAt := time.Now()
+	last := conversation.Messages[len(conversation.Messages)-1]
+
+	count, err := app.models.Conversations.MarkRead(conversation.ID, readerID, last, readAt)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range conversation.Messages {
+		if message.SenderID != readerID && message.ReadAt == nil {
+			message.ReadAt = &readAt
+		}
+	}
+
+	if count > 0 {
+		app.publish(conversation.Recipient(readerID), "messages.read", envelop{
+			"conversation_id": conversation.ID,
+			"reader_id":       readerID,
+			"last_message_id": last.ID,
+			"read_at":         readAt,
+		})
+	}
+
+	return nil
+}
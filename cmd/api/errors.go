@@ -54,3 +54,41 @@ func(app *application) editConflictResponse(w http.ResponseWriter, r *http.Reque
 	message := "unable to update the record due to an edit conflict. Please try again"
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
+
+// invalidCredentialsResponse sends a 401 status code and JSON response to the client.
+func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid authentication credentials"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// invalidAuthenticationTokenResponse sends a 401 status code and JSON response to the client.
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	message := "invalid or missing authentication token"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// authenticationRequiredResponse sends a 401 status code and JSON response to the client.
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you must be authenticated to access this resource"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// notPermittedResponse sends a 403 status code and JSON response to the client.
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+// rateLimitExceededResponse sends a 429 status code and JSON response to the client.
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "rate limit exceeded"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+// slotUnavailableResponse sends a 409 status code and JSON response to the client.
+func (app *application) slotUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the requested slot is no longer available, please choose another"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emzola/realty/internal/data"
+)
+
+// listFavouritesHandler returns the full records of the authenticated user's favourite properties that they
+// can still view.
+func (app *application) listFavouritesHandler(w http.ResponseWriter, r *http.Request) {
+	properties, err := app.models.Favourites.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"favourites": properties}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// addFavouriteHandler adds a property the authenticated user can view to their favourites.
+func (app *application) addFavouriteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	property, ok := app.viewableProperty(w, r, id)
+	if !ok {
+		return
+	}
+
+	err = app.models.Favourites.Insert(app.contextGetUser(r).ID, property.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "property successfully added to favourites"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// removeFavouriteHandler removes a property from the authenticated user's favourites.
+func (app *application) removeFavouriteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Favourites.Delete(app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "property successfully removed from favourites"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// setFavouriteFlags marks which of the properties are favourites of the user making the request.
+// Properties returned to anonymous users are left without the flag.
+func (app *application) setFavouriteFlags(r *http.Request, properties ...*data.Property) error {
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() {
+		return nil
+	}
+	return app.models.Favourites.SetFlags(user.ID, properties...)
+}
+
+// notifyFavouriteChange notifies the users who favourited a property, on their event streams and by
+// email, when its price or status has changed. Listings held for moderation are described as unavailable,
+// so that favouriters do not learn that a listing is under review.
+func (app *application) notifyFavouriteChange(property *data.Property, oldPrice float64, oldStatus string) {
+	status, oldStatus := favouriteStatus(property.Status), favouriteStatus(oldStatus)
+	if property.Price == oldPrice && status == oldStatus {
+		return
+	}
+
+	app.background(func() {
+		users, err := app.models.Favourites.GetUsersForProperty(property.ID)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		change := envelop{
+			"property_id": property.ID,
+			"title":       property.Title,
+			"old_price":   oldPrice,
+			"price":       property.Price,
+			"currency":    property.Currency,
+			"old_status":  oldStatus,
+			"status":      status,
+		}
+		for _, user := range users {
+			app.publish(user.ID, "property.changed", change)
+		}
+
+		for _, user := range users {
+			templateData := map[string]interface{}{
+				"Name":      user.Name,
+				"Property":  property,
+				"OldPrice":  oldPrice,
+				"OldStatus": oldStatus,
+				"Status":    status,
+			}
+
+			err = app.mailer.Send(user.Email, "favourite_changed.tmpl", templateData)
+			if err != nil {
+				app.logger.Println(err)
+			}
+		}
+	})
+}
+
+// favouriteStatus returns the status of a listing as it is shown to the users who favourited it.
+func favouriteStatus(status string) string {
+	if status == data.StatusPendingReview {
+		return "unavailable"
+	}
+	return status
+}
+
+// notifyStatusChange notifies the users who favourited a property whose status was changed from oldStatus
+// by a model method, fetching the property as it is now.
+func (app *application) notifyStatusChange(propertyID int64, oldStatus string) {
+	app.background(func() {
+		property, err := app.models.Properties.Get(propertyID)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		app.notifyFavouriteChange(property, property.Price, oldStatus)
+	})
+}
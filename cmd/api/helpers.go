@@ -5,10 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -85,3 +89,237 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, destina
 	}
 	return nil
 }
+
+// readString returns a string value from the query string, or the provided default value if no matching key is found.
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+// readInt reads a string value from the query string and converts it to an integer before returning.
+// If no matching key is found it returns the provided default value. If the value couldn't be converted
+// to an integer, then an error message is recorded in the provided Validator instance.
+func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+
+	return i
+}
+
+// readCSV reads a comma-separated string value from the query string and splits it into a slice.
+// If no matching key is found it returns the provided default value.
+func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
+	csv := qs.Get(key)
+	if csv == "" {
+		return defaultValue
+	}
+	return strings.Split(csv, ",")
+}
+
+// readFloat reads a string value from the query string and converts it to a float before returning.
+// If no matching key is found it returns the provided default value. If the value couldn't be converted
+// to a float, then an error message is recorded in the provided Validator instance.
+func (app *application) readFloat(qs url.Values, key string, defaultValue float64, v *validator.Validator) float64 {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		v.AddError(key, "must be a number")
+		return defaultValue
+	}
+
+	return f
+}
+
+// readFloatCSV reads a comma-separated list of numbers from the query string. If no matching key is found
+// it returns the provided default value. If a value couldn't be converted to a float, then an error message
+// is recorded in the provided Validator instance.
+func (app *application) readFloatCSV(qs url.Values, key string, defaultValue []float64, v *validator.Validator) []float64 {
+	values := app.readCSV(qs, key, nil)
+	if values == nil {
+		return defaultValue
+	}
+
+	floats, err := parseFloats(values)
+	if err != nil {
+		v.AddError(key, "must be a comma-separated list of numbers")
+		return defaultValue
+	}
+
+	return floats
+}
+
+// parseFloats converts a slice of strings into a slice of floats.
+func parseFloats(values []string) ([]float64, error) {
+	floats := make([]float64, 0, len(values))
+	for _, value := range values {
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, err
+		}
+		floats = append(floats, f)
+	}
+	return floats, nil
+}
+
+// cityIn reports whether a city is in a list of cities, ignoring case and surrounding spaces.
+func cityIn(list []string, city string) bool {
+	for _, c := range list {
+		if strings.EqualFold(strings.TrimSpace(c), strings.TrimSpace(city)) {
+			return true
+		}
+	}
+	return false
+}
+
+// background runs a function in a goroutine, recovering and logging any panic so that it cannot crash the application.
+func (app *application) background(fn func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Println(fmt.Errorf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// clientIP returns the IP address of the client making a request.
+func (app *application) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// publishedProperty fetches the published property identified by the id parameter, sending a 404 Not Found
+// response and returning false if there is none.
+func (app *application) publishedProperty(w http.ResponseWriter, r *http.Request) (*data.Property, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	property, err := app.models.Properties.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if property.Status != data.StatusPublished {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return property, true
+}
+
+// canViewProperty reports whether the requester can see a property. Published listings are public, while
+// listings with any other status, such as those held for moderation, are only visible to their owner.
+func (app *application) canViewProperty(r *http.Request, property *data.Property) bool {
+	if property.Status == data.StatusPublished {
+		return true
+	}
+	user := app.contextGetUser(r)
+	return !user.IsAnonymous() && property.UserID == user.ID
+}
+
+// viewableProperty fetches a property the requester can view, sending a 404 Not Found response and
+// returning false if there is none.
+func (app *application) viewableProperty(w http.ResponseWriter, r *http.Request, id int64) (*data.Property, bool) {
+	property, err := app.models.Properties.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !app.canViewProperty(r, property) {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return property, true
+}
+
+// canManageProperty reports whether the authenticated user may change a property. Properties are managed by
+// their owner, while legacy listings without an owner are managed by users with the properties:manage
+// permission.
+func (app *application) canManageProperty(r *http.Request, property *data.Property) (bool, error) {
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() {
+		return false, nil
+	}
+
+	if property.UserID != 0 {
+		return property.UserID == user.ID, nil
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return permissions.Include(data.PermissionPropertiesManage), nil
+}
+
+// ownedProperty fetches the property identified by the id parameter, sending a 404 Not Found response if
+// there is none or a 403 Forbidden response if the authenticated user cannot manage it, and returning
+// false in either case.
+func (app *application) ownedProperty(w http.ResponseWriter, r *http.Request) (*data.Property, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	property, err := app.models.Properties.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	canManage, err := app.canManageProperty(r, property)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	if !canManage {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return property, true
+}
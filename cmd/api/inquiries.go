@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// createInquiryHandler sends an inquiry about a published property to its owner.
+func (app *application) createInquiryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	property, err := app.models.Properties.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if property.Status != data.StatusPublished {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Name    string `json:"name"`
+		Email   string `json:"email"`
+		Phone   string `json:"phone"`
+		Message string `json:"message"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	inquiry := &data.Inquiry{
+		PropertyID:    property.ID,
+		PropertyTitle: property.Title,
+		OwnerID:       property.UserID,
+		UserID:        app.contextGetUser(r).ID,
+		Name:          input.Name,
+		Email:         input.Email,
+		Phone:         input.Phone,
+		Message:       input.Message,
+	}
+
+	v := validator.New()
+	if data.ValidateInquiry(v, inquiry); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Suspicious inquiries are held for moderation instead of being sent to the owner. The sender
+	// gets the same response either way.
+	result, err := app.screenInquiry(inquiry)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	inquiry.Held = result.Held
+
+	err = app.models.Inquiries.Insert(inquiry)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if inquiry.Held {
+		err = app.holdForModeration(&data.ModerationItem{
+			ItemType: data.ModerationItemInquiry,
+			ItemID:   inquiry.ID,
+			Score:    result.Score,
+			Reasons:  result.Reasons(),
+		})
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	} else {
+		app.notifyInquiry(inquiry)
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"inquiry": inquiry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// notifyInquiry notifies the owner of a property about an inquiry they have received, on their event
+// stream and by email.
+func (app *application) notifyInquiry(inquiry *data.Inquiry) {
+	if inquiry.OwnerID == 0 {
+		return
+	}
+
+	app.publish(inquiry.OwnerID, "inquiry.received", inquiry)
+
+	app.background(func() {
+		owner, err := app.models.Users.Get(inquiry.OwnerID)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		templateData := map[string]interface{}{
+			"Name":    owner.Name,
+			"Inquiry": inquiry,
+		}
+
+		err = app.mailer.Send(owner.Email, "inquiry_received.tmpl", templateData)
+		if err != nil {
+			app.logger.Println(err)
+		}
+	})
+}
+
+// listInquiriesHandler lists the inquiries received by the authenticated user, optionally filtered by whether they have been contacted.
+func (app *application) listInquiriesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Contacted *bool
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	if contacted := qs.Get("contacted"); contacted != "" {
+		value, err := strconv.ParseBool(contacted)
+		if err != nil {
+			v.AddError("contacted", "must be true or false")
+		}
+		input.Contacted = &value
+	}
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafelist = []string{"-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	inquiries, metadata, err := app.models.Inquiries.GetAllForOwner(app.contextGetUser(r).ID, input.Contacted, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"inquiries": inquiries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateInquiryHandler marks an inquiry received by the authenticated user as contacted or not contacted.
+func (app *application) updateInquiryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	inquiry, err := app.models.Inquiries.GetForOwner(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Contacted *bool `json:"contacted"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Contacted == nil {
+		app.badRequestResponse(w, r, fmt.Errorf("body must contain the contacted key"))
+		return
+	}
+
+	switch {
+	case *input.Contacted && inquiry.ContactedAt == nil:
+		now := time.Now()
+		inquiry.ContactedAt = &now
+	case !*input.Contacted:
+		inquiry.ContactedAt = nil
+	}
+
+	err = app.models.Inquiries.Update(inquiry)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"inquiry": inquiry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
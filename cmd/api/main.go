@@ -8,9 +8,16 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/events"
+	"github.com/emzola/realty/internal/icalsync"
+	"github.com/emzola/realty/internal/mailer"
+	"github.com/emzola/realty/internal/screening"
+	"github.com/emzola/realty/internal/storage"
 	_ "github.com/lib/pq"
 )
 
@@ -25,12 +32,107 @@ type config struct {
 		maxIdleConns int
 		maxIdleTime  string
 	}
+	facets struct {
+		priceBuckets []float64
+	}
+	smtp struct {
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+	}
+	alerts struct {
+		interval time.Duration
+	}
+	currency struct {
+		base  string
+		rates data.ExchangeRates
+	}
+	similar struct {
+		weights  data.SimilarityWeights
+		radiusKm float64
+	}
+	valuation struct {
+		radiusKm float64
+	}
+	marketStats struct {
+		refreshInterval time.Duration
+	}
+	views struct {
+		bufferSize    int
+		batchSize     int
+		flushInterval time.Duration
+	}
+	limiter struct {
+		rps     float64
+		burst   int
+		enabled bool
+	}
+	screening struct {
+		threshold             float64
+		maxLinks              int
+		blockedWords          []string
+		disposableDomainsFile string
+		repeatWindow          time.Duration
+		repeatMax             int
+	}
+	moderation struct {
+		reviewCities []string
+	}
+	verification struct {
+		requiredCities []string
+	}
+	reports struct {
+		threshold int
+	}
+	viewings struct {
+		minNotice time.Duration
+		maxDays   int
+	}
+	calendar struct {
+		tokenTTL time.Duration
+		pastDays int
+	}
+	feeds struct {
+		interval     time.Duration
+		timeout      time.Duration
+		maxBytes     int64
+		batchSize    int
+		allowPrivate bool
+	}
+	offers struct {
+		expiryInterval time.Duration
+	}
+	auctions struct {
+		closeInterval    time.Duration
+		extensionSeconds int
+	}
+	storage struct {
+		dir string
+	}
+	documents struct {
+		maxBytes int64
+	}
+	stream struct {
+		maxDuration time.Duration
+		heartbeat   time.Duration
+		retry       time.Duration
+		history     int
+		bufferSize  int
+	}
 }
 
 type application struct {
-	config config
-	logger *log.Logger
-	models data.Models
+	config   config
+	logger   *log.Logger
+	models   data.Models
+	mailer   mailer.Mailer
+	views    chan data.PropertyView
+	screener *screening.Pipeline
+	events   events.Broker
+	feeds    *icalsync.Fetcher
+	storage  storage.Store
 }
 
 func main() {
@@ -45,6 +147,104 @@ func main() {
 	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgresQl max open connections")
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgresQl max idle connections")
 	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
+
+	cfg.facets.priceBuckets = []float64{50_000, 100_000, 250_000, 500_000, 1_000_000}
+	flag.Func("facets-price-buckets", "Comma-separated price histogram bucket boundaries (default 50000,100000,250000,500000,1000000)", func(val string) error {
+		buckets, err := parseFloats(strings.Split(val, ","))
+		if err != nil {
+			return err
+		}
+		cfg.facets.priceBuckets = buckets
+		return nil
+	})
+
+	flag.StringVar(&cfg.smtp.host, "smtp-host", "localhost", "SMTP host")
+	flag.IntVar(&cfg.smtp.port, "smtp-port", 1025, "SMTP port")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", os.Getenv("REALTY_SMTP_USERNAME"), "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", os.Getenv("REALTY_SMTP_PASSWORD"), "SMTP password")
+	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Realty <no-reply@realty.com>", "SMTP sender")
+
+	flag.DurationVar(&cfg.alerts.interval, "alerts-interval", time.Minute, "Interval between saved search alert runs")
+
+	flag.StringVar(&cfg.currency.base, "currency-base", "USD", "Default currency that prices are converted to")
+	cfg.currency.rates = data.ExchangeRates{"USD": 1, "GBP": 1.27, "EUR": 1.08, "NGN": 0.00065}
+	flag.Func("currency-rates", "Comma-separated exchange rates to the base currency (default USD=1,GBP=1.27,EUR=1.08,NGN=0.00065)", func(val string) error {
+		rates, err := data.ParseExchangeRates(val)
+		if err != nil {
+			return err
+		}
+		cfg.currency.rates = rates
+		return nil
+	})
+
+	flag.Float64Var(&cfg.similar.weights.Distance, "similar-weight-distance", 3, "Weight of distance when scoring similar properties")
+	flag.Float64Var(&cfg.similar.weights.Type, "similar-weight-type", 2, "Weight of a shared type when scoring similar properties")
+	flag.Float64Var(&cfg.similar.weights.Category, "similar-weight-category", 2, "Weight of a shared category when scoring similar properties")
+	flag.Float64Var(&cfg.similar.weights.Price, "similar-weight-price", 3, "Weight of the price band when scoring similar properties")
+	flag.Float64Var(&cfg.similar.weights.Features, "similar-weight-features", 2, "Weight of bedrooms, bathrooms and size when scoring similar properties")
+	flag.Float64Var(&cfg.similar.weights.Amenities, "similar-weight-amenities", 1, "Weight of amenity overlap when scoring similar properties")
+	flag.Float64Var(&cfg.similar.radiusKm, "similar-radius-km", 10, "Radius in kilometres within which similar properties are searched")
+
+	flag.Float64Var(&cfg.valuation.radiusKm, "valuation-radius-km", 5, "Radius in kilometres within which comparables are picked for valuations")
+
+	flag.DurationVar(&cfg.marketStats.refreshInterval, "market-stats-refresh-interval", time.Hour, "Interval between market statistics refreshes")
+
+	flag.IntVar(&cfg.views.bufferSize, "views-buffer-size", 10_000, "Number of listing views queued before new views are dropped")
+	flag.IntVar(&cfg.views.batchSize, "views-batch-size", 500, "Number of listing views written to the database in one batch")
+	flag.DurationVar(&cfg.views.flushInterval, "views-flush-interval", 5*time.Second, "Maximum time listing views are queued before being written")
+
+	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 0.1, "Rate limiter maximum requests per second for anonymous submissions")
+	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 3, "Rate limiter maximum burst for anonymous submissions")
+	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+
+	flag.Float64Var(&cfg.screening.threshold, "screening-threshold", 1, "Spam score at which listings and inquiries are held for moderation")
+	flag.IntVar(&cfg.screening.maxLinks, "screening-max-links", 2, "Number of links allowed in listing descriptions and inquiry messages")
+	cfg.screening.blockedWords = []string{"bitcoin", "crypto investment", "western union", "moneygram", "wire transfer", "gift card", "casino", "viagra"}
+	flag.Func("screening-blocked-words", "Comma-separated words and phrases that count towards the spam score", func(val string) error {
+		cfg.screening.blockedWords = strings.Split(val, ",")
+		return nil
+	})
+	flag.StringVar(&cfg.screening.disposableDomainsFile, "screening-disposable-domains-file", "", "File listing disposable email domains, one per line (default bundled list)")
+	flag.DurationVar(&cfg.screening.repeatWindow, "screening-repeat-window", time.Hour, "Window in which repeated submissions are counted")
+	flag.IntVar(&cfg.screening.repeatMax, "screening-repeat-max", 5, "Number of similar submissions allowed within the repeat window")
+
+	flag.Func("moderation-review-cities", "Comma-separated cities whose listings are reviewed by a moderator before they are published", func(val string) error {
+		cfg.moderation.reviewCities = strings.Split(val, ",")
+		return nil
+	})
+
+	flag.Func("verification-required-cities", "Comma-separated cities in which only agents with a verified licence can publish listings", func(val string) error {
+		cfg.verification.requiredCities = strings.Split(val, ",")
+		return nil
+	})
+
+	flag.IntVar(&cfg.reports.threshold, "reports-threshold", 3, "Number of distinct signed-in users reporting a listing before it is taken down for review")
+
+	flag.DurationVar(&cfg.viewings.minNotice, "viewings-min-notice", 2*time.Hour, "Minimum notice before a viewing slot can be booked")
+	flag.IntVar(&cfg.viewings.maxDays, "viewings-max-days", 31, "Maximum number of days of viewing slots listed at once")
+
+	flag.DurationVar(&cfg.calendar.tokenTTL, "calendar-token-ttl", 5*365*24*time.Hour, "Lifetime of calendar feed URLs")
+	flag.IntVar(&cfg.calendar.pastDays, "calendar-past-days", 30, "Number of days of past events included in calendar feeds")
+
+	flag.DurationVar(&cfg.feeds.interval, "feeds-interval", 30*time.Minute, "Interval between imports of each external calendar feed")
+	flag.DurationVar(&cfg.feeds.timeout, "feeds-timeout", 10*time.Second, "Time after which fetching an external calendar feed is abandoned")
+	flag.Int64Var(&cfg.feeds.maxBytes, "feeds-max-bytes", 5_242_880, "Maximum size of an external calendar feed")
+	flag.IntVar(&cfg.feeds.batchSize, "feeds-batch-size", 50, "Number of external calendar feeds imported per minute")
+	flag.BoolVar(&cfg.feeds.allowPrivate, "feeds-allow-private", false, "Allow external calendar feeds on private network addresses")
+
+	flag.DurationVar(&cfg.offers.expiryInterval, "offers-expiry-interval", time.Minute, "Interval between runs expiring offers that have not been responded to")
+
+	flag.DurationVar(&cfg.auctions.closeInterval, "auctions-close-interval", 5*time.Second, "Interval between runs closing ended auctions and determining their winners")
+	flag.IntVar(&cfg.auctions.extensionSeconds, "auctions-extension-seconds", 300, "Default anti-sniping window in seconds by which late bids extend an auction")
+
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory in which private uploaded files are stored")
+	flag.Int64Var(&cfg.documents.maxBytes, "documents-max-bytes", 10_485_760, "Maximum size of a document uploaded with a rental application or licence verification")
+
+	flag.DurationVar(&cfg.stream.maxDuration, "stream-max-duration", 25*time.Second, "Time after which event streams are closed for clients to reconnect; must be below the 30s write timeout")
+	flag.DurationVar(&cfg.stream.heartbeat, "stream-heartbeat", 10*time.Second, "Interval between heartbeat comments sent on idle event streams")
+	flag.DurationVar(&cfg.stream.retry, "stream-retry", time.Second, "Delay after which clients reconnect to a closed event stream")
+	flag.IntVar(&cfg.stream.history, "stream-history", 1000, "Number of recent events kept for clients resuming an event stream")
+	flag.IntVar(&cfg.stream.bufferSize, "stream-buffer-size", 64, "Number of events buffered for each event stream before new events are dropped")
 	flag.Parse()
 
 	// Declare new default logger
@@ -58,12 +258,53 @@ func main() {
 	defer db.Close()
 	logger.Printf("database connection pool established")
 
+	smtpMailer, err := mailer.NewSMTP(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	models := data.NewModels(db)
+
+	store, err := storage.NewDisk(cfg.storage.dir)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	disposableDomains, err := screening.LoadDisposableDomains(cfg.screening.disposableDomainsFile)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	screener := screening.New(cfg.screening.threshold,
+		screening.LinkCount{Max: cfg.screening.maxLinks, ScorePerLink: 0.25},
+		screening.BlockedWords{Words: cfg.screening.blockedWords, Score: 0.5},
+		screening.RepeatedSubmissions{Counter: models.Moderation, Window: cfg.screening.repeatWindow, Max: cfg.screening.repeatMax, Score: 1},
+		screening.DisposableEmail{Domains: disposableDomains, Score: 0.5},
+	)
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
+		config:   cfg,
+		logger:   logger,
+		models:   models,
+		mailer:   smtpMailer,
+		views:    make(chan data.PropertyView, cfg.views.bufferSize),
+		screener: screener,
+		events:   events.NewHub(cfg.stream.history, cfg.stream.bufferSize),
+		feeds: &icalsync.Fetcher{
+			Client:    icalsync.NewClient(cfg.feeds.timeout, cfg.feeds.allowPrivate),
+			MaxBytes:  cfg.feeds.maxBytes,
+			UserAgent: "Realty/" + version,
+		},
+		storage: store,
 	}
 
+	app.runSavedSearchAlerts(cfg.alerts.interval)
+	app.runMarketStatsRefresh(cfg.marketStats.refreshInterval)
+	app.runViewRecorder(cfg.views.batchSize, cfg.views.flushInterval)
+	app.runCalendarFeedSync(cfg.feeds.interval, cfg.feeds.batchSize)
+	app.runOfferExpiry(cfg.offers.expiryInterval)
+	app.runAuctionClose(cfg.auctions.closeInterval)
+
 	// Create HTTP server with timeout settings
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.port),
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// showMarketStatsHandler returns market trend statistics for a city or neighbourhood over time.
+func (app *application) showMarketStatsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	q := data.MarketStatsQuery{
+		City:     app.readString(qs, "city", ""),
+		Location: app.readString(qs, "location", ""),
+		Type:     app.readString(qs, "type", ""),
+		Currency: app.readString(qs, "currency", ""),
+		Period:   app.readString(qs, "period", "monthly"),
+		Periods:  app.readInt(qs, "periods", 12, v),
+	}
+
+	if data.ValidateMarketStatsQuery(v, q); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stats, err := app.models.MarketStats.Get(q)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"market_stats": stats}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// runMarketStatsRefresh periodically refreshes the market statistics rollup.
+func (app *application) runMarketStatsRefresh(interval time.Duration) {
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			err := app.models.MarketStats.Refresh()
+			if err != nil {
+				app.logger.Println(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// listModerationItemsHandler lists the listings and inquiries in the moderation queue, oldest first,
+// with the reasons they were flagged.
+func (app *application) listModerationItemsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status   string
+		ItemType string
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", data.ModerationPending)
+	input.ItemType = app.readString(qs, "item_type", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "created_at"
+	input.Filters.SortSafelist = []string{"created_at"}
+
+	data.ValidateModerationQuery(v, input.Status, input.ItemType)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	items, metadata, err := app.models.Moderation.GetAll(input.Status, input.ItemType, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"moderation_items": items, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// approveModerationItemHandler approves a listing or inquiry awaiting review.
+func (app *application) approveModerationItemHandler(w http.ResponseWriter, r *http.Request) {
+	app.decideModerationItem(w, r, data.ModerationApproved)
+}
+
+// rejectModerationItemHandler rejects a listing or inquiry awaiting review.
+func (app *application) rejectModerationItemHandler(w http.ResponseWriter, r *http.Request) {
+	app.decideModerationItem(w, r, data.ModerationRejected)
+}
+
+// decideModerationItem records the authenticated moderator's decision on a moderation item and
+// notifies the people affected by it.
+func (app *application) decideModerationItem(w http.ResponseWriter, r *http.Request, decision string) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	item, err := app.models.Moderation.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(item.Status == data.ModerationPending, "status", "item has already been reviewed")
+	if data.ValidateModerationDecision(v, decision, input.Reason); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Moderation.Decide(item, app.contextGetUser(r).ID, decision, input.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyModerationDecision(item)
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"moderation_item": item}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// notifyModerationDecision emails the owner of a rejected listing, and delivers an approved inquiry
+// to the owner of the listing it was sent about.
+func (app *application) notifyModerationDecision(item *data.ModerationItem) {
+	switch {
+	case item.ItemType == data.ModerationItemInquiry && item.Status == data.ModerationApproved:
+		inquiry, err := app.models.Inquiries.Get(item.ItemID)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+		app.notifyInquiry(inquiry)
+
+	case item.ItemType == data.ModerationItemProperty && item.Status == data.ModerationRejected:
+		app.background(func() {
+			property, err := app.models.Properties.Get(item.ItemID)
+			if err != nil {
+				app.logger.Println(err)
+				return
+			}
+
+			if property.UserID == 0 {
+				return
+			}
+
+			owner, err := app.models.Users.Get(property.UserID)
+			if err != nil {
+				app.logger.Println(err)
+				return
+			}
+
+			templateData := map[string]interface{}{
+				"Name":     owner.Name,
+				"Property": property,
+				"Reason":   item.DecisionReason,
+			}
+
+			err = app.mailer.Send(owner.Email, "listing_rejected.tmpl", templateData)
+			if err != nil {
+				app.logger.Println(err)
+			}
+		})
+	}
+}
+
+// listAuditLogHandler lists audit log entries, newest first, optionally for a single entity.
+func (app *application) listAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		EntityType string
+		EntityID   int
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.EntityType = app.readString(qs, "entity_type", "")
+	input.EntityID = app.readInt(qs, "entity_id", 0, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafelist = []string{"-created_at"}
+
+	v.Check(input.EntityID >= 0, "entity_id", "must not be negative")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	entries, metadata, err := app.models.AuditLog.GetAll(input.EntityType, int64(input.EntityID), input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"audit_log": entries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
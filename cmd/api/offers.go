@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// createOfferHandler makes the authenticated user's offer to buy a published property, starting a negotiation
+// with its seller.
+func (app *application) createOfferHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.publishedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Amount     float64   `json:"amount"`
+		Conditions []string  `json:"conditions"`
+		ExpiresAt  time.Time `json:"expires_at"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	offer := &data.Offer{
+		PropertyID:    property.ID,
+		PropertyTitle: property.Title,
+		SellerID:      property.UserID,
+		BuyerID:       user.ID,
+		MadeBy:        user.ID,
+		Amount:        input.Amount,
+		Conditions:    input.Conditions,
+		ExpiresAt:     input.ExpiresAt,
+	}
+
+	v := validator.New()
+	if data.ValidateOffer(v, offer); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Offers.Insert(offer)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateOffer):
+			v.AddError("property", "you already have an open offer on this property")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyOffer(offer)
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"offer": offer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listOffersHandler lists the offers made by or to the authenticated user as a buyer.
+func (app *application) listOffersHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafelist = []string{"-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	offers, metadata, err := app.models.Offers.GetAllForBuyer(app.contextGetUser(r).ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"offers": offers, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listPropertyOffersHandler lists the offers on one of the authenticated user's properties, optionally with
+// a specific status.
+func (app *application) listPropertyOffersHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.ownedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafelist = []string{"-created_at"}
+
+	v.Check(input.Status == "" || validator.In(input.Status, data.OfferPending, data.OfferAccepted, data.OfferRejected, data.OfferCountered, data.OfferWithdrawn, data.OfferDeclined, data.OfferExpired), "status", "invalid status")
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	offers, metadata, err := app.models.Offers.GetAllForProperty(property.ID, input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"offers": offers, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showOfferHandler shows an offer and the history of its negotiation to its buyer or seller.
+func (app *application) showOfferHandler(w http.ResponseWriter, r *http.Request) {
+	offer, ok := app.offer(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := app.models.Offers.GetNegotiation(offer.NegotiationID())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	offer.History = history
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"offer": offer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// acceptOfferHandler accepts an offer made to the authenticated user. The property is put under offer and
+// its other pending offers are declined.
+func (app *application) acceptOfferHandler(w http.ResponseWriter, r *http.Request) {
+	offer, ok := app.offer(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateOfferResponse(v, offer, data.OfferAccepted, app.contextGetUser(r).ID); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	declined, err := app.models.Offers.Accept(offer)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrPropertyUnavailable):
+			v.AddError("property", "is no longer available for offers")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrPropertyAuctioned):
+			v.AddError("property", "has a scheduled auction, which must be cancelled or closed first")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyOffer(offer)
+	for _, other := range declined {
+		app.notifyOffer(other)
+	}
+	app.notifyStatusChange(offer.PropertyID, data.StatusPublished)
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"offer": offer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// rejectOfferHandler rejects an offer made to the authenticated user.
+func (app *application) rejectOfferHandler(w http.ResponseWriter, r *http.Request) {
+	app.respondToOffer(w, r, data.OfferRejected)
+}
+
+// withdrawOfferHandler withdraws an offer made by the authenticated user.
+func (app *application) withdrawOfferHandler(w http.ResponseWriter, r *http.Request) {
+	app.respondToOffer(w, r, data.OfferWithdrawn)
+}
+
+// respondToOffer rejects or withdraws an offer on behalf of the authenticated user and notifies the other party.
+func (app *application) respondToOffer(w http.ResponseWriter, r *http.Request, status string) {
+	offer, ok := app.offer(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateOfferResponse(v, offer, status, app.contextGetUser(r).ID); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.models.Offers.Respond(offer, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyOffer(offer)
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"offer": offer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// counterOfferHandler counters an offer made to the authenticated user with a new offer in the same negotiation.
+func (app *application) counterOfferHandler(w http.ResponseWriter, r *http.Request) {
+	offer, ok := app.offer(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Amount     float64   `json:"amount"`
+		Conditions []string  `json:"conditions"`
+		ExpiresAt  time.Time `json:"expires_at"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	counter := &data.Offer{
+		PropertyID:    offer.PropertyID,
+		PropertyTitle: offer.PropertyTitle,
+		SellerID:      offer.SellerID,
+		BuyerID:       offer.BuyerID,
+		MadeBy:        user.ID,
+		Amount:        input.Amount,
+		Conditions:    input.Conditions,
+		ExpiresAt:     input.ExpiresAt,
+	}
+
+	v := validator.New()
+	data.ValidateOfferResponse(v, offer, data.OfferCountered, user.ID)
+	if data.ValidateOffer(v, counter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Offers.Counter(offer, counter)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyOffer(counter)
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"offer": counter}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// runOfferExpiry periodically expires the offers that have not been responded to in time and notifies the
+// users who made them.
+func (app *application) runOfferExpiry(interval time.Duration) {
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			expired, err := app.models.Offers.ExpireDue()
+			if err != nil {
+				app.logger.Println(err)
+				continue
+			}
+
+			for _, offer := range expired {
+				app.notifyOffer(offer)
+			}
+		}
+	})
+}
+
+// notifyOffer tells the user affected by a new offer or a change of its status, on their event stream and by
+// email: the user an open offer was made to or withdrawn from, the user whose offer was accepted, rejected
+// or expired, and the buyer of an offer declined because another was accepted.
+func (app *application) notifyOffer(offer *data.Offer) {
+	event := offer.Status
+	recipientID := offer.MadeBy
+
+	switch offer.Status {
+	case data.OfferPending:
+		event = "received"
+		if offer.ParentID != nil {
+			event = "countered"
+		}
+		recipientID = offer.Recipient()
+	case data.OfferWithdrawn:
+		recipientID = offer.Recipient()
+	case data.OfferDeclined:
+		recipientID = offer.BuyerID
+	}
+
+	if recipientID == 0 {
+		return
+	}
+
+	app.publish(recipientID, "offer."+event, offer)
+
+	app.background(func() {
+		user, err := app.models.Users.Get(recipientID)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		templateData := map[string]interface{}{
+			"Name":  user.Name,
+			"Event": event,
+			"Offer": offer,
+		}
+
+		err = app.mailer.Send(user.Email, "offer.tmpl", templateData)
+		if err != nil {
+			app.logger.Println(err)
+		}
+	})
+}
+
+// offer fetches the offer identified by the id parameter, sending a 404 Not Found response and returning
+// false if there is none or the authenticated user is neither its buyer nor its seller.
+func (app *application) offer(w http.ResponseWriter, r *http.Request) (*data.Offer, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	offer, err := app.models.Offers.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !offer.IsParty(app.contextGetUser(r).ID) {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return offer, true
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// listOpenHousesHandler lists the upcoming open houses of a published property with the spots left at each.
+func (app *application) listOpenHousesHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.publishedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	openHouses, err := app.models.OpenHouses.GetUpcomingForProperty(property.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"open_houses": openHouses}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createOpenHouseHandler schedules an open house at one of the authenticated user's listings.
+func (app *application) createOpenHouseHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PropertyID int64     `json:"property_id"`
+		StartsAt   time.Time `json:"starts_at"`
+		EndsAt     time.Time `json:"ends_at"`
+		TimeZone   string    `json:"time_zone"`
+		Capacity   int       `json:"capacity"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	property, err := app.models.Properties.Get(input.PropertyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if property.UserID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	openHouse := &data.OpenHouse{
+		PropertyID:    property.ID,
+		PropertyTitle: property.Title,
+		AgentID:       property.UserID,
+		StartsAt:      input.StartsAt,
+		EndsAt:        input.EndsAt,
+		TimeZone:      input.TimeZone,
+		Capacity:      input.Capacity,
+	}
+
+	v := validator.New()
+	if data.ValidateOpenHouse(v, openHouse); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.OpenHouses.Insert(openHouse)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	openHouse.SpotsLeft = openHouse.Capacity
+	openHouse.Local()
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"open_house": openHouse}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// cancelOpenHouseHandler cancels an open house at one of the authenticated user's listings and notifies
+// everyone who responded to it.
+func (app *application) cancelOpenHouseHandler(w http.ResponseWriter, r *http.Request) {
+	openHouse, ok := app.openHouse(w, r)
+	if !ok {
+		return
+	}
+
+	if openHouse.AgentID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	if v.Check(openHouse.Status == data.OpenHouseScheduled, "status", "open house has already been cancelled"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	attendees, err := app.models.OpenHouses.GetAttendees(openHouse.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.OpenHouses.Cancel(openHouse)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	for _, user := range attendees {
+		app.publish(user.ID, "open_house.cancelled", openHouse)
+	}
+
+	app.background(func() {
+		for _, user := range attendees {
+			templateData := map[string]interface{}{
+				"Name":      user.Name,
+				"OpenHouse": openHouse,
+				"When":      openHouse.When(),
+			}
+
+			err := app.mailer.Send(user.Email, "open_house_cancelled.tmpl", templateData)
+			if err != nil {
+				app.logger.Println(err)
+			}
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"open_house": openHouse}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createRSVPHandler responds to an open house on behalf of the authenticated user. The response is
+// confirmed while the open house has capacity for its guests, and waitlisted otherwise. Open houses of
+// listings the user cannot view are not found.
+func (app *application) createRSVPHandler(w http.ResponseWriter, r *http.Request) {
+	openHouse, ok := app.openHouse(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := app.viewableProperty(w, r, openHouse.PropertyID); !ok {
+		return
+	}
+
+	var input struct {
+		Guests int `json:"guests"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rsvp := &data.RSVP{
+		OpenHouseID: openHouse.ID,
+		UserID:      app.contextGetUser(r).ID,
+		Guests:      input.Guests,
+	}
+
+	if rsvp.Guests == 0 {
+		rsvp.Guests = 1
+	}
+
+	v := validator.New()
+	v.Check(openHouse.Status == data.OpenHouseScheduled, "open_house", "has been cancelled")
+	v.Check(openHouse.EndsAt.After(time.Now()), "open_house", "has already ended")
+	v.Check(openHouse.AgentID != rsvp.UserID, "open_house", "must not be at your own listing")
+	v.Check(rsvp.Guests <= openHouse.Capacity, "guests", "must not be more than the capacity of the open house")
+	if data.ValidateRSVP(v, rsvp); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.OpenHouses.InsertRSVP(rsvp)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateRSVP):
+			v.AddError("open_house", "you have already responded to this open house")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.publish(openHouse.AgentID, "open_house.rsvp", rsvp)
+	app.notifyRSVP(openHouse, rsvp)
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"rsvp": rsvp}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// cancelRSVPHandler cancels the authenticated user's response to an open house, confirming waitlisted
+// responses that now fit and notifying their users.
+func (app *application) cancelRSVPHandler(w http.ResponseWriter, r *http.Request) {
+	openHouse, ok := app.openHouse(w, r)
+	if !ok {
+		return
+	}
+
+	userID := app.contextGetUser(r).ID
+
+	promoted, err := app.models.OpenHouses.CancelRSVP(openHouse.ID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.publish(openHouse.AgentID, "open_house.rsvp_cancelled", envelop{"open_house_id": openHouse.ID, "user_id": userID})
+	for _, rsvp := range promoted {
+		app.notifyRSVP(openHouse, rsvp)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "rsvp successfully cancelled"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// notifyRSVP tells a user whether their response to an open house is confirmed or waitlisted, on their
+// event stream and by email.
+func (app *application) notifyRSVP(openHouse *data.OpenHouse, rsvp *data.RSVP) {
+	app.publish(rsvp.UserID, "open_house.rsvp_"+rsvp.Status, rsvp)
+
+	app.background(func() {
+		user, err := app.models.Users.Get(rsvp.UserID)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		templateData := map[string]interface{}{
+			"Name":      user.Name,
+			"OpenHouse": openHouse,
+			"RSVP":      rsvp,
+			"When":      openHouse.When(),
+		}
+
+		err = app.mailer.Send(user.Email, "open_house_rsvp.tmpl", templateData)
+		if err != nil {
+			app.logger.Println(err)
+		}
+	})
+}
+
+// openHouse fetches the open house identified by the id parameter, sending a 404 Not Found response and
+// returning false if there is none.
+func (app *application) openHouse(w http.ResponseWriter, r *http.Request) (*data.OpenHouse, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	openHouse, err := app.models.OpenHouses.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return openHouse, true
+}
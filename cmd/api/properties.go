@@ -4,11 +4,87 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/emzola/realty/internal/data"
 	"github.com/emzola/realty/internal/validator"
 )
 
+// listPropertiesHandler returns a paginated, filtered list of properties. When the facets
+// parameter is provided, per-facet value counts are returned alongside the list.
+func (app *application) listPropertiesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.PropertyFilters
+		Facets       []string
+		PriceBuckets []float64
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.PropertyFilters = app.readPropertyFilters(qs, v)
+	input.PropertyFilters.Status = data.StatusPublished
+	input.Facets = app.readCSV(qs, "facets", []string{})
+	input.PriceBuckets = app.readFloatCSV(qs, "price_buckets", app.config.facets.priceBuckets, v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "title", "price", "created_at", "-id", "-title", "-price", "-created_at"}
+
+	data.ValidatePropertyFilters(v, input.PropertyFilters)
+	data.ValidateFacets(v, input.Facets, input.PriceBuckets)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	properties, metadata, err := app.models.Properties.GetAll(input.PropertyFilters, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.setFavouriteFlags(r, properties...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelop{"properties": properties, "metadata": metadata}
+
+	if len(input.Facets) > 0 {
+		facets, err := app.models.Properties.Facets(input.PropertyFilters, input.Facets, input.PriceBuckets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		env["facets"] = facets
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readPropertyFilters reads the property listing filters from the query string.
+func (app *application) readPropertyFilters(qs url.Values, v *validator.Validator) data.PropertyFilters {
+	return data.PropertyFilters{
+		City:      app.readString(qs, "city", ""),
+		Location:  app.readString(qs, "location", ""),
+		Type:      app.readCSV(qs, "type", []string{}),
+		Category:  app.readCSV(qs, "category", []string{}),
+		Amenities: app.readCSV(qs, "amenities", []string{}),
+		MinPrice:  app.readFloat(qs, "min_price", 0, v),
+		MaxPrice:  app.readFloat(qs, "max_price", 0, v),
+		OpenHouse: app.readString(qs, "open_house", ""),
+		CheckIn:   app.readString(qs, "check_in", ""),
+		CheckOut:  app.readString(qs, "check_out", ""),
+	}
+}
+
 // showPropertyHandler shows property details.
 func (app *application) showPropertyHandler(w http.ResponseWriter, r *http.Request) {
 	// extract ID param
@@ -29,6 +105,25 @@ func (app *application) showPropertyHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !app.canViewProperty(r, property) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	app.recordView(r, property.ID)
+
+	err = app.setFavouriteFlags(r, property)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.setPropertyAgent(property)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	err = app.writeJSON(w, http.StatusOK, envelop{"property": property}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -53,6 +148,7 @@ func (app *application) createPropertyHandler(w http.ResponseWriter, r *http.Req
 		Currency    []string          `json:"currency"`
 		Nearby      data.Nearby				`json:"nearby,omitempty"`
 		Amenities   []string          `json:"amenities,omitempty"`
+		Status      *string           `json:"status,omitempty"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -76,16 +172,38 @@ func (app *application) createPropertyHandler(w http.ResponseWriter, r *http.Req
 		Currency:    input.Currency,
 		Nearby:      input.Nearby,
 		Amenities:   input.Amenities,
+		Status:      data.StatusPublished,
+		UserID:      app.contextGetUser(r).ID,
+	}
+
+	if input.Status != nil {
+		property.Status = *input.Status
 	}
 
 	// Validate the property record, sending the client a 422 Unprocessable Entity
 	// response if any checks fail
 	v := validator.New()
+	data.ValidatePropertyStatusChange(v, "", property.Status)
+
+	// Only agents with a verified licence can publish listings in markets that require it
+	err = app.checkAgentVerified(v, property, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	if data.ValidateProperty(v, property); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	// Suspicious listings, and listings in markets that require review, are held for moderation
+	item, err := app.reviewListing(property, true, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Insert property record into properties DB table
 	err = app.models.Properties.Insert(property)
 	if err != nil {
@@ -93,6 +211,15 @@ func (app *application) createPropertyHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if item != nil {
+		item.ItemID = property.ID
+		err = app.holdForModeration(item)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
 	// Set location header
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/properties/%d", property.ID))
@@ -124,6 +251,17 @@ func(app *application) updatePropertyHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// Only the owner of a property, or an administrator for listings without an owner, may update it
+	canManage, err := app.canManageProperty(r, property)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !canManage {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
 	// Decode JSON into this input struct instead of directly on the property struct.
 	// That way, the client does not have to provide ID and Version fields
 	var input struct {
@@ -140,6 +278,7 @@ func(app *application) updatePropertyHandler(w http.ResponseWriter, r *http.Requ
 		Currency    []string          `json:"currency"`
 		Nearby      data.Nearby				`json:"nearby,omitempty"`
 		Amenities   []string          `json:"amenities,omitempty"`
+		Status      *string           `json:"status,omitempty"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -148,6 +287,10 @@ func(app *application) updatePropertyHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// Keep the previous price and status to notify users who favourited the property of changes
+	oldPrice, oldStatus := property.Price, property.Status
+	oldText, oldCity := property.Title+"\n"+property.Description, property.City
+
 	// copy data acrosss from input struct to property record
 	if input.Title != nil {
 		property.Title = *input.Title
@@ -185,15 +328,38 @@ func(app *application) updatePropertyHandler(w http.ResponseWriter, r *http.Requ
 	if input.Amenities != nil {
 		property.Amenities = input.Amenities
 	}
+	if input.Status != nil {
+		property.Status = *input.Status
+	}
 
 	// Validate the updated property record, sending the client a 422 Unprocessable Entity
 	// response if any checks fail
 	v := validator.New()
+	data.ValidatePropertyStatusChange(v, oldStatus, property.Status)
+
+	// Only agents with a verified licence can publish listings in markets that require it
+	publishing := oldStatus != data.StatusPublished || property.City != oldCity
+	err = app.checkAgentVerified(v, property, publishing)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	if data.ValidateProperty(v, property); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	// Screen a changed title or description, or a listing being published, and hold the listing for
+	// review if it fails screening, its last review is pending or was rejected, or it is being published
+	// in a market that requires review
+	textChanged := property.Title+"\n"+property.Description != oldText
+	item, err := app.reviewListing(property, textChanged, publishing)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Pass the updated property record to the Update() method to update the database
 	err = app.models.Properties.Update(property)
 	if err != nil {  
@@ -206,6 +372,17 @@ func(app *application) updatePropertyHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if item != nil {
+		item.ItemID = property.ID
+		err = app.holdForModeration(item)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	app.notifyFavouriteChange(property, oldPrice, oldStatus)
+
 	// Write the updated property record in a JSON response
 	err = app.writeJSON(w, http.StatusOK, envelop{"property": property}, nil)
 	if err != nil {
@@ -223,6 +400,28 @@ func (app *application) deletePropertyHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	property, err := app.models.Properties.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Only the owner of a property, or an administrator for listings without an owner, may delete it
+	canManage, err := app.canManageProperty(r, property)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !canManage {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
 	err = app.models.Properties.Delete(id)
 	if err != nil {
 		switch {
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/storage"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// createRentalApplicationHandler submits the authenticated user's application to rent a published property.
+func (app *application) createRentalApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.publishedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		EmploymentStatus string  `json:"employment_status"`
+		Employer         string  `json:"employer"`
+		JobTitle         string  `json:"job_title"`
+		AnnualIncome     float64 `json:"annual_income"`
+		MoveInDate       string  `json:"move_in_date"`
+		Occupants        int     `json:"occupants"`
+		Message          string  `json:"message"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	application := &data.RentalApplication{
+		PropertyID:       property.ID,
+		PropertyTitle:    property.Title,
+		LandlordID:       property.UserID,
+		ApplicantID:      app.contextGetUser(r).ID,
+		EmploymentStatus: input.EmploymentStatus,
+		Employer:         input.Employer,
+		JobTitle:         input.JobTitle,
+		AnnualIncome:     input.AnnualIncome,
+		MoveInDate:       input.MoveInDate,
+		Occupants:        input.Occupants,
+		Message:          input.Message,
+	}
+
+	v := validator.New()
+	if data.ValidateRentalApplication(v, application); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.RentalApplications.Insert(application)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateApplication):
+			v.AddError("property", "you already have an application for this property")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyRentalApplication(application)
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"application": application}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listRentalApplicationsHandler lists the authenticated user's rental applications.
+func (app *application) listRentalApplicationsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafelist = []string{"-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	applications, metadata, err := app.models.RentalApplications.GetAllForApplicant(app.contextGetUser(r).ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"applications": applications, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listPropertyApplicationsHandler lists the applications to rent one of the authenticated user's properties,
+// optionally with a specific status.
+func (app *application) listPropertyApplicationsHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.ownedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "created_at"
+	input.Filters.SortSafelist = []string{"created_at"}
+
+	v.Check(input.Status == "" || validator.In(input.Status, data.ApplicationSubmitted, data.ApplicationShortlisted, data.ApplicationApproved, data.ApplicationDeclined, data.ApplicationWithdrawn), "status", "invalid status")
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	applications, metadata, err := app.models.RentalApplications.GetAllForProperty(property.ID, input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"applications": applications, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showRentalApplicationHandler shows a rental application and its documents to its applicant or landlord.
+func (app *application) showRentalApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	application, ok := app.rentalApplication(w, r)
+	if !ok {
+		return
+	}
+
+	documents, err := app.models.RentalApplications.GetDocuments(application.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	application.Documents = documents
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"application": application}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// shortlistRentalApplicationHandler shortlists an application to rent one of the authenticated user's properties.
+func (app *application) shortlistRentalApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRentalApplicationStatus(w, r, data.ApplicationShortlisted)
+}
+
+// approveRentalApplicationHandler approves an application to rent one of the authenticated user's properties.
+func (app *application) approveRentalApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRentalApplicationStatus(w, r, data.ApplicationApproved)
+}
+
+// declineRentalApplicationHandler declines an application to rent one of the authenticated user's properties.
+func (app *application) declineRentalApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRentalApplicationStatus(w, r, data.ApplicationDeclined)
+}
+
+// withdrawRentalApplicationHandler withdraws one of the authenticated user's rental applications.
+func (app *application) withdrawRentalApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRentalApplicationStatus(w, r, data.ApplicationWithdrawn)
+}
+
+// changeRentalApplicationStatus moves a rental application to a new status on behalf of its landlord or
+// applicant, and notifies the other party.
+func (app *application) changeRentalApplicationStatus(w http.ResponseWriter, r *http.Request, status string) {
+	application, ok := app.rentalApplication(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	byApplicant := application.ApplicantID == app.contextGetUser(r).ID
+
+	v := validator.New()
+	if data.ValidateApplicationTransition(v, application, status, input.Reason, byApplicant); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.RentalApplications.UpdateStatus(application, status, input.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyRentalApplication(application)
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"application": application}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// uploadApplicationDocumentHandler uploads a document, such as a payslip or ID, with one of the authenticated
+// user's open rental applications. The document is sent as the file field of a multipart form, with its
+// kind in the kind field, and kept in private storage.
+func (app *application) uploadApplicationDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	application, ok := app.rentalApplication(w, r)
+	if !ok {
+		return
+	}
+
+	if application.ApplicantID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	if v.Check(application.IsOpen(), "application", "documents cannot be added to a closed application"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	count, err := app.models.RentalApplications.CountDocuments(application.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if v.Check(count < data.MaxApplicationDocuments, "file", fmt.Sprintf("must not be more than %d documents", data.MaxApplicationDocuments)); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	maxBytes := app.config.documents.maxBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1_048_576)
+
+	err = r.ParseMultipartForm(1_048_576)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("body must be a multipart form of at most %d bytes", maxBytes))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil && !errors.Is(err, http.ErrMissingFile) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	document := &data.ApplicationDocument{
+		ApplicationID: application.ID,
+		Kind:          r.FormValue("kind"),
+	}
+
+	// The content type is sniffed from the file rather than trusted from the client.
+	if file != nil {
+		defer file.Close()
+
+		document.Filename = filepath.Base(header.Filename)
+		document.ContentType, err = sniffContentType(file)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if data.ValidateApplicationDocument(v, document); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	document.StorageKey, err = storage.NewKey(fmt.Sprintf("applications/%d", application.ID))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	document.Size, err = app.storage.Put(r.Context(), document.StorageKey, file, maxBytes)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrTooLarge):
+			v.AddError("file", fmt.Sprintf("must not be larger than %d bytes", maxBytes))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.RentalApplications.InsertDocument(document)
+	if err != nil {
+		app.deleteStoredFile(document.StorageKey)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.publish(application.LandlordID, "rental_application.document_added", envelop{"application_id": application.ID, "document": document})
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"document": document}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showApplicationDocumentHandler downloads a document of a rental application. Only the applicant and the
+// landlord of the listing applied for can download it.
+func (app *application) showApplicationDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	document, application, ok := app.applicationDocument(w, r)
+	if !ok {
+		return
+	}
+
+	if !application.CanView(app.contextGetUser(r).ID) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	file, err := app.storage.Open(r.Context(), document.StorageKey)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", document.ContentType)
+	w.Header().Set("Content-Length", fmt.Sprint(document.Size))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", strings.ReplaceAll(document.Filename, `"`, "")))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// deleteApplicationDocumentHandler deletes a document from one of the authenticated user's open rental applications.
+func (app *application) deleteApplicationDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	document, application, ok := app.applicationDocument(w, r)
+	if !ok {
+		return
+	}
+
+	if application.ApplicantID != app.contextGetUser(r).ID {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	if v.Check(application.IsOpen(), "application", "documents cannot be removed from a closed application"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.models.RentalApplications.DeleteDocument(document.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deleteStoredFile(document.StorageKey)
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": "document successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// notifyRentalApplication tells the other party about a new rental application or a change of its status,
+// on their event stream and by email: the landlord when the applicant submits or withdraws it, and the
+// applicant otherwise.
+func (app *application) notifyRentalApplication(application *data.RentalApplication) {
+	recipientID := application.ApplicantID
+	if validator.In(application.Status, data.ApplicationSubmitted, data.ApplicationWithdrawn) {
+		recipientID = application.LandlordID
+	}
+
+	if recipientID == 0 {
+		return
+	}
+
+	app.publish(recipientID, "rental_application."+application.Status, application)
+
+	app.background(func() {
+		user, err := app.models.Users.Get(recipientID)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		templateData := map[string]interface{}{
+			"Name":        user.Name,
+			"Application": application,
+		}
+
+		err = app.mailer.Send(user.Email, "rental_application.tmpl", templateData)
+		if err != nil {
+			app.logger.Println(err)
+		}
+	})
+}
+
+// rentalApplication fetches the rental application identified by the id parameter, sending a 404 Not Found
+// response and returning false if there is none or the authenticated user is neither its applicant nor
+// its landlord.
+func (app *application) rentalApplication(w http.ResponseWriter, r *http.Request) (*data.RentalApplication, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	application, err := app.models.RentalApplications.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !application.CanView(app.contextGetUser(r).ID) {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return application, true
+}
+
+// applicationDocument fetches the document identified by the id parameter and its application, sending a
+// 404 Not Found response and returning false if there is none.
+func (app *application) applicationDocument(w http.ResponseWriter, r *http.Request) (*data.ApplicationDocument, *data.RentalApplication, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, nil, false
+	}
+
+	document, err := app.models.RentalApplications.GetDocument(id)
+	if err == nil {
+		var application *data.RentalApplication
+		application, err = app.models.RentalApplications.Get(document.ApplicationID)
+		if err == nil {
+			return document, application, true
+		}
+	}
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+	return nil, nil, false
+}
+
+// deleteStoredFile removes a file from storage in the background, logging failures.
+func (app *application) deleteStoredFile(key string) {
+	app.background(func() {
+		err := app.storage.Delete(context.Background(), key)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			app.logger.Println(err)
+		}
+	})
+}
+
+// sniffContentType detects the content type of an uploaded file from its first 512 bytes and rewinds it.
+func sniffContentType(file io.ReadSeeker) (string, error) {
+	buf := make([]byte, 512)
+
+	n, err := io.ReadFull(file, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	contentType := http.DetectContentType(buf[:n])
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	return contentType, nil
+}
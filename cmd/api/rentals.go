@@ -0,0 +1,351 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// rentalCalendarDays is the number of days of availability shown when no end date is requested.
+const rentalCalendarDays = 90
+
+// showRentalQuoteHandler prices a stay at a published short-let property, checking its minimum stay,
+// maximum number of guests and availability.
+func (app *application) showRentalQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.publishedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	checkIn := app.readString(qs, "check_in", "")
+	checkOut := app.readString(qs, "check_out", "")
+	guests := app.readInt(qs, "guests", 1, v)
+
+	if data.ValidateStay(v, checkIn, checkOut, guests); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rate, err := app.models.Rentals.GetRate(property.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	seasons, err := app.models.Rentals.GetSeasons(property.ID, checkIn)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	quote := data.NewRentalQuote(rate, seasons, checkIn, checkOut, guests)
+	if len(property.Currency) > 0 {
+		quote.Currency = property.Currency[0]
+	}
+
+	v.Check(quote.Nights >= quote.MinNights, "check_out", fmt.Sprintf("must be at least %d nights after check_in", quote.MinNights))
+	v.Check(guests <= rate.MaxGuests, "guests", fmt.Sprintf("must not be more than %d", rate.MaxGuests))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	available, err := app.models.Rentals.IsAvailable(property.ID, checkIn, checkOut)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !available {
+		v.AddError("check_in", "the property is not available for these dates")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"quote": quote}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showRentalAvailabilityHandler shows the blocked and booked date ranges of a published short-let property
+// between two dates, from today for the next 90 days by default.
+func (app *application) showRentalAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.publishedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	from := app.readString(qs, "from", now.Format("2006-01-02"))
+	to := app.readString(qs, "to", now.AddDate(0, 0, rentalCalendarDays).Format("2006-01-02"))
+
+	if data.ValidateCalendarRange(v, from, to); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rate, err := app.models.Rentals.GetRate(property.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	blocks, err := app.models.Rentals.GetBlocks(property.ID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Notes are private to the owner.
+	for _, block := range blocks {
+		block.Note = ""
+	}
+
+	availability := envelop{
+		"property_id": property.ID,
+		"from":        from,
+		"to":          to,
+		"min_nights":  rate.MinNights,
+		"max_guests":  rate.MaxGuests,
+		"unavailable": blocks,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"availability": availability}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showRentalHandler shows the rate, upcoming seasons and blocks of one of the authenticated user's
+// properties. The rate is null until the property is offered as a short-let.
+func (app *application) showRentalHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.ownedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	rate, err := app.models.Rentals.GetRate(property.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	from := now.Format("2006-01-02")
+
+	seasons, err := app.models.Rentals.GetSeasons(property.ID, from)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	blocks, err := app.models.Rentals.GetBlocks(property.ID, from, now.AddDate(1, 0, 0).Format("2006-01-02"))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"rate": rate, "seasons": seasons, "blocks": blocks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// setRentalRateHandler sets the base nightly rate, fees and stay rules of one of the authenticated user's
+// properties, offering it as a short-let. Sending the version of the current rate guards against
+// overwriting a concurrent change.
+func (app *application) setRentalRateHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.ownedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		NightlyRate       float64 `json:"nightly_rate"`
+		MinNights         int     `json:"min_nights"`
+		MaxGuests         int     `json:"max_guests"`
+		IncludedGuests    int     `json:"included_guests"`
+		ExtraGuestFee     float64 `json:"extra_guest_fee"`
+		CleaningFee       float64 `json:"cleaning_fee"`
+		ServiceFeePercent float64 `json:"service_fee_percent"`
+		Version           int32   `json:"version"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rate := &data.RentalRate{
+		PropertyID:        property.ID,
+		NightlyRate:       input.NightlyRate,
+		MinNights:         input.MinNights,
+		MaxGuests:         input.MaxGuests,
+		IncludedGuests:    input.IncludedGuests,
+		ExtraGuestFee:     input.ExtraGuestFee,
+		CleaningFee:       input.CleaningFee,
+		ServiceFeePercent: input.ServiceFeePercent,
+		Version:           input.Version,
+	}
+
+	if rate.MinNights == 0 {
+		rate.MinNights = 1
+	}
+	if rate.IncludedGuests == 0 {
+		rate.IncludedGuests = rate.MaxGuests
+	}
+
+	v := validator.New()
+	if data.ValidateRentalRate(v, rate); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Rentals.SetRate(rate)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"rate": rate}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createSeasonHandler adds a seasonal rate to one of the authenticated user's properties.
+func (app *application) createSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.ownedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name        string  `json:"name"`
+		StartDate   string  `json:"start_date"`
+		EndDate     string  `json:"end_date"`
+		NightlyRate float64 `json:"nightly_rate"`
+		MinNights   int     `json:"min_nights"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	season := &data.Season{
+		PropertyID:  property.ID,
+		Name:        input.Name,
+		StartDate:   input.StartDate,
+		EndDate:     input.EndDate,
+		NightlyRate: input.NightlyRate,
+		MinNights:   input.MinNights,
+	}
+
+	v := validator.New()
+	if data.ValidateSeason(v, season); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Rentals.InsertSeason(season)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"season": season}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteSeasonHandler deletes a seasonal rate of one of the authenticated user's properties.
+func (app *application) deleteSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	app.deleteAvailability(w, r, app.models.Rentals.DeleteSeason, "season successfully deleted")
+}
+
+// createRentalBlockHandler blocks or marks as booked a range of nights at one of the authenticated user's
+// properties.
+func (app *application) createRentalBlockHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.ownedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+		Kind      string `json:"kind"`
+		Note      string `json:"note"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	block := &data.RentalBlock{
+		PropertyID: property.ID,
+		StartDate:  input.StartDate,
+		EndDate:    input.EndDate,
+		Kind:       input.Kind,
+		Note:       input.Note,
+	}
+
+	if block.Kind == "" {
+		block.Kind = data.BlockBlocked
+	}
+
+	v := validator.New()
+	if data.ValidateRentalBlock(v, block); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Rentals.InsertBlock(block)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"block": block}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteRentalBlockHandler deletes a blocked or booked range of nights of one of the authenticated user's properties.
+func (app *application) deleteRentalBlockHandler(w http.ResponseWriter, r *http.Request) {
+	app.deleteAvailability(w, r, app.models.Rentals.DeleteBlock, "block successfully deleted")
+}
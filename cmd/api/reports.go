@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// createReportHandler reports a published listing as a scam, no longer available or otherwise inappropriate.
+// Once enough distinct signed-in users have reported it, the listing is taken down pending review by a
+// moderator. Anonymous reports are only shown to moderators.
+func (app *application) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.publishedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Reason  string `json:"reason"`
+		Comment string `json:"comment"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	report := &data.ListingReport{
+		PropertyID: property.ID,
+		Reporter:   app.reporterID(r),
+		UserID:     app.contextGetUser(r).ID,
+		Reason:     input.Reason,
+		Comment:    input.Comment,
+	}
+
+	v := validator.New()
+	if data.ValidateListingReport(v, report); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reports.Insert(report)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if report.UserID != 0 {
+		err = app.checkReportThreshold(property)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// reporterID identifies the user making a report, or the client IP address of anonymous users, hashed so
+// that addresses are not stored.
+func (app *application) reporterID(r *http.Request) string {
+	user := app.contextGetUser(r)
+	if !user.IsAnonymous() {
+		return fmt.Sprintf("user:%d", user.ID)
+	}
+
+	hash := sha256.Sum256([]byte(app.clientIP(r)))
+	return "ip:" + hex.EncodeToString(hash[:16])
+}
+
+// checkReportThreshold takes a published listing down for review once the number of distinct signed-in users
+// who have reported it since its last review reaches the configured threshold. Anonymous reporters are
+// identified only by their IP address, which is too easily changed for them to count.
+func (app *application) checkReportThreshold(property *data.Property) error {
+	summary, err := app.models.Reports.GetSummary(property.ID)
+	if err != nil {
+		return err
+	}
+
+	if summary.UserReporters < app.config.reports.threshold {
+		return nil
+	}
+
+	oldStatus := property.Status
+	property.Status = data.StatusPendingReview
+
+	err = app.models.Properties.Update(property)
+	if err != nil {
+		switch {
+		// The listing has been changed since it was fetched, possibly by a concurrent report taking it down.
+		case errors.Is(err, data.ErrEditConflict):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	app.notifyFavouriteChange(property, property.Price, oldStatus)
+
+	err = app.holdForModeration(&data.ModerationItem{
+		ItemType: data.ModerationItemProperty,
+		ItemID:   property.ID,
+		Score:    float64(summary.Reporters),
+		Reasons:  []string{summary.String()},
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.models.AuditLog.Insert(&data.AuditEntry{
+		Action:     "reports.threshold_reached",
+		EntityType: data.ModerationItemProperty,
+		EntityID:   property.ID,
+		Details: data.AuditDetails{
+			"reporters":      summary.Reporters,
+			"user_reporters": summary.UserReporters,
+			"reasons":        summary.Reasons,
+		},
+	})
+}
+
+// listReportsHandler lists the reported listings with their reports aggregated, most reported first.
+func (app *application) listReportsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-reporters"
+	input.Filters.SortSafelist = []string{"-reporters"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	summaries, metadata, err := app.models.Reports.GetAllSummaries(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"reports": summaries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
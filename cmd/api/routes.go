@@ -3,21 +3,154 @@ package main
 import (
 	"net/http"
 
+	"github.com/emzola/realty/internal/data"
 	"github.com/julienschmidt/httprouter"
 )
 
-// routes returns a router of all api endpoints.
-func (app *application) routes() *httprouter.Router {
+// routes returns a handler serving all api endpoints.
+func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/properties/:id", app.showPropertyHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/account/properties/create", app.createPropertyHandler)
-	router.HandlerFunc(http.MethodPatch, "/v1/account/properties/:id", app.updatePropertyHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/account/properties/:id", app.deletePropertyHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/locations/autocomplete", app.autocompleteLocationsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/properties", app.listPropertiesHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/properties/:id", app.staticSegments(app.showPropertyHandler, map[string]http.HandlerFunc{
+		"compare": app.comparePropertiesHandler,
+	}))
+	router.HandlerFunc(http.MethodGet, "/v1/properties/:id/similar", app.listSimilarPropertiesHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/properties/:id/inquiries", app.rateLimitAnonymous(app.createInquiryHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/properties/:id/conversations", app.requireAuthenticatedUser(app.startConversationHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/properties/:id/viewing-slots", app.listViewingSlotsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/properties/:id/viewings", app.requireAuthenticatedUser(app.createViewingHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/properties/:id/open-houses", app.listOpenHousesHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/properties/:id/quote", app.showRentalQuoteHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/properties/:id/availability", app.showRentalAvailabilityHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/properties/:id/applications", app.requireAuthenticatedUser(app.createRentalApplicationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/properties/:id/offers", app.requireAuthenticatedUser(app.createOfferHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/properties/:id/auction", app.showPropertyAuctionHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/auctions/:id", app.showAuctionHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/auctions/:id/bids", app.requireAuthenticatedUser(app.createBidHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/open-houses/:id/rsvp", app.requireAuthenticatedUser(app.createRSVPHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/open-houses/:id/rsvp", app.requireAuthenticatedUser(app.cancelRSVPHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/properties/:id/reports", app.rateLimitAnonymous(app.createReportHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/valuations", app.createValuationHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/stats/market", app.showMarketStatsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/account/properties/create", app.requireAuthenticatedUser(app.createPropertyHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/account/properties/:id", app.requireAuthenticatedUser(app.updatePropertyHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/properties/:id", app.requireAuthenticatedUser(app.deletePropertyHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/properties/:id/analytics", app.requireAuthenticatedUser(app.showPropertyAnalyticsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/properties/:id/offers", app.requireAuthenticatedUser(app.listPropertyOffersHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/open-houses", app.requireAuthenticatedUser(app.createOpenHouseHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/open-houses/:id", app.requireAuthenticatedUser(app.cancelOpenHouseHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/auctions", app.requireAuthenticatedUser(app.createAuctionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/auctions/:id", app.requireAuthenticatedUser(app.showAccountAuctionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/auctions/:id", app.requireAuthenticatedUser(app.cancelAuctionHandler))
 
-	return router
+	router.HandlerFunc(http.MethodGet, "/v1/account/saved-searches", app.requireAuthenticatedUser(app.listSavedSearchesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/saved-searches", app.requireAuthenticatedUser(app.createSavedSearchHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/saved-searches/:id", app.requireAuthenticatedUser(app.deleteSavedSearchHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/account/favourites", app.requireAuthenticatedUser(app.listFavouritesHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/account/favourites/:id", app.requireAuthenticatedUser(app.addFavouriteHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/favourites/:id", app.requireAuthenticatedUser(app.removeFavouriteHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/account/inquiries", app.requireAuthenticatedUser(app.listInquiriesHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/account/inquiries/:id", app.requireAuthenticatedUser(app.updateInquiryHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/account/conversations", app.requireAuthenticatedUser(app.listConversationsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/conversations/:id", app.requireAuthenticatedUser(app.showConversationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/conversations/:id/messages", app.requireAuthenticatedUser(app.createMessageHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/account/availability", app.requireAuthenticatedUser(app.listAvailabilityHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/availability/rules", app.requireAuthenticatedUser(app.createAvailabilityRuleHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/availability/rules/:id", app.requireAuthenticatedUser(app.deleteAvailabilityRuleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/availability/blackouts", app.requireAuthenticatedUser(app.createBlackoutHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/availability/blackouts/:id", app.requireAuthenticatedUser(app.deleteBlackoutHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/account/rentals/:id", app.requireAuthenticatedUser(app.showRentalHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/account/rentals/:id/rate", app.requireAuthenticatedUser(app.setRentalRateHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/rentals/:id/seasons", app.requireAuthenticatedUser(app.createSeasonHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/rentals/:id/blocks", app.requireAuthenticatedUser(app.createRentalBlockHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/rentals/:id/feeds", app.requireAuthenticatedUser(app.listCalendarFeedsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/rentals/:id/feeds", app.requireAuthenticatedUser(app.createCalendarFeedHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/rental-feeds/:id", app.requireAuthenticatedUser(app.deleteCalendarFeedHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/rental-seasons/:id", app.requireAuthenticatedUser(app.deleteSeasonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/rental-blocks/:id", app.requireAuthenticatedUser(app.deleteRentalBlockHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/account/rentals/:id/applications", app.requireAuthenticatedUser(app.listPropertyApplicationsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/applications", app.requireAuthenticatedUser(app.listRentalApplicationsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/applications/:id", app.requireAuthenticatedUser(app.showRentalApplicationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/applications/:id/shortlist", app.requireAuthenticatedUser(app.shortlistRentalApplicationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/applications/:id/approve", app.requireAuthenticatedUser(app.approveRentalApplicationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/applications/:id/decline", app.requireAuthenticatedUser(app.declineRentalApplicationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/applications/:id/withdraw", app.requireAuthenticatedUser(app.withdrawRentalApplicationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/applications/:id/documents", app.requireAuthenticatedUser(app.uploadApplicationDocumentHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/application-documents/:id", app.requireAuthenticatedUser(app.showApplicationDocumentHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/application-documents/:id", app.requireAuthenticatedUser(app.deleteApplicationDocumentHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/account/offers", app.requireAuthenticatedUser(app.listOffersHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/offers/:id", app.requireAuthenticatedUser(app.showOfferHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/offers/:id/accept", app.requireAuthenticatedUser(app.acceptOfferHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/offers/:id/reject", app.requireAuthenticatedUser(app.rejectOfferHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/offers/:id/counter", app.requireAuthenticatedUser(app.counterOfferHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/offers/:id/withdraw", app.requireAuthenticatedUser(app.withdrawOfferHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/agents/:id", app.showAgentHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/agencies/:id", app.showAgencyHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/account/agent-profile", app.requireAuthenticatedUser(app.showAgentProfileHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/account/agent-profile", app.requireAuthenticatedUser(app.updateAgentProfileHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/agency", app.requireAuthenticatedUser(app.createAgencyHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/agency", app.requireAuthenticatedUser(app.showAccountAgencyHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/account/agency", app.requireAuthenticatedUser(app.updateAgencyHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/agency/invitations", app.requireAuthenticatedUser(app.createAgencyInvitationHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/agency-invitations", app.requireAuthenticatedUser(app.listAgencyInvitationsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/agency-invitations/:id/accept", app.requireAuthenticatedUser(app.acceptAgencyInvitationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/agency-invitations/:id/decline", app.requireAuthenticatedUser(app.declineAgencyInvitationHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/agency-invitations/:id", app.requireAuthenticatedUser(app.revokeAgencyInvitationHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/account/agency-members/:id", app.requireAuthenticatedUser(app.removeAgencyMemberHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/account/agency-listings/:id", app.requireAuthenticatedUser(app.reassignAgencyListingHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/account/verifications", app.requireAuthenticatedUser(app.createVerificationHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/account/verifications", app.requireAuthenticatedUser(app.listVerificationsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/account/viewings", app.requireAuthenticatedUser(app.listViewingsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/account/viewings/:id/cancel", app.requireAuthenticatedUser(app.cancelViewingHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/admin/moderation", app.requirePermission(data.PermissionModerationReview, app.listModerationItemsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/moderation/:id/approve", app.requirePermission(data.PermissionModerationReview, app.approveModerationItemHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/moderation/:id/reject", app.requirePermission(data.PermissionModerationReview, app.rejectModerationItemHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/reports", app.requirePermission(data.PermissionModerationReview, app.listReportsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/audit-log", app.requirePermission(data.PermissionModerationReview, app.listAuditLogHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/verifications", app.requirePermission(data.PermissionAgentsVerify, app.listPendingVerificationsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/verifications/:id", app.requirePermission(data.PermissionAgentsVerify, app.showVerificationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/verifications/:id/approve", app.requirePermission(data.PermissionAgentsVerify, app.approveVerificationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/verifications/:id/reject", app.requirePermission(data.PermissionAgentsVerify, app.rejectVerificationHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/verification-documents/:id", app.requirePermission(data.PermissionAgentsVerify, app.showVerificationDocumentHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/account/calendar-token", app.requireAuthenticatedUser(app.createCalendarTokenHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/calendar/:token", app.showCalendarHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/stream", app.streamHandler)
+
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+
+	return app.authenticate(router)
+}
+
+// staticSegments returns a handler for a route ending in an :id wildcard that dispatches to a different
+// handler when the wildcard holds one of the static segments. httprouter does not allow a static path
+// such as /v1/properties/compare to be registered alongside /v1/properties/:id.
+func (app *application) staticSegments(next http.HandlerFunc, segments map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		if handler, ok := segments[params.ByName("id")]; ok {
+			handler(w, r)
+			return
+		}
+		next(w, r)
+	}
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/screening"
+)
+
+// screenProperty runs the title and description of a property through the screening pipeline.
+func (app *application) screenProperty(property *data.Property) (screening.Result, error) {
+	return app.screener.Screen(screening.Content{
+		Kind:   screening.KindProperty,
+		Text:   property.Title + "\n" + property.Description,
+		UserID: property.UserID,
+	})
+}
+
+// screenInquiry runs the message and email address of an inquiry through the screening pipeline.
+func (app *application) screenInquiry(inquiry *data.Inquiry) (screening.Result, error) {
+	return app.screener.Screen(screening.Content{
+		Kind:   screening.KindInquiry,
+		Text:   inquiry.Message,
+		Email:  inquiry.Email,
+		UserID: inquiry.UserID,
+	})
+}
+
+// requiresReview reports whether listings in a city must be reviewed by a moderator before they are published.
+func (app *application) requiresReview(city string) bool {
+	return cityIn(app.config.moderation.reviewCities, city)
+}
+
+// reviewListing decides whether a listing must be reviewed before it can go live: when the screening
+// pipeline holds its title or description, which are screened whenever they change or the listing is being
+// published, when it is being published in a market that requires review, or when it is being published
+// while its last review is pending or was rejected. The listing's status is then set to pending_review and
+// the moderation item to queue once the listing has been saved is returned. Otherwise it returns nil.
+func (app *application) reviewListing(property *data.Property, textChanged, publishing bool) (*data.ModerationItem, error) {
+	var item *data.ModerationItem
+
+	publishing = publishing && property.Status == data.StatusPublished
+
+	if textChanged || publishing {
+		result, err := app.screenProperty(property)
+		if err != nil {
+			return nil, err
+		}
+		if result.Held {
+			item = &data.ModerationItem{ItemType: data.ModerationItemProperty, Score: result.Score, Reasons: result.Reasons()}
+		}
+	}
+
+	var reasons []string
+
+	if publishing && property.ID != 0 {
+		status, err := app.models.Moderation.LatestStatus(data.ModerationItemProperty, property.ID)
+		if err != nil {
+			return nil, err
+		}
+		switch status {
+		case data.ModerationPending:
+			reasons = append(reasons, "listing was published again while awaiting review")
+		case data.ModerationRejected:
+			reasons = append(reasons, "listing was published again after being rejected by a moderator")
+		}
+	}
+
+	if publishing && app.requiresReview(property.City) {
+		reasons = append(reasons, fmt.Sprintf("listings in %s are reviewed before publishing", property.City))
+	}
+
+	if len(reasons) > 0 {
+		if item == nil {
+			item = &data.ModerationItem{ItemType: data.ModerationItemProperty}
+		}
+		item.Reasons = append(item.Reasons, reasons...)
+	}
+
+	if item != nil {
+		property.Status = data.StatusPendingReview
+	}
+
+	return item, nil
+}
+
+// holdForModeration adds a listing or inquiry to the moderation queue.
+func (app *application) holdForModeration(item *data.ModerationItem) error {
+	err := app.models.Moderation.Insert(item)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Printf("held %s %d for moderation: score %.2f", item.ItemType, item.ItemID, item.Score)
+
+	return nil
+}
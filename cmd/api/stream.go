@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/events"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// streamHandler streams the events addressed to the authenticated user as Server-Sent Events, together with
+// the events of the public topics listed in the topics query string parameter, such as the bid updates of
+// an auction. Browsers cannot set the Authorization header on an EventSource, so the token may also be
+// passed in the access_token query string parameter. The stream is closed before the server's write
+// timeout; clients reconnect with the Last-Event-ID header and receive the events they missed in between.
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.streamUser(w, r)
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		app.serverErrorResponse(w, r, errors.New("response writer does not support streaming"))
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	lastID, _ := strconv.ParseUint(lastEventID, 10, 64)
+
+	topics := app.readCSV(r.URL.Query(), "topics", []string{})
+
+	v := validator.New()
+	v.Check(len(topics) <= 20, "topics", "must not contain more than 20 topics")
+	for _, topic := range topics {
+		v.Check(events.IsPublicTopic(topic), "topics", fmt.Sprintf("%q is not a public topic", topic))
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	subscription := app.events.Subscribe(lastID, append(topics, events.UserTopic(user.ID))...)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", app.config.stream.retry.Milliseconds())
+	flusher.Flush()
+
+	deadline := time.NewTimer(app.config.stream.maxDuration)
+	defer deadline.Stop()
+
+	heartbeat := time.NewTicker(app.config.stream.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			return
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-subscription.C:
+			if !ok {
+				return
+			}
+			err := writeEvent(w, event)
+			if err != nil {
+				app.logger.Println(err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes an event in the Server-Sent Events format.
+func writeEvent(w http.ResponseWriter, event events.Event) error {
+	js, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, js)
+	return err
+}
+
+// streamUser returns the user authenticated by the Authorization header, or by the access_token query
+// string parameter when the header is absent. It sends an error response and returns false if neither
+// authenticates a user.
+func (app *application) streamUser(w http.ResponseWriter, r *http.Request) (*data.User, bool) {
+	user := app.contextGetUser(r)
+	if !user.IsAnonymous() {
+		return user, true
+	}
+
+	token := r.URL.Query().Get("access_token")
+	if token == "" {
+		app.authenticationRequiredResponse(w, r)
+		return nil, false
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return nil, false
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
+
+// publish sends an event to a user's stream.
+func (app *application) publish(userID int64, eventType string, payload interface{}) {
+	if userID == 0 {
+		return
+	}
+
+	err := app.events.Publish(events.UserTopic(userID), eventType, payload)
+	if err != nil {
+		app.logger.Println(err)
+	}
+}
+
+// broadcast sends an event to the streams subscribed to a public topic.
+func (app *application) broadcast(topic, eventType string, payload interface{}) {
+	err := app.events.Publish(topic, eventType, payload)
+	if err != nil {
+		app.logger.Println(err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// createValuationHandler estimates the price range of an existing property, or of ad-hoc property attributes, from comparable listings.
+func (app *application) createValuationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PropertyID *int64        `json:"property_id"`
+		City       string        `json:"city"`
+		Latitude   float64       `json:"latitude"`
+		Longitude  float64       `json:"longitude"`
+		Type       []string      `json:"type"`
+		Category   []string      `json:"category"`
+		Features   data.Features `json:"features"`
+		Currency   []string      `json:"currency"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	subject := &data.Property{
+		City:      input.City,
+		Latitude:  input.Latitude,
+		Longitude: input.Longitude,
+		Type:      input.Type,
+		Category:  input.Category,
+		Features:  input.Features,
+		Currency:  input.Currency,
+	}
+
+	if input.PropertyID != nil {
+		subject, err = app.models.Properties.Get(*input.PropertyID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if !app.canViewProperty(r, subject) {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		// Ad-hoc currency takes precedence, so that an existing property can be valued in another currency.
+		if input.Currency != nil {
+			subject.Currency = input.Currency
+		}
+	}
+
+	v := validator.New()
+	if data.ValidateValuationSubject(v, subject, app.config.currency.rates); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	valuation, err := app.models.Valuations.Estimate(subject, app.config.valuation.radiusKm, app.config.currency.rates)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, "there are not enough comparable properties to value this property")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"valuation": valuation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/storage"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// createVerificationHandler submits the authenticated user's licence for verification. The request is a
+// multipart form with the licence_number and authority fields and one or more supporting documents as
+// files parts.
+func (app *application) createVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	maxBytes := app.config.documents.maxBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes*data.MaxVerificationDocuments+1_048_576)
+
+	err := r.ParseMultipartForm(1_048_576)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("body must be a multipart form of at most %d bytes per file", maxBytes))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	verification := &data.LicenceVerification{
+		UserID:        app.contextGetUser(r).ID,
+		AgentName:     app.contextGetUser(r).Name,
+		LicenceNumber: strings.TrimSpace(r.FormValue("licence_number")),
+		Authority:     strings.TrimSpace(r.FormValue("authority")),
+	}
+
+	// The content type of each file is sniffed rather than trusted from the client.
+	headers := r.MultipartForm.File["files"]
+	files := make([]multipart.File, 0, len(headers))
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		files = append(files, file)
+
+		contentType, err := sniffContentType(file)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		verification.Documents = append(verification.Documents, &data.VerificationDocument{
+			Filename:    filepath.Base(header.Filename),
+			ContentType: contentType,
+		})
+	}
+
+	v := validator.New()
+	if data.ValidateLicenceVerification(v, verification); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	for i, document := range verification.Documents {
+		document.StorageKey, err = storage.NewKey(fmt.Sprintf("verifications/%d", verification.UserID))
+		if err == nil {
+			document.Size, err = app.storage.Put(r.Context(), document.StorageKey, files[i], maxBytes)
+		}
+		if err != nil {
+			app.deleteVerificationFiles(verification.Documents[:i])
+			switch {
+			case errors.Is(err, storage.ErrTooLarge):
+				v.AddError("files", fmt.Sprintf("must not be larger than %d bytes each", maxBytes))
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	err = app.models.Verifications.Insert(verification)
+	if err != nil {
+		app.deleteVerificationFiles(verification.Documents)
+		switch {
+		case errors.Is(err, data.ErrDuplicateVerification):
+			v.AddError("licence", "a previous submission is still awaiting review")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"verification": verification}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listVerificationsHandler lists the authenticated user's licence verification requests and their outcomes.
+func (app *application) listVerificationsHandler(w http.ResponseWriter, r *http.Request) {
+	verifications, err := app.models.Verifications.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"verifications": verifications}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listPendingVerificationsHandler lists the licence verification requests with a status, by default those
+// awaiting review, oldest first.
+func (app *application) listPendingVerificationsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", data.VerificationPending)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "created_at"
+	input.Filters.SortSafelist = []string{"created_at"}
+
+	data.ValidateVerificationQuery(v, input.Status)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	verifications, metadata, err := app.models.Verifications.GetAll(input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"verifications": verifications, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showVerificationHandler shows a licence verification request with its documents.
+func (app *application) showVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	verification, ok := app.licenceVerification(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+
+	verification.Documents, err = app.models.Verifications.GetDocuments(verification.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"verification": verification}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showVerificationDocumentHandler downloads a document submitted with a licence verification request.
+func (app *application) showVerificationDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	document, err := app.models.Verifications.GetDocument(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	file, err := app.storage.Open(r.Context(), document.StorageKey)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", document.ContentType)
+	w.Header().Set("Content-Length", fmt.Sprint(document.Size))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", strings.ReplaceAll(document.Filename, `"`, "")))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// approveVerificationHandler approves a licence awaiting review, marking its agent as verified.
+func (app *application) approveVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	app.decideVerification(w, r, data.VerificationApproved)
+}
+
+// rejectVerificationHandler rejects a licence awaiting review.
+func (app *application) rejectVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	app.decideVerification(w, r, data.VerificationRejected)
+}
+
+// decideVerification records the authenticated admin's decision on a licence verification request and
+// notifies the agent who submitted it.
+func (app *application) decideVerification(w http.ResponseWriter, r *http.Request, decision string) {
+	verification, ok := app.licenceVerification(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(verification.Status == data.VerificationPending, "status", "licence has already been reviewed")
+	if data.ValidateVerificationDecision(v, decision, input.Reason); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Verifications.Decide(verification, app.contextGetUser(r).ID, decision, input.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyVerificationDecision(verification)
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"verification": verification}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// notifyVerificationDecision tells an agent whether their licence was approved or rejected, on their event
+// stream and by email.
+func (app *application) notifyVerificationDecision(verification *data.LicenceVerification) {
+	app.publish(verification.UserID, "licence_verification."+verification.Status, verification)
+
+	app.background(func() {
+		user, err := app.models.Users.Get(verification.UserID)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		templateData := map[string]interface{}{
+			"Name":         user.Name,
+			"Verification": verification,
+		}
+
+		err = app.mailer.Send(user.Email, "licence_verification.tmpl", templateData)
+		if err != nil {
+			app.logger.Println(err)
+		}
+	})
+}
+
+// requiresVerification reports whether only verified agents can publish listings in a city.
+func (app *application) requiresVerification(city string) bool {
+	return cityIn(app.config.verification.requiredCities, city)
+}
+
+// checkAgentVerified adds a validation error when a listing is being published in a market that requires
+// verification by an agent whose licence has not been verified. The rule only gates publishing: listings
+// that are already live stay published if their agent later loses their verification.
+func (app *application) checkAgentVerified(v *validator.Validator, property *data.Property, publishing bool) error {
+	if !publishing || property.Status != data.StatusPublished || !app.requiresVerification(property.City) {
+		return nil
+	}
+
+	profile, err := app.models.Agents.GetProfile(property.UserID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return err
+	}
+
+	property.AgentVerified = profile != nil && profile.Verified
+	v.Check(property.AgentVerified, "status", fmt.Sprintf("listings in %s can only be published by agents with a verified licence", property.City))
+
+	return nil
+}
+
+// licenceVerification fetches the licence verification request identified by the id parameter, sending a
+// 404 Not Found response and returning false if there is none.
+func (app *application) licenceVerification(w http.ResponseWriter, r *http.Request) (*data.LicenceVerification, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	verification, err := app.models.Verifications.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return verification, true
+}
+
+// deleteVerificationFiles removes the stored files of documents that could not be saved with a licence
+// verification request.
+func (app *application) deleteVerificationFiles(documents []*data.VerificationDocument) {
+	for _, document := range documents {
+		app.deleteStoredFile(document.StorageKey)
+	}
+}
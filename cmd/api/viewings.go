@@ -0,0 +1,439 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// listAvailabilityHandler lists the weekly availability rules and upcoming blackout dates of the authenticated agent.
+func (app *application) listAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	agentID := app.contextGetUser(r).ID
+
+	rules, err := app.models.Availability.GetRulesForAgent(agentID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	blackouts, err := app.models.Availability.GetBlackoutsForAgent(agentID, time.Now().AddDate(0, 0, -1))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"rules": rules, "blackouts": blackouts}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createAvailabilityRuleHandler adds a weekly availability window for the authenticated agent.
+func (app *application) createAvailabilityRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Weekday     *int   `json:"weekday"`
+		StartTime   string `json:"start_time"`
+		EndTime     string `json:"end_time"`
+		TimeZone    string `json:"time_zone"`
+		SlotMinutes int    `json:"slot_minutes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rule := &data.AvailabilityRule{
+		AgentID:     app.contextGetUser(r).ID,
+		Weekday:     -1,
+		StartTime:   input.StartTime,
+		EndTime:     input.EndTime,
+		TimeZone:    input.TimeZone,
+		SlotMinutes: input.SlotMinutes,
+	}
+
+	if input.Weekday != nil {
+		rule.Weekday = *input.Weekday
+	}
+	if rule.SlotMinutes == 0 {
+		rule.SlotMinutes = 30
+	}
+
+	v := validator.New()
+	if data.ValidateAvailabilityRule(v, rule); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Availability.InsertRule(rule)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"rule": rule}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteAvailabilityRuleHandler deletes a weekly availability window of the authenticated agent.
+func (app *application) deleteAvailabilityRuleHandler(w http.ResponseWriter, r *http.Request) {
+	app.deleteAvailability(w, r, app.models.Availability.DeleteRule, "availability rule successfully deleted")
+}
+
+// createBlackoutHandler marks a date on which the authenticated agent is not available for viewings.
+func (app *application) createBlackoutHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Date   string `json:"date"`
+		Reason string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	blackout := &data.Blackout{
+		AgentID: app.contextGetUser(r).ID,
+		Date:    input.Date,
+		Reason:  input.Reason,
+	}
+
+	v := validator.New()
+	if data.ValidateBlackout(v, blackout); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Availability.InsertBlackout(blackout)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"blackout": blackout}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteBlackoutHandler deletes a blackout date of the authenticated agent.
+func (app *application) deleteBlackoutHandler(w http.ResponseWriter, r *http.Request) {
+	app.deleteAvailability(w, r, app.models.Availability.DeleteBlackout, "blackout date successfully deleted")
+}
+
+// deleteAvailability deletes the availability record identified by the id parameter belonging to the authenticated agent.
+func (app *application) deleteAvailability(w http.ResponseWriter, r *http.Request, deleteFn func(id, agentID int64) error, message string) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = deleteFn(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"message": message}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listViewingSlotsHandler lists the bookable viewing slots of a published property over a number of days
+// from a date. The date is midnight in the agent's time zone unless another time zone is given.
+func (app *application) listViewingSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.publishedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	rules, err := app.models.Availability.GetRulesForAgent(property.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	timeZone := "UTC"
+	if len(rules) > 0 {
+		timeZone = rules[0].TimeZone
+	}
+	timeZone = app.readString(qs, "time_zone", timeZone)
+	days := app.readInt(qs, "days", 7, v)
+	fromDate := app.readString(qs, "from", "")
+
+	data.ValidateTimeZone(v, timeZone)
+	v.Check(days >= 1 && days <= app.config.viewings.maxDays, "days", "must be between 1 and "+strconv.Itoa(app.config.viewings.maxDays))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	loc, _ := time.LoadLocation(timeZone)
+
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if fromDate != "" {
+		from, err = time.ParseInLocation("2006-01-02", fromDate, loc)
+		if err != nil {
+			v.AddError("from", "must be a date in the YYYY-MM-DD format")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	slots, err := app.viewingSlots(property.UserID, rules, from, from.AddDate(0, 0, days))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"slots": slots, "time_zone": timeZone}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// viewingSlots computes the bookable slots of an agent in the [from, to) interval, leaving the configured
+// minimum notice before the first slot.
+func (app *application) viewingSlots(agentID int64, rules []*data.AvailabilityRule, from, to time.Time) ([]*data.ViewingSlot, error) {
+	if agentID == 0 || len(rules) == 0 {
+		return []*data.ViewingSlot{}, nil
+	}
+
+	blackouts, err := app.models.Availability.GetBlackoutsForAgent(agentID, from.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	booked, err := app.models.Viewings.GetBookedForAgent(agentID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.ViewingSlots(rules, blackouts, booked, from, to, time.Now().Add(app.config.viewings.minNotice))
+}
+
+// createViewingHandler books a viewing of a published property for the authenticated user in one of the
+// agent's available slots.
+func (app *application) createViewingHandler(w http.ResponseWriter, r *http.Request) {
+	property, ok := app.publishedProperty(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		StartsAt time.Time `json:"starts_at"`
+		Note     string    `json:"note"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	viewing := &data.Viewing{
+		PropertyID:    property.ID,
+		PropertyTitle: property.Title,
+		AgentID:       property.UserID,
+		BuyerID:       app.contextGetUser(r).ID,
+		StartsAt:      input.StartsAt,
+		Note:          input.Note,
+	}
+
+	v := validator.New()
+	if data.ValidateViewing(v, viewing); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rules, err := app.models.Availability.GetRulesForAgent(property.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	slots, err := app.viewingSlots(property.UserID, rules, input.StartsAt, input.StartsAt.Add(time.Second))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var slot *data.ViewingSlot
+	for _, s := range slots {
+		if s.StartsAt.Equal(input.StartsAt) {
+			slot = s
+			break
+		}
+	}
+
+	if slot == nil {
+		v.AddError("starts_at", "must be the start of an available slot")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	viewing.EndsAt = slot.EndsAt
+	viewing.TimeZone = slot.TimeZone
+
+	err = app.models.Viewings.Insert(viewing)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrSlotUnavailable):
+			app.slotUnavailableResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	viewing.Local()
+	app.notifyViewing(viewing, "viewing.booked", "viewing_confirmed.tmpl")
+
+	err = app.writeJSON(w, http.StatusCreated, envelop{"viewing": viewing}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listViewingsHandler lists the viewings the authenticated user takes part in as buyer or agent, soonest
+// first. Past viewings are included when upcoming is false.
+func (app *application) listViewingsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Upcoming bool
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Upcoming = true
+	if upcoming := qs.Get("upcoming"); upcoming != "" {
+		value, err := strconv.ParseBool(upcoming)
+		if err != nil {
+			v.AddError("upcoming", "must be true or false")
+		}
+		input.Upcoming = value
+	}
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "starts_at"
+	input.Filters.SortSafelist = []string{"starts_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	viewings, metadata, err := app.models.Viewings.GetAllForUser(app.contextGetUser(r).ID, input.Upcoming, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"viewings": viewings, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// cancelViewingHandler cancels an upcoming viewing on behalf of its buyer or agent.
+func (app *application) cancelViewingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	viewing, err := app.models.Viewings.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if !viewing.HasParticipant(user.ID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	v.Check(viewing.Status == data.ViewingConfirmed, "status", "viewing has already been cancelled")
+	v.Check(viewing.StartsAt.After(time.Now()), "starts_at", "viewing has already started")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Viewings.Cancel(viewing, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyViewing(viewing, "viewing.cancelled", "viewing_cancelled.tmpl")
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"viewing": viewing}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// notifyViewing notifies the buyer and agent of a viewing that it has been booked or cancelled, on their
+// event streams and by email with the viewing attached as an .ics file.
+func (app *application) notifyViewing(viewing *data.Viewing, eventType, templateFile string) {
+	app.publish(viewing.BuyerID, eventType, viewing)
+	app.publish(viewing.AgentID, eventType, viewing)
+
+	attachment := viewingAttachment(viewing)
+
+	app.background(func() {
+		for _, userID := range []int64{viewing.BuyerID, viewing.AgentID} {
+			user, err := app.models.Users.Get(userID)
+			if err != nil {
+				app.logger.Println(err)
+				continue
+			}
+
+			templateData := map[string]interface{}{
+				"Name":    user.Name,
+				"Viewing": viewing,
+				"When":    viewing.When(),
+			}
+
+			err = app.mailer.Send(user.Email, templateFile, templateData, attachment)
+			if err != nil {
+				app.logger.Println(err)
+			}
+		}
+	})
+}
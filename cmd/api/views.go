@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/validator"
+)
+
+// recordView queues a detail view of a property for the batch writer without blocking the request.
+// When the queue is full the view is dropped rather than slowing the response down.
+func (app *application) recordView(r *http.Request, propertyID int64) {
+	view := data.PropertyView{
+		PropertyID: propertyID,
+		Visitor:    app.visitorID(r),
+		ViewedAt:   time.Now(),
+	}
+
+	select {
+	case app.views <- view:
+	default:
+	}
+}
+
+// visitorID identifies the visitor making a request: authenticated users by their ID, and anonymous
+// visitors by a hash of their IP address and user agent, so that no personal data is stored.
+func (app *application) visitorID(r *http.Request) string {
+	user := app.contextGetUser(r)
+	if !user.IsAnonymous() {
+		return fmt.Sprintf("user:%d", user.ID)
+	}
+
+	hash := sha256.Sum256([]byte(app.clientIP(r) + "|" + r.UserAgent()))
+	return "anon:" + hex.EncodeToString(hash[:16])
+}
+
+// runViewRecorder writes queued views to the database in batches, whenever a batch is full or the flush interval elapses.
+func (app *application) runViewRecorder(batchSize int, flushInterval time.Duration) {
+	app.background(func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+
+		batch := make([]data.PropertyView, 0, batchSize)
+
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			err := app.models.Views.InsertBatch(batch)
+			if err != nil {
+				app.logger.Println(err)
+			}
+			batch = batch[:0]
+		}
+
+		for {
+			select {
+			case view := <-app.views:
+				batch = append(batch, view)
+				if len(batch) >= batchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			}
+		}
+	})
+}
+
+// showPropertyAnalyticsHandler shows the performance of one of the authenticated user's listings.
+func (app *application) showPropertyAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	days := app.readInt(r.URL.Query(), "days", 30, v)
+
+	v.Check(days > 0, "days", "must be greater than zero")
+	if v.Check(days <= 365, "days", "must be a maximum of 365"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	property, err := app.models.Properties.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if property.UserID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	analytics, err := app.models.Views.GetAnalytics(property, days)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelop{"analytics": analytics}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
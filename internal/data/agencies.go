@@ -0,0 +1,490 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+var (
+	// ErrAlreadyInAgency is returned when a user who belongs to an agency creates or joins another.
+	ErrAlreadyInAgency = errors.New("already in agency")
+	// ErrDuplicateInvitation is returned when inviting an email address that already has a pending invitation.
+	ErrDuplicateInvitation = errors.New("duplicate invitation")
+)
+
+// Agency invitation statuses.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// Agency contains an estate agency, whose agents list properties as a team.
+type Agency struct {
+	ID          int64           `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	LogoURL     string          `json:"logo_url,omitempty"`
+	Phone       string          `json:"phone,omitempty"`
+	Email       string          `json:"email,omitempty"`
+	Website     string          `json:"website,omitempty"`
+	City        string          `json:"city,omitempty"`
+	Version     int32           `json:"version"`
+	Agents      []*AgentProfile `json:"agents,omitempty"`
+}
+
+// ValidateAgency validates an agency based on set validation criteria.
+func ValidateAgency(v *validator.Validator, agency *Agency) {
+	v.Check(agency.Name != "", "name", "must be provided")
+	v.Check(len(agency.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(agency.Description) <= 5000, "description", "must not be more than 5000 bytes long")
+	validateWebURL(v, "logo_url", agency.LogoURL)
+	validateWebURL(v, "website", agency.Website)
+	v.Check(agency.Phone == "" || validator.Matches(agency.Phone, PhoneRX), "phone", "must be a valid phone number")
+	v.Check(agency.Email == "" || validator.Matches(agency.Email, regexp.MustCompile(validator.EmailRX)), "email", "must be a valid email address")
+	v.Check(len(agency.City) <= 100, "city", "must not be more than 100 bytes long")
+}
+
+// AgencyInvitation contains an invitation for the user with an email address to join an agency as an agent.
+// The plaintext Token is only known when the invitation is inserted, so that it can be emailed to the
+// invitee; only its hash is stored.
+type AgencyInvitation struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	AgencyID    int64      `json:"agency_id"`
+	AgencyName  string     `json:"agency_name"`
+	Email       string     `json:"email"`
+	InvitedBy   int64      `json:"invited_by"`
+	Status      string     `json:"status"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+	Token       string     `json:"-"`
+	TokenHash   []byte     `json:"-"`
+}
+
+// TokenMatches reports whether a plaintext token is the one emailed with the invitation.
+func (invitation *AgencyInvitation) TokenMatches(plaintext string) bool {
+	hash := sha256.Sum256([]byte(plaintext))
+	return len(invitation.TokenHash) > 0 && subtle.ConstantTimeCompare(hash[:], invitation.TokenHash) == 1
+}
+
+// AgencyModel struct wraps a sql.DB connection pool.
+type AgencyModel struct {
+	DB *sql.DB
+}
+
+// Insert creates an agency with a user as its admin in one transaction, returning ErrAlreadyInAgency if
+// the user already belongs to an agency. The user is given an agent profile if they have none.
+func (m AgencyModel) Insert(agency *Agency, adminID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockAgentForJoining(ctx, tx, adminID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO agencies (name, description, logo_url, phone, email, website, city)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at, version`
+
+	args := []interface{}{agency.Name, agency.Description, agency.LogoURL, agency.Phone, agency.Email, agency.Website, agency.City}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&agency.ID, &agency.CreatedAt, &agency.Version)
+	if err != nil {
+		return err
+	}
+
+	err = joinAgency(ctx, tx, adminID, agency.ID, AgencyRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockAgentForJoining locks the agent profile of a user within a transaction, creating it if it does not
+// exist, and returns ErrAlreadyInAgency if the user belongs to an agency.
+func lockAgentForJoining(ctx context.Context, tx *sql.Tx, userID int64) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO agent_profiles (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`, userID)
+	if err != nil {
+		return err
+	}
+
+	var agencyID *int64
+
+	err = tx.QueryRowContext(ctx, `SELECT agency_id FROM agent_profiles WHERE user_id = $1 FOR UPDATE`, userID).Scan(&agencyID)
+	if err != nil {
+		return err
+	}
+
+	if agencyID != nil {
+		return ErrAlreadyInAgency
+	}
+
+	return nil
+}
+
+// joinAgency makes a user a member of an agency within a transaction.
+func joinAgency(ctx context.Context, tx *sql.Tx, userID, agencyID int64, role string) error {
+	query := `
+	UPDATE agent_profiles
+	SET agency_id = $1, agency_role = $2, updated_at = NOW(), version = version + 1
+	WHERE user_id = $3`
+
+	_, err := tx.ExecContext(ctx, query, agencyID, role, userID)
+	return err
+}
+
+// Get fetches a specific agency.
+func (m AgencyModel) Get(id int64) (*Agency, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT id, created_at, name, description, logo_url, phone, email, website, city, version
+	FROM agencies
+	WHERE id = $1`
+
+	var agency Agency
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&agency.ID,
+		&agency.CreatedAt,
+		&agency.Name,
+		&agency.Description,
+		&agency.LogoURL,
+		&agency.Phone,
+		&agency.Email,
+		&agency.Website,
+		&agency.City,
+		&agency.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &agency, nil
+}
+
+// Update updates the details of an agency, returning ErrEditConflict if it has changed since it was fetched.
+func (m AgencyModel) Update(agency *Agency) error {
+	query := `
+	UPDATE agencies
+	SET name = $1, description = $2, logo_url = $3, phone = $4, email = $5, website = $6, city = $7, version = version + 1
+	WHERE id = $8 AND version = $9
+	RETURNING version`
+
+	args := []interface{}{agency.Name, agency.Description, agency.LogoURL, agency.Phone, agency.Email, agency.Website, agency.City, agency.ID, agency.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&agency.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveMember removes an agent who is not an admin from an agency. Their listings stay with them.
+func (m AgencyModel) RemoveMember(agencyID, userID int64) error {
+	query := `
+	UPDATE agent_profiles
+	SET agency_id = NULL, agency_role = NULL, updated_at = NOW(), version = version + 1
+	WHERE user_id = $1 AND agency_id = $2 AND agency_role = 'agent'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, agencyID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ReassignListing moves a property listed by a member of an agency to another member of the agency, together
+// with the conversations buyers have started about it, returning ErrEditConflict if the property has changed
+// since it was fetched or either agent is not a member. Viewings already booked stay with the agent they were
+// made with.
+func (m AgencyModel) ReassignListing(property *Property, agencyID, toUserID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE properties
+	SET user_id = $1, updated_at = NOW(), version = version + 1
+	WHERE id = $2 AND version = $3
+		AND EXISTS (SELECT 1 FROM agent_profiles WHERE user_id = properties.user_id AND agency_id = $4)
+		AND EXISTS (SELECT 1 FROM agent_profiles WHERE user_id = $1 AND agency_id = $4)
+	RETURNING updated_at, version`
+
+	err = tx.QueryRowContext(ctx, query, toUserID, property.ID, property.Version, agencyID).Scan(&property.UpdatedAt, &property.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	query = `
+	UPDATE conversations
+	SET agent_id = $1
+	WHERE property_id = $2 AND agent_id = $3 AND buyer_id <> $1`
+
+	_, err = tx.ExecContext(ctx, query, toUserID, property.ID, property.UserID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	property.UserID = toUserID
+
+	return nil
+}
+
+// InsertInvitation inserts a new agency invitation with a random token that the invitee must present to
+// accept it, returning ErrDuplicateInvitation if the email address already has a pending invitation to the
+// agency.
+func (m AgencyModel) InsertInvitation(invitation *AgencyInvitation) error {
+	token, err := generateToken(invitation.InvitedBy, 0, "")
+	if err != nil {
+		return err
+	}
+	invitation.Token, invitation.TokenHash = token.Plaintext, token.Hash
+
+	query := `
+	INSERT INTO agency_invitations (agency_id, email, invited_by, token_hash)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at, status`
+
+	args := []interface{}{invitation.AgencyID, invitation.Email, invitation.InvitedBy, invitation.TokenHash}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&invitation.ID, &invitation.CreatedAt, &invitation.Status)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "agency_invitations_pending_idx"`:
+			return ErrDuplicateInvitation
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetInvitation fetches a specific agency invitation.
+func (m AgencyModel) GetInvitation(id int64) (*AgencyInvitation, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT agency_invitations.id, agency_invitations.created_at, agency_invitations.agency_id, agencies.name,
+		agency_invitations.email, coalesce(agency_invitations.invited_by, 0), agency_invitations.status,
+		agency_invitations.responded_at, agency_invitations.token_hash
+	FROM agency_invitations
+	INNER JOIN agencies ON agencies.id = agency_invitations.agency_id
+	WHERE agency_invitations.id = $1`
+
+	var invitation AgencyInvitation
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(invitation.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &invitation, nil
+}
+
+// GetPendingInvitations returns the pending invitations to an agency, or the pending invitations of an email
+// address when agencyID is zero, newest first.
+func (m AgencyModel) GetPendingInvitations(agencyID int64, email string) ([]*AgencyInvitation, error) {
+	query := `
+	SELECT agency_invitations.id, agency_invitations.created_at, agency_invitations.agency_id, agencies.name,
+		agency_invitations.email, coalesce(agency_invitations.invited_by, 0), agency_invitations.status,
+		agency_invitations.responded_at, agency_invitations.token_hash
+	FROM agency_invitations
+	INNER JOIN agencies ON agencies.id = agency_invitations.agency_id
+	WHERE agency_invitations.status = 'pending'
+		AND (agency_invitations.agency_id = $1 OR ($1 = 0 AND agency_invitations.email = $2))
+	ORDER BY agency_invitations.created_at DESC, agency_invitations.id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, agencyID, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*AgencyInvitation{}
+
+	for rows.Next() {
+		var invitation AgencyInvitation
+		err := rows.Scan(invitation.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, &invitation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// AcceptInvitation accepts a pending invitation on behalf of a user in one transaction, making them an
+// agent of the agency. ErrAlreadyInAgency is returned if the user already belongs to an agency, and
+// ErrEditConflict if the invitation is no longer pending.
+func (m AgencyModel) AcceptInvitation(invitation *AgencyInvitation, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = respondToInvitation(ctx, tx, invitation, InvitationAccepted)
+	if err != nil {
+		return err
+	}
+
+	err = lockAgentForJoining(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = joinAgency(ctx, tx, userID, invitation.AgencyID, AgencyRoleAgent)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CloseInvitation declines or revokes a pending invitation, returning ErrEditConflict if it is no longer pending.
+func (m AgencyModel) CloseInvitation(invitation *AgencyInvitation, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = respondToInvitation(ctx, tx, invitation, status)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// respondToInvitation changes the status of a pending invitation within a transaction.
+func respondToInvitation(ctx context.Context, tx *sql.Tx, invitation *AgencyInvitation, status string) error {
+	query := `
+	UPDATE agency_invitations
+	SET status = $1, responded_at = NOW()
+	WHERE id = $2 AND status = 'pending'
+	RETURNING responded_at`
+
+	err := tx.QueryRowContext(ctx, query, status, invitation.ID).Scan(&invitation.RespondedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	invitation.Status = status
+
+	return nil
+}
+
+// scanTargets returns pointers to the invitation fields in the order they are selected.
+func (invitation *AgencyInvitation) scanTargets() []interface{} {
+	return []interface{}{
+		&invitation.ID,
+		&invitation.CreatedAt,
+		&invitation.AgencyID,
+		&invitation.AgencyName,
+		&invitation.Email,
+		&invitation.InvitedBy,
+		&invitation.Status,
+		&invitation.RespondedAt,
+		&invitation.TokenHash,
+	}
+}
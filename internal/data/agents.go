@@ -0,0 +1,212 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Agency roles. Agency admins manage the agency's details, team members and listings.
+const (
+	AgencyRoleAdmin = "admin"
+	AgencyRoleAgent = "agent"
+)
+
+// AgentProfile contains the public profile of a user who lists properties, and the agency they belong to.
+type AgentProfile struct {
+	UserID           int64      `json:"user_id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	Name             string     `json:"name"`
+	Bio              string     `json:"bio"`
+	PhotoURL         string     `json:"photo_url,omitempty"`
+	LicenceNumber    string     `json:"licence_number,omitempty"`
+	LicenceAuthority string     `json:"licence_authority,omitempty"`
+	Verified         bool       `json:"verified"`
+	VerifiedAt       *time.Time `json:"verified_at,omitempty"`
+	Phone            string     `json:"phone,omitempty"`
+	ServiceAreas     []string   `json:"service_areas"`
+	AgencyID         *int64     `json:"agency_id,omitempty"`
+	AgencyName       string     `json:"agency_name,omitempty"`
+	AgencyRole       string     `json:"agency_role,omitempty"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	Version          int32      `json:"version"`
+}
+
+// IsAgencyAdmin reports whether the agent is an admin of an agency.
+func (profile *AgentProfile) IsAgencyAdmin() bool {
+	return profile.AgencyID != nil && profile.AgencyRole == AgencyRoleAdmin
+}
+
+// ValidateAgentProfile validates an agent profile based on set validation criteria.
+func ValidateAgentProfile(v *validator.Validator, profile *AgentProfile) {
+	v.Check(len(profile.Bio) <= 2000, "bio", "must not be more than 2000 bytes long")
+	validateWebURL(v, "photo_url", profile.PhotoURL)
+	v.Check(len(profile.LicenceNumber) <= 50, "licence_number", "must not be more than 50 bytes long")
+	v.Check(profile.Phone == "" || validator.Matches(profile.Phone, PhoneRX), "phone", "must be a valid phone number")
+	v.Check(len(profile.ServiceAreas) <= 20, "service_areas", "must not contain more than 20 areas")
+	v.Check(validator.Unique(profile.ServiceAreas), "service_areas", "must not contain duplicate values")
+	for _, area := range profile.ServiceAreas {
+		v.Check(area != "", "service_areas", "must not contain empty values")
+		v.Check(len(area) <= 100, "service_areas", "must not contain values more than 100 bytes long")
+	}
+}
+
+// validateWebURL checks that an optional value is an http or https URL.
+func validateWebURL(v *validator.Validator, key, value string) {
+	if value == "" {
+		return
+	}
+	v.Check(len(value) <= 2000, key, "must not be more than 2000 bytes long")
+	u, err := url.Parse(value)
+	v.Check(err == nil && validator.In(u.Scheme, "http", "https") && u.Host != "", key, "must be an http or https URL")
+}
+
+// AgentModel struct wraps a sql.DB connection pool.
+type AgentModel struct {
+	DB *sql.DB
+}
+
+// GetProfile fetches the profile of an agent.
+func (m AgentModel) GetProfile(userID int64) (*AgentProfile, error) {
+	if userID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM agent_profiles
+	INNER JOIN users ON users.id = agent_profiles.user_id
+	LEFT JOIN agencies ON agencies.id = agent_profiles.agency_id
+	WHERE agent_profiles.user_id = $1`, agentColumns)
+
+	var profile AgentProfile
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(profile.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &profile, nil
+}
+
+// SetProfile creates or replaces the profile of an agent, leaving their agency membership unchanged. Changing
+// the licence number of a verified agent removes their verification, which stops them publishing listings in
+// markets that require it but leaves their published listings live, no longer marked as verified. When the
+// version is not zero, the profile is only replaced if it still has that version, and ErrEditConflict is
+// returned otherwise.
+func (m AgentModel) SetProfile(profile *AgentProfile) error {
+	query := `
+	INSERT INTO agent_profiles (user_id, bio, photo_url, licence_number, phone, service_areas)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (user_id) DO UPDATE
+	SET bio = EXCLUDED.bio, photo_url = EXCLUDED.photo_url, licence_number = EXCLUDED.licence_number,
+		phone = EXCLUDED.phone, service_areas = EXCLUDED.service_areas, updated_at = NOW(),
+		verified_at = CASE WHEN EXCLUDED.licence_number = agent_profiles.licence_number THEN agent_profiles.verified_at END,
+		version = agent_profiles.version + 1
+	WHERE $7 = 0 OR agent_profiles.version = $7
+	RETURNING created_at, verified_at IS NOT NULL, verified_at, updated_at, version`
+
+	args := []interface{}{
+		profile.UserID,
+		profile.Bio,
+		profile.PhotoURL,
+		profile.LicenceNumber,
+		profile.Phone,
+		pq.Array(nonNil(profile.ServiceAreas)),
+		profile.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&profile.CreatedAt, &profile.Verified, &profile.VerifiedAt, &profile.UpdatedAt, &profile.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAllForAgency returns the agents of an agency, admins first.
+func (m AgentModel) GetAllForAgency(agencyID int64) ([]*AgentProfile, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM agent_profiles
+	INNER JOIN users ON users.id = agent_profiles.user_id
+	LEFT JOIN agencies ON agencies.id = agent_profiles.agency_id
+	WHERE agent_profiles.agency_id = $1
+	ORDER BY agent_profiles.agency_role = 'admin' DESC, users.name, agent_profiles.user_id`, agentColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []*AgentProfile{}
+
+	for rows.Next() {
+		var profile AgentProfile
+		err := rows.Scan(profile.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, &profile)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// agentColumns lists the agent profile columns in the order expected by AgentProfile.scanTargets.
+const agentColumns = `agent_profiles.user_id, agent_profiles.created_at, users.name, agent_profiles.bio, agent_profiles.photo_url,
+	agent_profiles.licence_number, agent_profiles.licence_authority, agent_profiles.verified_at IS NOT NULL,
+	agent_profiles.verified_at, agent_profiles.phone, agent_profiles.service_areas, agent_profiles.agency_id,
+	coalesce(agencies.name, ''), coalesce(agent_profiles.agency_role, ''), agent_profiles.updated_at, agent_profiles.version`
+
+// scanTargets returns pointers to the agent profile fields in the order of agentColumns.
+func (profile *AgentProfile) scanTargets() []interface{} {
+	return []interface{}{
+		&profile.UserID,
+		&profile.CreatedAt,
+		&profile.Name,
+		&profile.Bio,
+		&profile.PhotoURL,
+		&profile.LicenceNumber,
+		&profile.LicenceAuthority,
+		&profile.Verified,
+		&profile.VerifiedAt,
+		&profile.Phone,
+		pq.Array(&profile.ServiceAreas),
+		&profile.AgencyID,
+		&profile.AgencyName,
+		&profile.AgencyRole,
+		&profile.UpdatedAt,
+		&profile.Version,
+	}
+}
@@ -0,0 +1,469 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrDuplicateAuction is returned when scheduling an auction of a property that already has one scheduled.
+	ErrDuplicateAuction = errors.New("duplicate auction")
+	// ErrAuctionNotLive is returned when bidding on an auction that has not started, has ended or was cancelled.
+	ErrAuctionNotLive = errors.New("auction not live")
+	// ErrBidTooLow is returned when a bid is below the minimum bid of an auction.
+	ErrBidTooLow = errors.New("bid too low")
+	// ErrHighestBidder is returned when the highest bidder of an auction bids again.
+	ErrHighestBidder = errors.New("already highest bidder")
+)
+
+// Auction statuses. A scheduled auction is live between its start and end times, and is closed by the
+// winner determination job once it has ended.
+const (
+	AuctionScheduled = "scheduled"
+	AuctionClosed    = "closed"
+	AuctionCancelled = "cancelled"
+)
+
+// Auction contains a timed online auction of a property. A bid placed within ExtensionSeconds of the end
+// of the auction extends it to ExtensionSeconds after the bid, so that bidders always have time to respond;
+// ScheduledEndsAt holds the end time the auction was scheduled with. The reserve price and the bidders are
+// only shown to the seller.
+type Auction struct {
+	ID               int64      `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	PropertyID       int64      `json:"property_id"`
+	PropertyTitle    string     `json:"property_title"`
+	SellerID         int64      `json:"seller_id"`
+	StartsAt         time.Time  `json:"starts_at"`
+	EndsAt           time.Time  `json:"ends_at"`
+	ScheduledEndsAt  time.Time  `json:"scheduled_ends_at"`
+	StartingPrice    float64    `json:"starting_price"`
+	ReservePrice     float64    `json:"-"`
+	MinIncrement     float64    `json:"min_increment"`
+	ExtensionSeconds int        `json:"extension_seconds"`
+	CurrentBid       *float64   `json:"current_bid"`
+	CurrentBidderID  int64      `json:"-"`
+	BidCount         int        `json:"bid_count"`
+	MinimumBid       float64    `json:"minimum_bid"`
+	ReserveMet       bool       `json:"reserve_met"`
+	Live             bool       `json:"live"`
+	Status           string     `json:"status"`
+	WinnerID         int64      `json:"-"`
+	ClosedAt         *time.Time `json:"closed_at,omitempty"`
+	Version          int32      `json:"version"`
+}
+
+// HighBid returns the current high bid of an auction, or zero if it has no bids.
+func (auction *Auction) HighBid() float64 {
+	if auction.CurrentBid == nil {
+		return 0
+	}
+	return *auction.CurrentBid
+}
+
+// ValidateAuction validates an auction based on set validation criteria.
+func ValidateAuction(v *validator.Validator, auction *Auction) {
+	v.Check(auction.SellerID != 0, "property", "has no seller to auction it")
+	v.Check(!auction.StartsAt.IsZero(), "starts_at", "must be provided")
+	v.Check(!auction.EndsAt.IsZero(), "ends_at", "must be provided")
+	v.Check(auction.StartsAt.After(time.Now()), "starts_at", "must be in the future")
+	v.Check(auction.EndsAt.Sub(auction.StartsAt) >= 10*time.Minute, "ends_at", "must be at least 10 minutes after starts_at")
+	v.Check(auction.EndsAt.Sub(auction.StartsAt) <= 30*24*time.Hour, "ends_at", "must be at most 30 days after starts_at")
+	v.Check(auction.StartingPrice > 0, "starting_price", "must be greater than zero")
+	v.Check(auction.StartingPrice < 1e12, "starting_price", "must be less than 1000000000000")
+	v.Check(auction.ReservePrice >= 0, "reserve_price", "must not be a negative number")
+	v.Check(auction.ReservePrice < 1e12, "reserve_price", "must be less than 1000000000000")
+	v.Check(auction.MinIncrement > 0, "min_increment", "must be greater than zero")
+	v.Check(auction.MinIncrement < 1e12, "min_increment", "must be less than 1000000000000")
+	v.Check(auction.ExtensionSeconds >= 0, "extension_seconds", "must not be a negative number")
+	v.Check(auction.ExtensionSeconds <= 3600, "extension_seconds", "must not be more than 3600")
+}
+
+// Bid contains a bid placed on an auction.
+type Bid struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	AuctionID int64     `json:"auction_id"`
+	BidderID  int64     `json:"bidder_id"`
+	Amount    float64   `json:"amount"`
+}
+
+// ValidateBid validates a bid based on set validation criteria.
+func ValidateBid(v *validator.Validator, auction *Auction, bid *Bid) {
+	v.Check(bid.BidderID != auction.SellerID, "auction", "must not be your own auction")
+	v.Check(bid.Amount > 0, "amount", "must be greater than zero")
+	v.Check(bid.Amount < 1e12, "amount", "must be less than 1000000000000")
+}
+
+// AuctionModel struct wraps a sql.DB connection pool.
+type AuctionModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a new record into the auctions table, returning ErrDuplicateAuction if the property already
+// has a scheduled auction.
+func (m AuctionModel) Insert(auction *Auction) error {
+	query := `
+	INSERT INTO auctions (property_id, starts_at, ends_at, scheduled_ends_at, starting_price, reserve_price, min_increment, extension_seconds)
+	VALUES ($1, $2, $3, $3, $4, $5, $6, $7)
+	RETURNING id, created_at, scheduled_ends_at, status, version`
+
+	args := []interface{}{
+		auction.PropertyID,
+		auction.StartsAt,
+		auction.EndsAt,
+		auction.StartingPrice,
+		auction.ReservePrice,
+		auction.MinIncrement,
+		auction.ExtensionSeconds,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&auction.ID, &auction.CreatedAt, &auction.ScheduledEndsAt, &auction.Status, &auction.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "auctions_scheduled_idx"`:
+			return ErrDuplicateAuction
+		default:
+			return err
+		}
+	}
+
+	auction.MinimumBid = auction.StartingPrice
+	auction.ReserveMet = auction.ReservePrice == 0
+
+	return nil
+}
+
+// Get fetches a specific auction.
+func (m AuctionModel) Get(id int64) (*Auction, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM auctions
+	INNER JOIN properties ON properties.id = auctions.property_id
+	WHERE auctions.id = $1`, auctionColumns)
+
+	return m.get(query, id)
+}
+
+// GetForProperty fetches the scheduled auction of a property, or its latest closed auction if it has none.
+func (m AuctionModel) GetForProperty(propertyID int64) (*Auction, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM auctions
+	INNER JOIN properties ON properties.id = auctions.property_id
+	WHERE auctions.property_id = $1 AND auctions.status <> 'cancelled'
+	ORDER BY auctions.status = 'scheduled' DESC, auctions.id DESC
+	LIMIT 1`, auctionColumns)
+
+	return m.get(query, propertyID)
+}
+
+// get runs a query returning a single auction.
+func (m AuctionModel) get(query string, args ...interface{}) (*Auction, error) {
+	var auction Auction
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(auction.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &auction, nil
+}
+
+// GetBids returns the bids placed on an auction, highest first.
+func (m AuctionModel) GetBids(auctionID int64) ([]*Bid, error) {
+	query := `
+	SELECT id, created_at, auction_id, bidder_id, amount
+	FROM auction_bids
+	WHERE auction_id = $1
+	ORDER BY amount DESC, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, auctionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bids := []*Bid{}
+
+	for rows.Next() {
+		var bid Bid
+		err := rows.Scan(&bid.ID, &bid.CreatedAt, &bid.AuctionID, &bid.BidderID, &bid.Amount)
+		if err != nil {
+			return nil, err
+		}
+		bids = append(bids, &bid)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return bids, nil
+}
+
+// Cancel cancels a scheduled auction that has no bids, returning ErrEditConflict if it has changed since
+// it was fetched.
+func (m AuctionModel) Cancel(auction *Auction) error {
+	query := `
+	UPDATE auctions
+	SET status = 'cancelled', version = version + 1
+	WHERE id = $1 AND version = $2 AND status = 'scheduled' AND bid_count = 0
+	RETURNING version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, auction.ID, auction.Version).Scan(&auction.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	auction.Status = AuctionCancelled
+	auction.Live = false
+
+	return nil
+}
+
+// PlaceBid places a bid on an auction. The auctioned property and the auction rows are locked for the
+// duration of the transaction, so that concurrent bids are checked against the bid placed before them and
+// applied one at a time, and so that the property cannot change status meanwhile. ErrPropertyUnavailable
+// is returned if the property is no longer published. The auction is refreshed with its locked state, so
+// that it holds the current minimum bid when ErrBidTooLow is returned, and with the outcome of the bid
+// otherwise. The ID of the user who was outbid, if any, is returned.
+func (m AuctionModel) PlaceBid(auction *Auction, bid *Bid) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The property is locked before the auction, in the same order as OfferModel.Accept and CloseDue.
+	var status string
+
+	query := `
+	SELECT properties.status
+	FROM auctions
+	INNER JOIN properties ON properties.id = auctions.property_id
+	WHERE auctions.id = $1
+	FOR UPDATE OF properties`
+
+	err = tx.QueryRowContext(ctx, query, bid.AuctionID).Scan(&status)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	if status != StatusPublished {
+		return 0, ErrPropertyUnavailable
+	}
+
+	query = fmt.Sprintf(`
+	SELECT %s
+	FROM auctions
+	INNER JOIN properties ON properties.id = auctions.property_id
+	WHERE auctions.id = $1
+	FOR UPDATE OF auctions`, auctionColumns)
+
+	err = tx.QueryRowContext(ctx, query, bid.AuctionID).Scan(auction.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	switch {
+	case !auction.Live:
+		return 0, ErrAuctionNotLive
+	case auction.CurrentBidderID == bid.BidderID:
+		return 0, ErrHighestBidder
+	case bid.Amount < auction.MinimumBid:
+		return 0, ErrBidTooLow
+	}
+
+	query = `
+	INSERT INTO auction_bids (auction_id, bidder_id, amount)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, bid.AuctionID, bid.BidderID, bid.Amount).Scan(&bid.ID, &bid.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `
+	UPDATE auctions
+	SET current_bid = $1, current_bidder_id = $2, bid_count = bid_count + 1,
+		ends_at = greatest(ends_at, NOW() + make_interval(secs => extension_seconds)), version = version + 1
+	WHERE id = $3
+	RETURNING ends_at, bid_count, version`
+
+	err = tx.QueryRowContext(ctx, query, bid.Amount, bid.BidderID, bid.AuctionID).Scan(&auction.EndsAt, &auction.BidCount, &auction.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	outbidID := auction.CurrentBidderID
+
+	auction.CurrentBid = &bid.Amount
+	auction.CurrentBidderID = bid.BidderID
+	auction.MinimumBid = bid.Amount + auction.MinIncrement
+	auction.ReserveMet = bid.Amount >= auction.ReservePrice
+
+	return outbidID, nil
+}
+
+// CloseDue closes the scheduled auctions that have ended and returns them. An auction that met its reserve
+// price puts its property under offer if the property is still published, and only then is its highest
+// bidder the winner. The IDs of the properties put under offer are returned with the auctions.
+func (m AuctionModel) CloseDue() ([]*Auction, []int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE properties
+	SET status = $1, updated_at = NOW(), version = version + 1
+	WHERE status = $2 AND id IN (
+		SELECT property_id FROM auctions
+		WHERE status = 'scheduled' AND ends_at <= NOW() AND current_bid >= reserve_price)
+	RETURNING id`
+
+	rows, err := tx.QueryContext(ctx, query, StatusUnderOffer, StatusPublished)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	underOffer := []int64{}
+
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, nil, err
+		}
+		underOffer = append(underOffer, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	query = fmt.Sprintf(`
+	UPDATE auctions
+	SET status = 'closed', closed_at = NOW(), version = auctions.version + 1,
+		winner_id = CASE WHEN auctions.property_id = ANY($1) THEN auctions.current_bidder_id END
+	FROM properties
+	WHERE auctions.status = 'scheduled' AND auctions.ends_at <= NOW() AND properties.id = auctions.property_id
+	RETURNING %s`, auctionColumns)
+
+	rows, err = tx.QueryContext(ctx, query, pq.Array(underOffer))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	auctions := []*Auction{}
+
+	for rows.Next() {
+		var auction Auction
+		err := rows.Scan(auction.scanTargets()...)
+		if err != nil {
+			return nil, nil, err
+		}
+		auctions = append(auctions, &auction)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return auctions, underOffer, tx.Commit()
+}
+
+// auctionColumns lists the auction columns in the order expected by Auction.scanTargets.
+const auctionColumns = `auctions.id, auctions.created_at, auctions.property_id, properties.title, coalesce(properties.user_id, 0),
+	auctions.starts_at, auctions.ends_at, auctions.scheduled_ends_at, auctions.starting_price, auctions.reserve_price,
+	auctions.min_increment, auctions.extension_seconds, auctions.current_bid, coalesce(auctions.current_bidder_id, 0),
+	auctions.bid_count, coalesce(auctions.current_bid + auctions.min_increment, auctions.starting_price),
+	coalesce(auctions.current_bid >= auctions.reserve_price, auctions.reserve_price = 0),
+	auctions.status = 'scheduled' AND auctions.starts_at <= NOW() AND NOW() < auctions.ends_at,
+	auctions.status, coalesce(auctions.winner_id, 0), auctions.closed_at, auctions.version`
+
+// scanTargets returns pointers to the auction fields in the order of auctionColumns.
+func (auction *Auction) scanTargets() []interface{} {
+	return []interface{}{
+		&auction.ID,
+		&auction.CreatedAt,
+		&auction.PropertyID,
+		&auction.PropertyTitle,
+		&auction.SellerID,
+		&auction.StartsAt,
+		&auction.EndsAt,
+		&auction.ScheduledEndsAt,
+		&auction.StartingPrice,
+		&auction.ReservePrice,
+		&auction.MinIncrement,
+		&auction.ExtensionSeconds,
+		&auction.CurrentBid,
+		&auction.CurrentBidderID,
+		&auction.BidCount,
+		&auction.MinimumBid,
+		&auction.ReserveMet,
+		&auction.Live,
+		&auction.Status,
+		&auction.WinnerID,
+		&auction.ClosedAt,
+		&auction.Version,
+	}
+}
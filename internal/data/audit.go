@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// AuditDetails contains the action-specific details of an audit log entry.
+type AuditDetails map[string]interface{}
+
+func (d AuditDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(d)
+}
+
+func (d *AuditDetails) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, &d)
+}
+
+// AuditEntry records an action taken by a user on an entity.
+type AuditEntry struct {
+	ID         int64        `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ActorID    int64        `json:"actor_id"`
+	Action     string       `json:"action"`
+	EntityType string       `json:"entity_type"`
+	EntityID   int64        `json:"entity_id"`
+	Details    AuditDetails `json:"details"`
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx, so that audit entries can be written as part of
+// the transaction that performs the audited action.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertAuditEntry inserts a new record into the audit_log table.
+func insertAuditEntry(ctx context.Context, db queryRower, entry *AuditEntry) error {
+	query := `
+	INSERT INTO audit_log (actor_id, action, entity_type, entity_id, details)
+	VALUES (NULLIF($1, 0), $2, $3, $4, $5)
+	RETURNING id, created_at`
+
+	args := []interface{}{entry.ActorID, entry.Action, entry.EntityType, entry.EntityID, entry.Details}
+
+	return db.QueryRowContext(ctx, query, args...).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// AuditLogModel struct wraps a sql.DB connection pool.
+type AuditLogModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a new record into the audit_log table.
+func (m AuditLogModel) Insert(entry *AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertAuditEntry(ctx, m.DB, entry)
+}
+
+// GetAll returns a paginated list of audit log entries, newest first. An empty entityType and a zero entityID
+// match any entity.
+func (m AuditLogModel) GetAll(entityType string, entityID int64, filters Filters) ([]*AuditEntry, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), id, created_at, coalesce(actor_id, 0), action, entity_type, entity_id, details
+	FROM audit_log
+	WHERE (entity_type = $1 OR $1 = '')
+	AND (entity_id = $2 OR $2 = 0)
+	ORDER BY created_at DESC, id DESC
+	LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, entityType, entityID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := []*AuditEntry{}
+
+	for rows.Next() {
+		var entry AuditEntry
+		err := rows.Scan(
+			&totalRecords,
+			&entry.ID,
+			&entry.CreatedAt,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.EntityType,
+			&entry.EntityID,
+			&entry.Details,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return entries, metadata, nil
+}
@@ -0,0 +1,218 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// AvailabilityRule contains a weekly window in which an agent is available for viewings, in the agent's
+// time zone. Weekday counts from Sunday (0) to Saturday (6) and times are written as HH:MM.
+type AvailabilityRule struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	AgentID     int64     `json:"-"`
+	Weekday     int       `json:"weekday"`
+	StartTime   string    `json:"start_time"`
+	EndTime     string    `json:"end_time"`
+	TimeZone    string    `json:"time_zone"`
+	SlotMinutes int       `json:"slot_minutes"`
+	Version     int32     `json:"version"`
+}
+
+// ValidateAvailabilityRule validates an availability rule based on set validation criteria.
+func ValidateAvailabilityRule(v *validator.Validator, rule *AvailabilityRule) {
+	v.Check(rule.Weekday >= 0 && rule.Weekday <= 6, "weekday", "must be between 0 (Sunday) and 6 (Saturday)")
+
+	start, startErr := time.Parse("15:04", rule.StartTime)
+	end, endErr := time.Parse("15:04", rule.EndTime)
+	v.Check(startErr == nil, "start_time", "must be a time in the HH:MM format")
+	v.Check(endErr == nil, "end_time", "must be a time in the HH:MM format")
+	if startErr == nil && endErr == nil {
+		v.Check(start.Before(end), "end_time", "must be after the start time")
+		v.Check(end.Sub(start) >= time.Duration(rule.SlotMinutes)*time.Minute, "end_time", "must leave room for at least one slot")
+	}
+
+	ValidateTimeZone(v, rule.TimeZone)
+	v.Check(rule.SlotMinutes >= 15, "slot_minutes", "must be at least 15")
+	v.Check(rule.SlotMinutes <= 240, "slot_minutes", "must not be more than 240")
+}
+
+// ValidateTimeZone validates an IANA time zone name such as Europe/London.
+func ValidateTimeZone(v *validator.Validator, timeZone string) {
+	v.Check(timeZone != "", "time_zone", "must be provided")
+	if timeZone != "" {
+		_, err := time.LoadLocation(timeZone)
+		v.Check(err == nil && timeZone != "Local", "time_zone", "must be a valid IANA time zone")
+	}
+}
+
+// Blackout contains a date on which an agent is not available for viewings, whatever their weekly rules.
+// The date is in the time zone of the agent's rules.
+type Blackout struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	AgentID   int64     `json:"-"`
+	Date      string    `json:"date"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// ValidateBlackout validates a blackout date based on set validation criteria.
+func ValidateBlackout(v *validator.Validator, blackout *Blackout) {
+	_, err := time.Parse("2006-01-02", blackout.Date)
+	v.Check(err == nil, "date", "must be a date in the YYYY-MM-DD format")
+	v.Check(len(blackout.Reason) <= 200, "reason", "must not be more than 200 bytes long")
+}
+
+// AvailabilityModel struct wraps a sql.DB connection pool.
+type AvailabilityModel struct {
+	DB *sql.DB
+}
+
+// InsertRule inserts a new record into the availability_rules table.
+func (m AvailabilityModel) InsertRule(rule *AvailabilityRule) error {
+	query := `
+	INSERT INTO availability_rules (agent_id, weekday, start_time, end_time, time_zone, slot_minutes)
+	VALUES ($1, $2, $3::time, $4::time, $5, $6)
+	RETURNING id, created_at, version`
+
+	args := []interface{}{rule.AgentID, rule.Weekday, rule.StartTime, rule.EndTime, rule.TimeZone, rule.SlotMinutes}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&rule.ID, &rule.CreatedAt, &rule.Version)
+}
+
+// GetRulesForAgent returns the availability rules of an agent, ordered by weekday and start time.
+func (m AvailabilityModel) GetRulesForAgent(agentID int64) ([]*AvailabilityRule, error) {
+	query := `
+	SELECT id, created_at, agent_id, weekday, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'), time_zone, slot_minutes, version
+	FROM availability_rules
+	WHERE agent_id = $1
+	ORDER BY weekday, start_time, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*AvailabilityRule{}
+
+	for rows.Next() {
+		var rule AvailabilityRule
+		err := rows.Scan(
+			&rule.ID,
+			&rule.CreatedAt,
+			&rule.AgentID,
+			&rule.Weekday,
+			&rule.StartTime,
+			&rule.EndTime,
+			&rule.TimeZone,
+			&rule.SlotMinutes,
+			&rule.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// DeleteRule deletes a specific availability rule belonging to an agent.
+func (m AvailabilityModel) DeleteRule(id, agentID int64) error {
+	return m.delete(`DELETE FROM availability_rules WHERE id = $1 AND agent_id = $2`, id, agentID)
+}
+
+// InsertBlackout inserts a new record into the availability_blackouts table. Adding a date that is
+// already blacked out replaces its reason.
+func (m AvailabilityModel) InsertBlackout(blackout *Blackout) error {
+	query := `
+	INSERT INTO availability_blackouts (agent_id, date, reason)
+	VALUES ($1, $2::date, $3)
+	ON CONFLICT (agent_id, date) DO UPDATE SET reason = EXCLUDED.reason
+	RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, blackout.AgentID, blackout.Date, blackout.Reason).Scan(&blackout.ID, &blackout.CreatedAt)
+}
+
+// GetBlackoutsForAgent returns the blackout dates of an agent from a date onwards.
+func (m AvailabilityModel) GetBlackoutsForAgent(agentID int64, from time.Time) ([]*Blackout, error) {
+	query := `
+	SELECT id, created_at, agent_id, to_char(date, 'YYYY-MM-DD'), reason
+	FROM availability_blackouts
+	WHERE agent_id = $1 AND date >= $2::date
+	ORDER BY date`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, agentID, from.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blackouts := []*Blackout{}
+
+	for rows.Next() {
+		var blackout Blackout
+		err := rows.Scan(&blackout.ID, &blackout.CreatedAt, &blackout.AgentID, &blackout.Date, &blackout.Reason)
+		if err != nil {
+			return nil, err
+		}
+		blackouts = append(blackouts, &blackout)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return blackouts, nil
+}
+
+// DeleteBlackout deletes a specific blackout date belonging to an agent.
+func (m AvailabilityModel) DeleteBlackout(id, agentID int64) error {
+	return m.delete(`DELETE FROM availability_blackouts WHERE id = $1 AND agent_id = $2`, id, agentID)
+}
+
+// delete runs a delete query for a record belonging to an agent, returning ErrRecordNotFound if no record was deleted.
+func (m AvailabilityModel) delete(query string, id, agentID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, agentID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
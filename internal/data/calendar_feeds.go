@@ -0,0 +1,270 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ErrDuplicateFeed is returned when a calendar feed is added twice to the same property.
+var ErrDuplicateFeed = errors.New("duplicate feed")
+
+// CalendarFeed contains an external iCalendar feed whose events are imported as blocks of a short-let property,
+// so that bookings taken on other platforms are not double booked. ETag and LastModified hold the validators
+// of the last response, sent with the next request so that unchanged feeds are not downloaded again.
+type CalendarFeed struct {
+	ID           int64      `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	PropertyID   int64      `json:"property_id"`
+	Name         string     `json:"name,omitempty"`
+	URL          string     `json:"url"`
+	ETag         string     `json:"-"`
+	LastModified string     `json:"-"`
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+	LastError    string     `json:"last_error,omitempty"`
+	Version      int32      `json:"version"`
+}
+
+// ValidateCalendarFeed validates a calendar feed based on set validation criteria.
+func ValidateCalendarFeed(v *validator.Validator, feed *CalendarFeed) {
+	v.Check(feed.URL != "", "url", "must be provided")
+	v.Check(len(feed.URL) <= 2000, "url", "must not be more than 2000 bytes long")
+
+	u, err := url.Parse(feed.URL)
+	v.Check(err == nil && validator.In(u.Scheme, "http", "https") && u.Host != "", "url", "must be an http or https URL")
+
+	v.Check(len(feed.Name) <= 100, "name", "must not be more than 100 bytes long")
+}
+
+// FeedSyncResult contains the number of blocks changed by a calendar feed sync.
+type FeedSyncResult struct {
+	Upserted int64 `json:"upserted"`
+	Removed  int64 `json:"removed"`
+}
+
+// CalendarFeedModel struct wraps a sql.DB connection pool.
+type CalendarFeedModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a new record into the calendar_feeds table, returning ErrDuplicateFeed if the property already has the feed.
+func (m CalendarFeedModel) Insert(feed *CalendarFeed) error {
+	query := `
+	INSERT INTO calendar_feeds (property_id, name, url)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (property_id, url) DO NOTHING
+	RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, feed.PropertyID, feed.Name, feed.URL).Scan(&feed.ID, &feed.CreatedAt, &feed.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrDuplicateFeed
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAllForProperty returns the calendar feeds of a property.
+func (m CalendarFeedModel) GetAllForProperty(propertyID int64) ([]*CalendarFeed, error) {
+	query := `
+	SELECT id, created_at, property_id, name, url, etag, last_modified, last_synced_at, last_error, version
+	FROM calendar_feeds
+	WHERE property_id = $1
+	ORDER BY id`
+
+	return m.query(query, propertyID)
+}
+
+// GetDue returns up to limit feeds that have not been synced since a point in time, least recently synced first.
+func (m CalendarFeedModel) GetDue(syncedBefore time.Time, limit int) ([]*CalendarFeed, error) {
+	query := `
+	SELECT id, created_at, property_id, name, url, etag, last_modified, last_synced_at, last_error, version
+	FROM calendar_feeds
+	WHERE last_synced_at IS NULL OR last_synced_at < $1
+	ORDER BY last_synced_at NULLS FIRST, id
+	LIMIT $2`
+
+	return m.query(query, syncedBefore, limit)
+}
+
+// query runs a query returning calendar feeds.
+func (m CalendarFeedModel) query(query string, args ...interface{}) ([]*CalendarFeed, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []*CalendarFeed{}
+
+	for rows.Next() {
+		var feed CalendarFeed
+		err := rows.Scan(
+			&feed.ID,
+			&feed.CreatedAt,
+			&feed.PropertyID,
+			&feed.Name,
+			&feed.URL,
+			&feed.ETag,
+			&feed.LastModified,
+			&feed.LastSyncedAt,
+			&feed.LastError,
+			&feed.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, &feed)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return feeds, nil
+}
+
+// Delete deletes a calendar feed of a property owned by a user, together with the blocks imported from it.
+func (m CalendarFeedModel) Delete(id, ownerID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM calendar_feeds
+	USING properties
+	WHERE calendar_feeds.id = $1 AND properties.id = calendar_feeds.property_id AND properties.user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Reconcile replaces the blocks imported from a feed with the blocks of its latest content in one transaction.
+// Blocks are matched by UID, so that events moved on the other platform update their block, and blocks whose
+// event is no longer in the feed are removed. The feed's sync time and response validators are recorded and
+// its last error cleared.
+func (m CalendarFeedModel) Reconcile(feed *CalendarFeed, blocks []*RentalBlock) (FeedSyncResult, error) {
+	var result FeedSyncResult
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO rental_blocks (property_id, start_date, end_date, kind, note, feed_id, uid)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (feed_id, uid) WHERE feed_id IS NOT NULL DO UPDATE
+	SET start_date = EXCLUDED.start_date, end_date = EXCLUDED.end_date, kind = EXCLUDED.kind, note = EXCLUDED.note
+	WHERE (rental_blocks.start_date, rental_blocks.end_date, rental_blocks.kind, rental_blocks.note)
+		IS DISTINCT FROM (EXCLUDED.start_date, EXCLUDED.end_date, EXCLUDED.kind, EXCLUDED.note)`
+
+	uids := make([]string, 0, len(blocks))
+
+	for _, block := range blocks {
+		res, err := tx.ExecContext(ctx, query, feed.PropertyID, block.StartDate, block.EndDate, block.Kind, block.Note, feed.ID, block.UID)
+		if err != nil {
+			return result, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return result, err
+		}
+
+		result.Upserted += n
+		uids = append(uids, block.UID)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM rental_blocks WHERE feed_id = $1 AND NOT uid = ANY($2)`, feed.ID, pq.Array(uids))
+	if err != nil {
+		return result, err
+	}
+
+	result.Removed, err = res.RowsAffected()
+	if err != nil {
+		return result, err
+	}
+
+	query = `
+	UPDATE calendar_feeds
+	SET etag = $1, last_modified = $2, last_synced_at = NOW(), last_error = '', version = version + 1
+	WHERE id = $3
+	RETURNING last_synced_at, version`
+
+	err = tx.QueryRowContext(ctx, query, feed.ETag, feed.LastModified, feed.ID).Scan(&feed.LastSyncedAt, &feed.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return result, ErrRecordNotFound
+		default:
+			return result, err
+		}
+	}
+
+	feed.LastError = ""
+
+	return result, tx.Commit()
+}
+
+// MarkSynced records a sync of a feed that left its blocks unchanged, either because the feed has not been
+// modified or because it could not be fetched, in which case the error is recorded.
+func (m CalendarFeedModel) MarkSynced(feed *CalendarFeed, syncErr string) error {
+	query := `
+	UPDATE calendar_feeds
+	SET last_synced_at = NOW(), last_error = $1, version = version + 1
+	WHERE id = $2
+	RETURNING last_synced_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, syncErr, feed.ID).Scan(&feed.LastSyncedAt, &feed.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	feed.LastError = syncErr
+
+	return nil
+}
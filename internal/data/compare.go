@@ -0,0 +1,130 @@
+package data
+
+import (
+	"sort"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// ComparedProperty contains the normalized values of a property in a comparison. Values that cannot be
+// computed, such as the price of a property in a currency without an exchange rate, are null.
+type ComparedProperty struct {
+	ID                  int64    `json:"id"`
+	Title               string   `json:"title"`
+	City                string   `json:"city"`
+	Location            string   `json:"location"`
+	Status              string   `json:"status"`
+	Price               *float64 `json:"price"`
+	OriginalPrice       float64  `json:"original_price"`
+	OriginalCurrency    string   `json:"original_currency"`
+	Size                *float64 `json:"size"`
+	PricePerSquareMetre *float64 `json:"price_per_square_metre"`
+}
+
+// Comparison is a side-by-side matrix of properties. Each entry of the Features and Amenities maps, and each
+// row and column of Distances, holds one value per property in the order of Properties.
+type Comparison struct {
+	Currency   string                   `json:"currency"`
+	Properties []*ComparedProperty      `json:"properties"`
+	Features   map[string][]interface{} `json:"features"`
+	Amenities  map[string][]bool        `json:"amenities"`
+	Distances  [][]*float64             `json:"distances_km"`
+}
+
+// ValidateComparison validates the property IDs and currency of a comparison request.
+func ValidateComparison(v *validator.Validator, ids []int64, currency string, rates ExchangeRates) {
+	v.Check(len(ids) >= 2, "ids", "must contain at least 2 properties")
+	v.Check(len(ids) <= 4, "ids", "must not contain more than 4 properties")
+	v.Check(rates.Supports(currency), "currency", "must be a supported currency")
+
+	seen := make(map[int64]bool)
+	for _, id := range ids {
+		v.Check(id > 0, "ids", "must contain valid property IDs")
+		v.Check(!seen[id], "ids", "must not contain duplicate values")
+		seen[id] = true
+	}
+}
+
+// NewComparison builds the comparison matrix of properties, with prices converted to a single currency.
+func NewComparison(properties []*Property, currency string, rates ExchangeRates) *Comparison {
+	comparison := &Comparison{
+		Currency:   currency,
+		Properties: make([]*ComparedProperty, len(properties)),
+		Features:   make(map[string][]interface{}),
+		Amenities:  make(map[string][]bool),
+		Distances:  make([][]*float64, len(properties)),
+	}
+
+	for i, property := range properties {
+		compared := &ComparedProperty{
+			ID:            property.ID,
+			Title:         property.Title,
+			City:          property.City,
+			Location:      property.Location,
+			Status:        property.Status,
+			OriginalPrice: property.Price,
+		}
+
+		if len(property.Currency) > 0 {
+			compared.OriginalCurrency = property.Currency[0]
+		}
+
+		if price, ok := property.PriceIn(currency, rates); ok {
+			compared.Price = &price
+		}
+
+		if size, ok := property.Features.Float(SizeFeatureKeys...); ok && size > 0 {
+			compared.Size = &size
+			if compared.Price != nil {
+				pricePerSquareMetre := *compared.Price / size
+				compared.PricePerSquareMetre = &pricePerSquareMetre
+			}
+		}
+
+		comparison.Properties[i] = compared
+
+		for key, value := range property.Features {
+			if _, ok := comparison.Features[key]; !ok {
+				comparison.Features[key] = make([]interface{}, len(properties))
+			}
+			comparison.Features[key][i] = value
+		}
+
+		for _, amenity := range property.Amenities {
+			if _, ok := comparison.Amenities[amenity]; !ok {
+				comparison.Amenities[amenity] = make([]bool, len(properties))
+			}
+			comparison.Amenities[amenity][i] = true
+		}
+
+		comparison.Distances[i] = make([]*float64, len(properties))
+		for j, other := range properties {
+			if distance, ok := property.DistanceTo(other); ok {
+				comparison.Distances[i][j] = &distance
+			}
+		}
+	}
+
+	return comparison
+}
+
+// SortByIDs orders properties to follow the order of the provided IDs. Properties whose ID is not listed are dropped.
+func SortByIDs(properties []*Property, ids []int64) []*Property {
+	position := make(map[int64]int, len(ids))
+	for i, id := range ids {
+		position[id] = i
+	}
+
+	sorted := make([]*Property, 0, len(properties))
+	for _, property := range properties {
+		if _, ok := position[property.ID]; ok {
+			sorted = append(sorted, property)
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return position[sorted[i].ID] < position[sorted[j].ID]
+	})
+
+	return sorted
+}
@@ -0,0 +1,148 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewComparison(t *testing.T) {
+	rates := ExchangeRates{"GBP": 1.25, "USD": 1}
+
+	properties := []*Property{
+		{
+			ID:        1,
+			Latitude:  51.5,
+			Longitude: -0.12,
+			Price:     400000,
+			Currency:  []string{"GBP"},
+			Features:  Features{"size": 100.0, "bedrooms": 2.0},
+			Amenities: []string{"parking"},
+		},
+		{
+			ID:        2,
+			Price:     500000,
+			Currency:  []string{"USD"},
+			Features:  Features{"area": "80 sqm"},
+			Amenities: []string{"parking", "garden"},
+		},
+		{
+			ID:       3,
+			Latitude: 51.5,
+			Price:    300000,
+			Currency: []string{"EUR"},
+			Features: Features{"size": 60.0},
+		},
+	}
+
+	comparison := NewComparison(properties, "USD", rates)
+
+	t.Run("Prices", func(t *testing.T) {
+		tests := []struct {
+			id                      int64
+			wantPrice               *float64
+			wantSize                *float64
+			wantPricePerSquareMetre *float64
+		}{
+			{id: 1, wantPrice: float(500000), wantSize: float(100), wantPricePerSquareMetre: float(5000)},
+			{id: 2, wantPrice: float(500000), wantSize: float(80), wantPricePerSquareMetre: float(6250)},
+			{id: 3, wantSize: float(60)},
+		}
+
+		for i, tt := range tests {
+			compared := comparison.Properties[i]
+			if compared.ID != tt.id {
+				t.Fatalf("got property %d at position %d; want %d", compared.ID, i, tt.id)
+			}
+			if !reflect.DeepEqual(compared.Price, tt.wantPrice) {
+				t.Errorf("property %d: got price %v; want %v", tt.id, deref(compared.Price), deref(tt.wantPrice))
+			}
+			if !reflect.DeepEqual(compared.Size, tt.wantSize) {
+				t.Errorf("property %d: got size %v; want %v", tt.id, deref(compared.Size), deref(tt.wantSize))
+			}
+			if !reflect.DeepEqual(compared.PricePerSquareMetre, tt.wantPricePerSquareMetre) {
+				t.Errorf("property %d: got price per square metre %v; want %v", tt.id, deref(compared.PricePerSquareMetre), deref(tt.wantPricePerSquareMetre))
+			}
+		}
+	})
+
+	t.Run("Features and amenities", func(t *testing.T) {
+		wantFeatures := map[string][]interface{}{
+			"size":     {100.0, nil, 60.0},
+			"bedrooms": {2.0, nil, nil},
+			"area":     {nil, "80 sqm", nil},
+		}
+		if !reflect.DeepEqual(comparison.Features, wantFeatures) {
+			t.Errorf("got features %v; want %v", comparison.Features, wantFeatures)
+		}
+
+		wantAmenities := map[string][]bool{
+			"parking": {true, true, false},
+			"garden":  {false, true, false},
+		}
+		if !reflect.DeepEqual(comparison.Amenities, wantAmenities) {
+			t.Errorf("got amenities %v; want %v", comparison.Amenities, wantAmenities)
+		}
+	})
+
+	t.Run("Distances", func(t *testing.T) {
+		for i := range properties {
+			for j := range properties {
+				distance := comparison.Distances[i][j]
+				switch {
+				case i == 1 || j == 1:
+					if distance != nil {
+						t.Errorf("got distance %v between %d and %d; want null", *distance, i, j)
+					}
+				case i == j:
+					if distance == nil || *distance != 0 {
+						t.Errorf("got distance %v from %d to itself; want 0", deref(distance), i)
+					}
+				default:
+					if distance == nil || *distance != *comparison.Distances[j][i] {
+						t.Errorf("got distances %v and %v between %d and %d; want equal", deref(distance), deref(comparison.Distances[j][i]), i, j)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestParseExchangeRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    ExchangeRates
+		wantErr bool
+	}{
+		{name: "Single rate", s: "USD=1", want: ExchangeRates{"USD": 1}},
+		{name: "Spaces and lower case", s: "usd=1, gbp=1.27", want: ExchangeRates{"USD": 1, "GBP": 1.27}},
+		{name: "Missing rate", s: "USD=1,GBP", wantErr: true},
+		{name: "Not a number", s: "USD=one", wantErr: true},
+		{name: "Zero rate", s: "USD=0", wantErr: true},
+		{name: "Negative rate", s: "USD=-1", wantErr: true},
+		{name: "Empty", s: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := ParseExchangeRates(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v; want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(rates, tt.want) {
+				t.Errorf("got %v; want %v", rates, tt.want)
+			}
+		})
+	}
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func deref(f *float64) interface{} {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
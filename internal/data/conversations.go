@@ -0,0 +1,296 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// Conversation contains a message thread between a prospective buyer or tenant and the agent or owner
+// of a listing. UnreadCount and LastMessage are relative to the user the conversation was fetched for.
+type Conversation struct {
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PropertyID    int64      `json:"property_id"`
+	PropertyTitle string     `json:"property_title"`
+	BuyerID       int64      `json:"buyer_id"`
+	BuyerName     string     `json:"buyer_name"`
+	AgentID       int64      `json:"agent_id"`
+	AgentName     string     `json:"agent_name"`
+	LastMessageAt time.Time  `json:"last_message_at"`
+	LastMessage   string     `json:"last_message,omitempty"`
+	UnreadCount   int        `json:"unread_count"`
+	Messages      []*Message `json:"messages,omitempty"`
+}
+
+// HasParticipant reports whether a user is one of the two participants of the conversation.
+func (c *Conversation) HasParticipant(userID int64) bool {
+	return userID != 0 && (userID == c.BuyerID || userID == c.AgentID)
+}
+
+// Recipient returns the participant of the conversation who receives the messages sent by the other.
+func (c *Conversation) Recipient(senderID int64) int64 {
+	if senderID == c.BuyerID {
+		return c.AgentID
+	}
+	return c.BuyerID
+}
+
+// Message contains a message posted in a conversation. ReadAt records when the recipient read it.
+type Message struct {
+	ID             int64      `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ConversationID int64      `json:"conversation_id"`
+	SenderID       int64      `json:"sender_id"`
+	Body           string     `json:"body"`
+	ReadAt         *time.Time `json:"read_at"`
+}
+
+// ValidateMessage validates a message based on set validation criteria.
+func ValidateMessage(v *validator.Validator, message *Message) {
+	v.Check(message.Body != "", "body", "must be provided")
+	v.Check(len(message.Body) <= 5000, "body", "must not be more than 5000 bytes long")
+}
+
+// ConversationModel struct wraps a sql.DB connection pool.
+type ConversationModel struct {
+	DB *sql.DB
+}
+
+// GetOrCreate returns the conversation between a buyer and the agent of a property, starting one if they
+// have not talked about the property before. An existing conversation is handed to the given agent if the
+// property has since been listed by someone else.
+func (m ConversationModel) GetOrCreate(propertyID, buyerID, agentID int64) (*Conversation, error) {
+	query := `
+	INSERT INTO conversations (property_id, buyer_id, agent_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (property_id, buyer_id) DO UPDATE SET agent_id = EXCLUDED.agent_id
+	RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+
+	err := m.DB.QueryRowContext(ctx, query, propertyID, buyerID, agentID).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Get(id)
+}
+
+// Get fetches a specific conversation.
+func (m ConversationModel) Get(id int64) (*Conversation, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM conversations
+	INNER JOIN properties ON properties.id = conversations.property_id
+	INNER JOIN users buyers ON buyers.id = conversations.buyer_id
+	INNER JOIN users agents ON agents.id = conversations.agent_id
+	WHERE conversations.id = $1`, conversationColumns)
+
+	var conversation Conversation
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(conversation.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &conversation, nil
+}
+
+// GetAllForUser returns a paginated list of the conversations a user takes part in, most recently active
+// first, with the last message of each and the number of messages the user has not read.
+func (m ConversationModel) GetAllForUser(userID int64, filters Filters) ([]*Conversation, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s,
+		coalesce((SELECT body FROM messages WHERE conversation_id = conversations.id ORDER BY created_at DESC, id DESC LIMIT 1), ''),
+		(SELECT count(*) FROM messages WHERE conversation_id = conversations.id AND sender_id <> $1 AND read_at IS NULL)
+	FROM conversations
+	INNER JOIN properties ON properties.id = conversations.property_id
+	INNER JOIN users buyers ON buyers.id = conversations.buyer_id
+	INNER JOIN users agents ON agents.id = conversations.agent_id
+	WHERE conversations.buyer_id = $1 OR conversations.agent_id = $1
+	ORDER BY conversations.last_message_at DESC, conversations.id DESC
+	LIMIT $2 OFFSET $3`, conversationColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	conversations := []*Conversation{}
+
+	for rows.Next() {
+		var conversation Conversation
+		targets := append([]interface{}{&totalRecords}, conversation.scanTargets()...)
+		err := rows.Scan(append(targets, &conversation.LastMessage, &conversation.UnreadCount)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		conversations = append(conversations, &conversation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return conversations, metadata, nil
+}
+
+// CountUnread returns the number of messages a user has not read across all of their conversations.
+func (m ConversationModel) CountUnread(userID int64) (int, error) {
+	query := `
+	SELECT count(*)
+	FROM messages
+	INNER JOIN conversations ON conversations.id = messages.conversation_id
+	WHERE (conversations.buyer_id = $1 OR conversations.agent_id = $1)
+	AND messages.sender_id <> $1
+	AND messages.read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// InsertMessage adds a message to a conversation and moves the conversation's last activity forward.
+func (m ConversationModel) InsertMessage(message *Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO messages (conversation_id, sender_id, body)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, message.ConversationID, message.SenderID, message.Body).Scan(&message.ID, &message.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE conversations SET last_message_at = $1 WHERE id = $2`, message.CreatedAt, message.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetMessages returns a paginated list of the messages in a conversation, oldest first.
+func (m ConversationModel) GetMessages(conversationID int64, filters Filters) ([]*Message, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), id, created_at, conversation_id, sender_id, body, read_at
+	FROM messages
+	WHERE conversation_id = $1
+	ORDER BY created_at ASC, id ASC
+	LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, conversationID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	messages := []*Message{}
+
+	for rows.Next() {
+		var message Message
+		err := rows.Scan(
+			&totalRecords,
+			&message.ID,
+			&message.CreatedAt,
+			&message.ConversationID,
+			&message.SenderID,
+			&message.Body,
+			&message.ReadAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		messages = append(messages, &message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return messages, metadata, nil
+}
+
+// MarkRead records that a user has read the messages sent to them in a conversation up to and including
+// the last message they were shown, returning the number of messages newly marked as read.
+func (m ConversationModel) MarkRead(conversationID, readerID int64, last *Message, readAt time.Time) (int64, error) {
+	query := `
+	UPDATE messages
+	SET read_at = $1
+	WHERE conversation_id = $2 AND sender_id <> $3 AND read_at IS NULL
+	AND (created_at, id) <= ($4, $5)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, readAt, conversationID, readerID, last.CreatedAt, last.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// conversationColumns lists the conversations columns in the order expected by Conversation.scanTargets.
+const conversationColumns = `conversations.id, conversations.created_at, conversations.property_id, properties.title,
+	conversations.buyer_id, buyers.name, conversations.agent_id, agents.name, conversations.last_message_at`
+
+// scanTargets returns pointers to the conversation fields in the order of conversationColumns.
+func (c *Conversation) scanTargets() []interface{} {
+	return []interface{}{
+		&c.ID,
+		&c.CreatedAt,
+		&c.PropertyID,
+		&c.PropertyTitle,
+		&c.BuyerID,
+		&c.BuyerName,
+		&c.AgentID,
+		&c.AgentName,
+		&c.LastMessageAt,
+	}
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExchangeRates maps a currency code to the value of one unit of that currency in a common base currency.
+type ExchangeRates map[string]float64
+
+// ParseExchangeRates parses a comma-separated list of CODE=rate pairs, such as "USD=1,GBP=1.27".
+func ParseExchangeRates(s string) (ExchangeRates, error) {
+	rates := make(ExchangeRates)
+
+	for _, pair := range strings.Split(s, ",") {
+		code, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid exchange rate %q", pair)
+		}
+
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q", pair)
+		}
+
+		rates[strings.ToUpper(code)] = rate
+	}
+
+	return rates, nil
+}
+
+// Supports returns true if there is an exchange rate for the currency.
+func (r ExchangeRates) Supports(currency string) bool {
+	_, ok := r[strings.ToUpper(currency)]
+	return ok
+}
+
+// Convert converts an amount between two currencies. It returns false if either currency has no exchange rate.
+func (r ExchangeRates) Convert(amount float64, from, to string) (float64, bool) {
+	fromRate, ok := r[strings.ToUpper(from)]
+	if !ok {
+		return 0, false
+	}
+
+	toRate, ok := r[strings.ToUpper(to)]
+	if !ok {
+		return 0, false
+	}
+
+	return amount * fromRate / toRate, true
+}
+
+// PriceIn returns the price of a property converted to a currency, and false if it cannot be converted.
+func (property *Property) PriceIn(currency string, rates ExchangeRates) (float64, bool) {
+	if len(property.Currency) == 0 {
+		return 0, false
+	}
+	return rates.Convert(property.Price, property.Currency[0], currency)
+}
@@ -0,0 +1,193 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+	"github.com/lib/pq"
+)
+
+// PermittedFacets lists the facets that can be requested alongside a property listing.
+var PermittedFacets = []string{"type", "category", "city", "amenities", "price_bucket"}
+
+// FacetValue contains the number of properties sharing a facet value.
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// PriceBucket contains the number of properties in a price range. A nil Min or Max means the range is open-ended.
+type PriceBucket struct {
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int      `json:"count"`
+}
+
+// ValidateFacets validates the requested facets and price histogram bucket boundaries.
+func ValidateFacets(v *validator.Validator, facets []string, priceBuckets []float64) {
+	for _, facet := range facets {
+		v.Check(validator.In(facet, PermittedFacets...), "facets", "contains an unknown facet")
+	}
+	v.Check(validator.Unique(facets), "facets", "must not contain duplicate values")
+	v.Check(len(priceBuckets) <= 20, "price_buckets", "must not contain more than 20 boundaries")
+	v.Check(sort.Float64sAreSorted(priceBuckets), "price_buckets", "must be in ascending order")
+	for i := 1; i < len(priceBuckets); i++ {
+		v.Check(priceBuckets[i] != priceBuckets[i-1], "price_buckets", "must not contain duplicate values")
+	}
+}
+
+// facetColumns maps a facet to the column it counts and whether that column is an array.
+var facetColumns = map[string]struct {
+	column string
+	array  bool
+}{
+	"type":      {"type", true},
+	"category":  {"category", true},
+	"city":      {"city", false},
+	"amenities": {"amenities", true},
+}
+
+// Facets returns per-value property counts for each requested facet. Every facet is computed over
+// the listing filters except its own, so that the counts show what selecting another value would return.
+func (p PropertyModel) Facets(propertyFilters PropertyFilters, facets []string, priceBuckets []float64) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(facets))
+
+	for _, facet := range facets {
+		if facet == "price_bucket" {
+			buckets, err := p.priceBuckets(propertyFilters.without(facet), priceBuckets)
+			if err != nil {
+				return nil, err
+			}
+			result[facet] = buckets
+			continue
+		}
+
+		values, err := p.facetValues(propertyFilters.without(facet), facet)
+		if err != nil {
+			return nil, err
+		}
+		result[facet] = values
+	}
+
+	return result, nil
+}
+
+// without returns a copy of the filters with the filter matching a facet cleared.
+func (f PropertyFilters) without(facet string) PropertyFilters {
+	switch facet {
+	case "type":
+		f.Type = nil
+	case "category":
+		f.Category = nil
+	case "city":
+		f.City = ""
+	case "amenities":
+		f.Amenities = nil
+	case "price_bucket":
+		f.MinPrice = 0
+		f.MaxPrice = 0
+	}
+	return f
+}
+
+// facetValues counts the properties per value of a type, category, city or amenities facet.
+func (p PropertyModel) facetValues(propertyFilters PropertyFilters, facet string) ([]*FacetValue, error) {
+	column := facetColumns[facet]
+
+	source := "properties"
+	value := column.column
+	if column.array {
+		source = fmt.Sprintf("properties, unnest(%s) AS value", column.column)
+		value = "value"
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s, count(*)
+	FROM %s
+	WHERE %s
+	GROUP BY 1
+	ORDER BY 2 DESC, 1 ASC
+	LIMIT 50`, value, source, propertyFilterClause)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query, propertyFilters.args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []*FacetValue{}
+
+	for rows.Next() {
+		var value FacetValue
+		err := rows.Scan(&value.Value, &value.Count)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, &value)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// priceBuckets counts the properties in each price range delimited by the bucket boundaries.
+// Ranges are inclusive of their lower bound, and the first and last ranges are open-ended.
+func (p PropertyModel) priceBuckets(propertyFilters PropertyFilters, boundaries []float64) ([]*PriceBucket, error) {
+	buckets := make([]*PriceBucket, len(boundaries)+1)
+	for i := range buckets {
+		buckets[i] = &PriceBucket{}
+		if i > 0 {
+			buckets[i].Min = &boundaries[i-1]
+		}
+		if i < len(boundaries) {
+			buckets[i].Max = &boundaries[i]
+		}
+	}
+
+	if len(boundaries) == 0 {
+		return buckets[:0], nil
+	}
+
+	query := fmt.Sprintf(`
+	SELECT width_bucket(price, $%d::numeric[]), count(*)
+	FROM properties
+	WHERE %s
+	GROUP BY 1`, propertyFilterArgs+1, propertyFilterClause)
+
+	args := append(propertyFilters.args(), pq.Array(boundaries))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket, count int
+		err := rows.Scan(&bucket, &count)
+		if err != nil {
+			return nil, err
+		}
+		if bucket >= 0 && bucket < len(buckets) {
+			buckets[bucket].Count = count
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
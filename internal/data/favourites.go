@@ -0,0 +1,188 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// FavouriteModel struct wraps a sql.DB connection pool.
+type FavouriteModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a property to a user's favourites. Adding a property that is already a favourite is a no-op.
+func (f FavouriteModel) Insert(userID, propertyID int64) error {
+	query := `
+	INSERT INTO favourites (user_id, property_id)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := f.DB.ExecContext(ctx, query, userID, propertyID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "favourites" violates foreign key constraint "favourites_property_id_fkey"`:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a property from a user's favourites.
+func (f FavouriteModel) Delete(userID, propertyID int64) error {
+	query := `
+	DELETE FROM favourites
+	WHERE user_id = $1 AND property_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := f.DB.ExecContext(ctx, query, userID, propertyID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForUser returns the full records of a user's favourite properties, most recently added first.
+// Favourites that are no longer published are left out unless the user owns them.
+func (f FavouriteModel) GetAllForUser(userID int64) ([]*Property, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM properties
+	INNER JOIN favourites ON favourites.property_id = properties.id
+	WHERE favourites.user_id = $1
+	AND (properties.status = 'published' OR properties.user_id = $1)
+	ORDER BY favourites.created_at DESC, properties.id DESC`, propertyColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := f.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := []*Property{}
+
+	for rows.Next() {
+		var property Property
+		err := rows.Scan(property.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		isFavourite := true
+		property.IsFavourite = &isFavourite
+		properties = append(properties, &property)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return properties, nil
+}
+
+// SetFlags sets the IsFavourite flag of each property according to whether it is one of the user's favourites.
+func (f FavouriteModel) SetFlags(userID int64, properties ...*Property) error {
+	if len(properties) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(properties))
+	for i, property := range properties {
+		ids[i] = property.ID
+	}
+
+	query := `
+	SELECT property_id
+	FROM favourites
+	WHERE user_id = $1 AND property_id = ANY($2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := f.DB.QueryContext(ctx, query, userID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	favourites := make(map[int64]bool)
+
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			return err
+		}
+		favourites[id] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, property := range properties {
+		isFavourite := favourites[property.ID]
+		property.IsFavourite = &isFavourite
+	}
+
+	return nil
+}
+
+// GetUsersForProperty returns the users who have added a property to their favourites.
+func (f FavouriteModel) GetUsersForProperty(propertyID int64) ([]*User, error) {
+	query := `
+	SELECT users.id, users.created_at, users.name, users.email
+	FROM users
+	INNER JOIN favourites ON favourites.user_id = users.id
+	WHERE favourites.property_id = $1
+	ORDER BY users.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := f.DB.QueryContext(ctx, query, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&user.ID, &user.CreatedAt, &user.Name, &user.Email)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -0,0 +1,33 @@
+package data
+
+import "math"
+
+// earthRadiusKm is the mean radius of the Earth in kilometres.
+const earthRadiusKm = 6371.0
+
+// Distance returns the great-circle distance in kilometres between two coordinates, using the haversine formula.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// HasCoordinates returns true if the property has been given a position. Properties created
+// without coordinates are stored at 0,0, which is treated as unknown.
+func (property *Property) HasCoordinates() bool {
+	return property.Latitude != 0 || property.Longitude != 0
+}
+
+// DistanceTo returns the distance in kilometres between two properties, and false if either has no coordinates.
+func (property *Property) DistanceTo(other *Property) (float64, bool) {
+	if !property.HasCoordinates() || !other.HasCoordinates() {
+		return 0, false
+	}
+	return Distance(property.Latitude, property.Longitude, other.Latitude, other.Longitude), true
+}
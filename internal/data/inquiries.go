@@ -0,0 +1,211 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// PhoneRX matches phone numbers made of digits, spaces and common separators, with an optional leading +.
+var PhoneRX = regexp.MustCompile(`^\+?[0-9][0-9 ()\-.]{5,19}$`)
+
+// Inquiry contains a message sent by a prospective buyer or tenant to the owner of a listing.
+type Inquiry struct {
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PropertyID    int64      `json:"property_id"`
+	PropertyTitle string     `json:"property_title,omitempty"`
+	OwnerID       int64      `json:"-"`
+	UserID        int64      `json:"-"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	Phone         string     `json:"phone,omitempty"`
+	Message       string     `json:"message"`
+	ContactedAt   *time.Time `json:"contacted_at"`
+	Held          bool       `json:"-"`
+	Version       int32      `json:"version"`
+}
+
+// ValidateInquiry validates an inquiry based on set validation criteria.
+func ValidateInquiry(v *validator.Validator, inquiry *Inquiry) {
+	v.Check(inquiry.Name != "", "name", "must be provided")
+	v.Check(len(inquiry.Name) <= 200, "name", "must not be more than 200 bytes long")
+	ValidateEmail(v, inquiry.Email)
+	v.Check(inquiry.Phone == "" || validator.Matches(inquiry.Phone, PhoneRX), "phone", "must be a valid phone number")
+	v.Check(inquiry.Message != "", "message", "must be provided")
+	v.Check(len(inquiry.Message) <= 5000, "message", "must not be more than 5000 bytes long")
+}
+
+// InquiryModel struct wraps a sql.DB connection pool.
+type InquiryModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a new record into the inquiries table.
+func (m InquiryModel) Insert(inquiry *Inquiry) error {
+	query := `
+	INSERT INTO inquiries (property_id, owner_id, user_id, name, email, phone, message, held)
+	VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, $7, $8)
+	RETURNING id, created_at, version`
+
+	args := []interface{}{inquiry.PropertyID, inquiry.OwnerID, inquiry.UserID, inquiry.Name, inquiry.Email, inquiry.Phone, inquiry.Message, inquiry.Held}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&inquiry.ID, &inquiry.CreatedAt, &inquiry.Version)
+}
+
+// Get fetches a specific inquiry, including inquiries held for moderation.
+func (m InquiryModel) Get(id int64) (*Inquiry, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM inquiries
+	INNER JOIN properties ON properties.id = inquiries.property_id
+	WHERE inquiries.id = $1`, inquiryColumns)
+
+	var inquiry Inquiry
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(inquiry.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &inquiry, nil
+}
+
+// GetForOwner fetches an inquiry received by a specific owner. Inquiries held for moderation are not returned.
+func (m InquiryModel) GetForOwner(id, ownerID int64) (*Inquiry, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM inquiries
+	INNER JOIN properties ON properties.id = inquiries.property_id
+	WHERE inquiries.id = $1 AND inquiries.owner_id = $2 AND NOT inquiries.held`, inquiryColumns)
+
+	var inquiry Inquiry
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, ownerID).Scan(inquiry.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &inquiry, nil
+}
+
+// GetAllForOwner returns a paginated list of the inquiries received by an owner, newest first, excluding those held
+// for moderation. A non-nil contacted value restricts the list to the inquiries that have or have not been marked
+// as contacted.
+func (m InquiryModel) GetAllForOwner(ownerID int64, contacted *bool, filters Filters) ([]*Inquiry, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM inquiries
+	INNER JOIN properties ON properties.id = inquiries.property_id
+	WHERE inquiries.owner_id = $1
+	AND NOT inquiries.held
+	AND ($2::boolean IS NULL OR (inquiries.contacted_at IS NOT NULL) = $2)
+	ORDER BY inquiries.created_at DESC, inquiries.id DESC
+	LIMIT $3 OFFSET $4`, inquiryColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, ownerID, contacted, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	inquiries := []*Inquiry{}
+
+	for rows.Next() {
+		var inquiry Inquiry
+		err := rows.Scan(append([]interface{}{&totalRecords}, inquiry.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		inquiries = append(inquiries, &inquiry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return inquiries, metadata, nil
+}
+
+// Update updates the contacted status of an inquiry.
+func (m InquiryModel) Update(inquiry *Inquiry) error {
+	query := `
+	UPDATE inquiries
+	SET contacted_at = $1, version = version + 1
+	WHERE id = $2 AND version = $3
+	RETURNING version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, inquiry.ContactedAt, inquiry.ID, inquiry.Version).Scan(&inquiry.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inquiryColumns lists the inquiries columns in the order expected by Inquiry.scanTargets.
+const inquiryColumns = `inquiries.id, inquiries.created_at, inquiries.property_id, properties.title, coalesce(inquiries.owner_id, 0), coalesce(inquiries.user_id, 0), inquiries.name, inquiries.email, inquiries.phone, inquiries.message, inquiries.contacted_at, inquiries.version`
+
+// scanTargets returns pointers to the inquiry fields in the order of inquiryColumns.
+func (inquiry *Inquiry) scanTargets() []interface{} {
+	return []interface{}{
+		&inquiry.ID,
+		&inquiry.CreatedAt,
+		&inquiry.PropertyID,
+		&inquiry.PropertyTitle,
+		&inquiry.OwnerID,
+		&inquiry.UserID,
+		&inquiry.Name,
+		&inquiry.Email,
+		&inquiry.Phone,
+		&inquiry.Message,
+		&inquiry.ContactedAt,
+		&inquiry.Version,
+	}
+}
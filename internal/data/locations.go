@@ -0,0 +1,99 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// LocationSuggestion contains a city or neighbourhood suggested for a search term.
+type LocationSuggestion struct {
+	Kind     string  `json:"kind"`
+	Name     string  `json:"name"`
+	City     string  `json:"city"`
+	Listings int     `json:"listings"`
+	Score    float64 `json:"score"`
+}
+
+// ValidateAutocompleteQuery validates a location autocomplete search term and limit.
+func ValidateAutocompleteQuery(v *validator.Validator, q string, limit int) {
+	v.Check(strings.TrimSpace(q) != "", "q", "must be provided")
+	v.Check(len(q) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+}
+
+// LocationModel struct wraps a sql.DB connection pool.
+type LocationModel struct {
+	DB *sql.DB
+}
+
+// Autocomplete returns the distinct cities and locations of published listings that match a search term,
+// ranked so that prefix matches come first, followed by the closest trigram matches and the most listings.
+// Spellings that differ only in case or accents are counted together under their most common spelling.
+func (l LocationModel) Autocomplete(q string, limit int) ([]*LocationSuggestion, error) {
+	query := `
+	WITH candidates AS (
+		SELECT 'city' AS kind, mode() WITHIN GROUP (ORDER BY city) AS name, mode() WITHIN GROUP (ORDER BY city) AS city,
+			count(*) AS listings, immutable_unaccent(lower(city)) AS normalized
+		FROM properties
+		WHERE status = 'published'
+		AND (immutable_unaccent(lower(city)) LIKE immutable_unaccent(lower($2)) || '%'
+			OR immutable_unaccent(lower(city)) % immutable_unaccent(lower($1)))
+		GROUP BY immutable_unaccent(lower(city))
+		UNION ALL
+		SELECT 'location' AS kind, mode() WITHIN GROUP (ORDER BY location) AS name, mode() WITHIN GROUP (ORDER BY city) AS city,
+			count(*) AS listings, immutable_unaccent(lower(location)) AS normalized
+		FROM properties
+		WHERE status = 'published'
+		AND (immutable_unaccent(lower(location)) LIKE immutable_unaccent(lower($2)) || '%'
+			OR immutable_unaccent(lower(location)) % immutable_unaccent(lower($1)))
+		GROUP BY immutable_unaccent(lower(location)), immutable_unaccent(lower(city))
+	)
+	SELECT kind, name, city, listings, similarity(normalized, immutable_unaccent(lower($1))) AS score
+	FROM candidates
+	ORDER BY normalized LIKE immutable_unaccent(lower($2)) || '%' DESC, score DESC, listings DESC, name ASC
+	LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	q = strings.TrimSpace(q)
+
+	rows, err := l.DB.QueryContext(ctx, query, q, escapeLike(q), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*LocationSuggestion{}
+
+	for rows.Next() {
+		var suggestion LocationSuggestion
+		err := rows.Scan(
+			&suggestion.Kind,
+			&suggestion.Name,
+			&suggestion.City,
+			&suggestion.Listings,
+			&suggestion.Score,
+		)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// escapeLike escapes the characters that have a special meaning in a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
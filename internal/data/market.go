@@ -0,0 +1,115 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// MarketStat contains the market statistics of the listings created in a period. Listings that were never
+// published, or that are awaiting review, are not counted.
+type MarketStat struct {
+	PeriodStart        time.Time `json:"period_start"`
+	City               string    `json:"city"`
+	Location           string    `json:"location,omitempty"`
+	Type               string    `json:"type,omitempty"`
+	Currency           string    `json:"currency"`
+	NewListings        int       `json:"new_listings"`
+	MedianPrice        float64   `json:"median_price"`
+	MeanPrice          float64   `json:"mean_price"`
+	MedianPricePerSqm  *float64  `json:"median_price_per_sqm"`
+	AvgDaysOnMarket    float64   `json:"avg_days_on_market"`
+	PriceReductionRate float64   `json:"price_reduction_rate"`
+}
+
+// MarketStatsQuery contains the criteria of a market statistics request. An empty Location, Type or
+// Currency returns statistics aggregated over every neighbourhood or type, or for every currency.
+type MarketStatsQuery struct {
+	City     string
+	Location string
+	Type     string
+	Currency string
+	Period   string
+	Periods  int
+}
+
+// ValidateMarketStatsQuery validates the criteria of a market statistics request.
+func ValidateMarketStatsQuery(v *validator.Validator, q MarketStatsQuery) {
+	v.Check(q.City != "", "city", "must be provided")
+	v.Check(validator.In(q.Period, "weekly", "monthly"), "period", "must be weekly or monthly")
+	v.Check(q.Periods > 0, "periods", "must be greater than zero")
+	v.Check(q.Periods <= 60, "periods", "must be a maximum of 60")
+}
+
+// MarketStatModel struct wraps a sql.DB connection pool.
+type MarketStatModel struct {
+	DB *sql.DB
+}
+
+// Get returns the statistics of the most recent periods matching the query, oldest first.
+func (m MarketStatModel) Get(q MarketStatsQuery) ([]*MarketStat, error) {
+	query := `
+	SELECT period_start, city, location, type, currency, new_listings, median_price, mean_price, median_price_per_sqm, avg_days_on_market, price_reduction_rate
+	FROM (
+		SELECT *, dense_rank() OVER (ORDER BY period_start DESC) AS recency
+		FROM market_stats
+		WHERE period = $1
+		AND lower(city) = lower($2)
+		AND lower(location) = lower($3)
+		AND lower(type) = lower($4)
+		AND (upper(currency) = upper($5) OR $5 = '')
+	) stats
+	WHERE recency <= $6
+	ORDER BY period_start ASC, currency ASC`
+
+	args := []interface{}{q.Period, q.City, q.Location, q.Type, q.Currency, q.Periods}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []*MarketStat{}
+
+	for rows.Next() {
+		var stat MarketStat
+		err := rows.Scan(
+			&stat.PeriodStart,
+			&stat.City,
+			&stat.Location,
+			&stat.Type,
+			&stat.Currency,
+			&stat.NewListings,
+			&stat.MedianPrice,
+			&stat.MeanPrice,
+			&stat.MedianPricePerSqm,
+			&stat.AvgDaysOnMarket,
+			&stat.PriceReductionRate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, &stat)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// Refresh recomputes the market statistics without blocking concurrent reads.
+func (m MarketStatModel) Refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY market_stats`)
+	return err
+}
@@ -7,17 +7,67 @@ import (
 
 var (
 	ErrRecordNotFound = errors.New("record not found")
-	ErrEditConflict = errors.New("edit conflict")
+	ErrEditConflict   = errors.New("edit conflict")
 )
 
 // Models is a 'container' struct to wrap all models of the application.
 type Models struct {
-	Properties PropertyModel
+	Agencies           AgencyModel
+	Agents             AgentModel
+	AuditLog           AuditLogModel
+	Auctions           AuctionModel
+	Availability       AvailabilityModel
+	CalendarFeeds      CalendarFeedModel
+	Conversations      ConversationModel
+	Favourites         FavouriteModel
+	Inquiries          InquiryModel
+	Locations          LocationModel
+	MarketStats        MarketStatModel
+	Moderation         ModerationModel
+	Offers             OfferModel
+	OpenHouses         OpenHouseModel
+	Permissions        PermissionModel
+	Properties         PropertyModel
+	Rentals            RentalModel
+	RentalApplications RentalApplicationModel
+	Reports            ReportModel
+	SavedSearches      SavedSearchModel
+	Tokens             TokenModel
+	Users              UserModel
+	Valuations         ValuationModel
+	Verifications      VerificationModel
+	Viewings           ViewingModel
+	Views              ViewModel
 }
 
-// NewModels returns a models struct containing an uninitialised property model.
+// NewModels returns a models struct containing the initialised models.
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Properties: PropertyModel{DB: db},
+		Agencies:           AgencyModel{DB: db},
+		Agents:             AgentModel{DB: db},
+		AuditLog:           AuditLogModel{DB: db},
+		Auctions:           AuctionModel{DB: db},
+		Availability:       AvailabilityModel{DB: db},
+		CalendarFeeds:      CalendarFeedModel{DB: db},
+		Conversations:      ConversationModel{DB: db},
+		Favourites:         FavouriteModel{DB: db},
+		Inquiries:          InquiryModel{DB: db},
+		Locations:          LocationModel{DB: db},
+		MarketStats:        MarketStatModel{DB: db},
+		Moderation:         ModerationModel{DB: db},
+		Offers:             OfferModel{DB: db},
+		OpenHouses:         OpenHouseModel{DB: db},
+		Permissions:        PermissionModel{DB: db},
+		Properties:         PropertyModel{DB: db},
+		Rentals:            RentalModel{DB: db},
+		RentalApplications: RentalApplicationModel{DB: db},
+		Reports:            ReportModel{DB: db},
+		SavedSearches:      SavedSearchModel{DB: db},
+		Tokens:             TokenModel{DB: db},
+		Users:              UserModel{DB: db},
+		Valuations:         ValuationModel{DB: db},
+		Verifications:      VerificationModel{DB: db},
+		Viewings:           ViewingModel{DB: db},
+		Views:              ViewModel{DB: db},
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,323 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Moderation item types.
+const (
+	ModerationItemProperty = "property"
+	ModerationItemInquiry  = "inquiry"
+)
+
+// Moderation item statuses.
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+)
+
+// ModerationItem contains a listing or inquiry held for review, with the score and reasons it was held for
+// and, once reviewed, the moderator's decision.
+type ModerationItem struct {
+	ID             int64      `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ItemType       string     `json:"item_type"`
+	ItemID         int64      `json:"item_id"`
+	Summary        string     `json:"summary"`
+	Score          float64    `json:"score"`
+	Reasons        []string   `json:"reasons"`
+	Status         string     `json:"status"`
+	ModeratorID    int64      `json:"moderator_id,omitempty"`
+	DecisionReason string     `json:"decision_reason,omitempty"`
+	DecidedAt      *time.Time `json:"decided_at,omitempty"`
+	Version        int32      `json:"version"`
+}
+
+// ValidateModerationQuery validates the status and item type a moderation queue is filtered by.
+func ValidateModerationQuery(v *validator.Validator, status, itemType string) {
+	v.Check(validator.In(status, ModerationPending, ModerationApproved, ModerationRejected), "status", "must be pending, approved or rejected")
+	v.Check(itemType == "" || validator.In(itemType, ModerationItemProperty, ModerationItemInquiry), "item_type", "must be property or inquiry")
+}
+
+// ValidateModerationDecision validates the reason given for a moderation decision. A reason is required
+// when rejecting an item.
+func ValidateModerationDecision(v *validator.Validator, decision, reason string) {
+	v.Check(decision != ModerationRejected || reason != "", "reason", "must be provided")
+	v.Check(len(reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+}
+
+// ModerationModel struct wraps a sql.DB connection pool.
+type ModerationModel struct {
+	DB *sql.DB
+}
+
+// Insert adds an item to the moderation queue. If the item is already awaiting review, its reasons are
+// merged into the pending entry and the higher of the two scores is kept.
+func (m ModerationModel) Insert(item *ModerationItem) error {
+	query := `
+	INSERT INTO moderation_items (item_type, item_id, score, reasons)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (item_type, item_id) WHERE status = 'pending'
+	DO UPDATE SET score = GREATEST(moderation_items.score, EXCLUDED.score),
+		reasons = ARRAY(SELECT DISTINCT unnest(moderation_items.reasons || EXCLUDED.reasons)),
+		version = moderation_items.version + 1
+	RETURNING id, created_at, score, reasons, status, version`
+
+	args := []interface{}{item.ItemType, item.ItemID, item.Score, pq.Array(nonNil(item.Reasons))}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&item.ID, &item.CreatedAt, &item.Score, pq.Array(&item.Reasons), &item.Status, &item.Version)
+}
+
+// Get fetches a specific moderation item.
+func (m ModerationModel) Get(id int64) (*ModerationItem, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM moderation_items
+	LEFT JOIN properties ON moderation_items.item_type = 'property' AND properties.id = moderation_items.item_id
+	LEFT JOIN inquiries ON moderation_items.item_type = 'inquiry' AND inquiries.id = moderation_items.item_id
+	WHERE moderation_items.id = $1`, moderationItemColumns)
+
+	var item ModerationItem
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(item.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &item, nil
+}
+
+// GetAll returns a paginated list of the moderation items with a status, oldest first. An empty itemType
+// matches both listings and inquiries.
+func (m ModerationModel) GetAll(status, itemType string, filters Filters) ([]*ModerationItem, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM moderation_items
+	LEFT JOIN properties ON moderation_items.item_type = 'property' AND properties.id = moderation_items.item_id
+	LEFT JOIN inquiries ON moderation_items.item_type = 'inquiry' AND inquiries.id = moderation_items.item_id
+	WHERE moderation_items.status = $1
+	AND (moderation_items.item_type = $2 OR $2 = '')
+	ORDER BY moderation_items.created_at ASC, moderation_items.id ASC
+	LIMIT $3 OFFSET $4`, moderationItemColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, status, itemType, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*ModerationItem{}
+
+	for rows.Next() {
+		var item ModerationItem
+		err := rows.Scan(append([]interface{}{&totalRecords}, item.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return items, metadata, nil
+}
+
+// Decide records a moderator's decision on a pending item and applies it in a single transaction. Approving
+// a listing publishes it and approving an inquiry releases it to the listing owner. Rejecting a listing
+// unpublishes it, while a rejected inquiry stays held. The decision is written to the audit log. It returns
+// ErrEditConflict if the item has been changed or decided since it was fetched.
+func (m ModerationModel) Decide(item *ModerationItem, moderatorID int64, decision, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE moderation_items
+	SET status = $1, moderator_id = $2, decision_reason = $3, decided_at = NOW(), version = version + 1
+	WHERE id = $4 AND version = $5 AND status = 'pending'
+	RETURNING decided_at, version`
+
+	args := []interface{}{decision, moderatorID, reason, item.ID, item.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&item.DecidedAt, &item.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	switch {
+	case item.ItemType == ModerationItemProperty:
+		status := StatusPublished
+		if decision == ModerationRejected {
+			status = StatusUnpublished
+		}
+		_, err = tx.ExecContext(ctx, `
+		UPDATE properties
+		SET status = $1, updated_at = NOW(), version = version + 1
+		WHERE id = $2 AND status = $3`, status, item.ItemID, StatusPendingReview)
+		if err != nil {
+			return err
+		}
+		// The reports that took the listing down have been dealt with, so they no longer count
+		// towards the threshold.
+		_, err = tx.ExecContext(ctx, `
+		UPDATE listing_reports
+		SET resolved_at = NOW()
+		WHERE property_id = $1 AND resolved_at IS NULL`, item.ItemID)
+	case item.ItemType == ModerationItemInquiry && decision == ModerationApproved:
+		_, err = tx.ExecContext(ctx, `UPDATE inquiries SET held = false WHERE id = $1`, item.ItemID)
+	}
+	if err != nil {
+		return err
+	}
+
+	entry := &AuditEntry{
+		ActorID:    moderatorID,
+		Action:     "moderation." + decision,
+		EntityType: item.ItemType,
+		EntityID:   item.ItemID,
+		Details: AuditDetails{
+			"moderation_item_id": item.ID,
+			"score":              item.Score,
+			"flagged_reasons":    item.Reasons,
+			"reason":             reason,
+		},
+	}
+
+	err = insertAuditEntry(ctx, tx, entry)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	item.Status = decision
+	item.ModeratorID = moderatorID
+	item.DecisionReason = reason
+
+	return nil
+}
+
+// LatestStatus returns the status of the most recent moderation item of a listing or inquiry, or an empty
+// string if it has never been held for review.
+func (m ModerationModel) LatestStatus(itemType string, itemID int64) (string, error) {
+	query := `
+	SELECT status
+	FROM moderation_items
+	WHERE item_type = $1 AND item_id = $2
+	ORDER BY created_at DESC, id DESC
+	LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var status string
+	err := m.DB.QueryRowContext(ctx, query, itemType, itemID).Scan(&status)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	return status, nil
+}
+
+// CountRecentSubmissions counts the listings or inquiries created since a point in time by the same email
+// address or user, or with the same text. The text of a listing is its title and description separated by a
+// newline. It implements screening.SubmissionCounter.
+func (m ModerationModel) CountRecentSubmissions(kind, email string, userID int64, text string, since time.Time) (int, error) {
+	var query string
+	var args []interface{}
+
+	switch kind {
+	case ModerationItemInquiry:
+		query = `
+		SELECT count(*)
+		FROM inquiries
+		WHERE created_at >= $1
+		AND (email = $2 OR ($3 <> 0 AND user_id = $3) OR message = $4)`
+		args = []interface{}{since, email, userID, text}
+	case ModerationItemProperty:
+		query = `
+		SELECT count(*)
+		FROM properties
+		WHERE created_at >= $1
+		AND (($2 <> 0 AND user_id = $2) OR title || E'\n' || description = $3)`
+		args = []interface{}{since, userID, text}
+	default:
+		return 0, fmt.Errorf("unknown submission kind %q", kind)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&count)
+	return count, err
+}
+
+// moderationItemColumns lists the moderation_items columns in the order expected by ModerationItem.scanTargets.
+// The summary is the title of a listing or the start of an inquiry's message.
+const moderationItemColumns = `moderation_items.id, moderation_items.created_at, moderation_items.item_type, moderation_items.item_id,
+	coalesce(properties.title, left(inquiries.message, 200), ''), moderation_items.score, moderation_items.reasons,
+	moderation_items.status, coalesce(moderation_items.moderator_id, 0), moderation_items.decision_reason,
+	moderation_items.decided_at, moderation_items.version`
+
+// scanTargets returns pointers to the moderation item fields in the order of moderationItemColumns.
+func (item *ModerationItem) scanTargets() []interface{} {
+	return []interface{}{
+		&item.ID,
+		&item.CreatedAt,
+		&item.ItemType,
+		&item.ItemID,
+		&item.Summary,
+		&item.Score,
+		pq.Array(&item.Reasons),
+		&item.Status,
+		&item.ModeratorID,
+		&item.DecisionReason,
+		&item.DecidedAt,
+		&item.Version,
+	}
+}
@@ -0,0 +1,510 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrDuplicateOffer is returned when a buyer makes an offer on a property while another of their offers on it is open.
+	ErrDuplicateOffer = errors.New("duplicate offer")
+	// ErrPropertyUnavailable is returned when accepting an offer on, or bidding for, a property that is no
+	// longer published.
+	ErrPropertyUnavailable = errors.New("property unavailable")
+	// ErrPropertyAuctioned is returned when accepting an offer on a property with a scheduled auction.
+	ErrPropertyAuctioned = errors.New("property auctioned")
+)
+
+// Offer statuses. An offer is pending until it is accepted, rejected, countered, withdrawn or expires.
+// Pending offers on a property are declined when another offer on it is accepted.
+const (
+	OfferPending   = "pending"
+	OfferAccepted  = "accepted"
+	OfferRejected  = "rejected"
+	OfferCountered = "countered"
+	OfferWithdrawn = "withdrawn"
+	OfferDeclined  = "declined"
+	OfferExpired   = "expired"
+)
+
+// maxOfferDays is the number of days an offer can be left open for.
+const maxOfferDays = 30
+
+// Offer contains an offer to buy a property. A negotiation starts with an offer made by the buyer, and each
+// counter-offer made by the seller or the buyer points to the offer it counters and to the first offer of
+// the negotiation. History holds every offer of the negotiation, oldest first.
+type Offer struct {
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PropertyID    int64      `json:"property_id"`
+	PropertyTitle string     `json:"property_title"`
+	SellerID      int64      `json:"seller_id"`
+	BuyerID       int64      `json:"buyer_id"`
+	MadeBy        int64      `json:"made_by"`
+	RootID        *int64     `json:"root_id,omitempty"`
+	ParentID      *int64     `json:"parent_id,omitempty"`
+	Amount        float64    `json:"amount"`
+	Conditions    []string   `json:"conditions"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	Status        string     `json:"status"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+	Version       int32      `json:"version"`
+	History       []*Offer   `json:"history,omitempty"`
+}
+
+// Recipient returns the ID of the user an offer was made to.
+func (offer *Offer) Recipient() int64 {
+	if offer.MadeBy == offer.BuyerID {
+		return offer.SellerID
+	}
+	return offer.BuyerID
+}
+
+// NegotiationID returns the ID of the first offer of the negotiation an offer belongs to.
+func (offer *Offer) NegotiationID() int64 {
+	if offer.RootID != nil {
+		return *offer.RootID
+	}
+	return offer.ID
+}
+
+// IsOpen reports whether an offer can still be responded to.
+func (offer *Offer) IsOpen(now time.Time) bool {
+	return offer.Status == OfferPending && offer.ExpiresAt.After(now)
+}
+
+// IsParty reports whether a user is the buyer or the seller of an offer.
+func (offer *Offer) IsParty(userID int64) bool {
+	return userID != 0 && (userID == offer.BuyerID || userID == offer.SellerID)
+}
+
+// ValidateOffer validates an offer or counter-offer based on set validation criteria.
+func ValidateOffer(v *validator.Validator, offer *Offer) {
+	v.Check(offer.SellerID != 0, "property", "has no seller to make an offer to")
+	v.Check(offer.SellerID != offer.BuyerID, "property", "must not be your own listing")
+	v.Check(offer.Amount > 0, "amount", "must be greater than zero")
+	v.Check(offer.Amount < 1e12, "amount", "must be less than 1000000000000")
+	v.Check(len(offer.Conditions) <= 10, "conditions", "must not contain more than 10 conditions")
+	v.Check(validator.Unique(offer.Conditions), "conditions", "must not contain duplicate values")
+	for _, condition := range offer.Conditions {
+		v.Check(condition != "", "conditions", "must not contain empty values")
+		v.Check(len(condition) <= 500, "conditions", "must not contain values more than 500 bytes long")
+	}
+	v.Check(!offer.ExpiresAt.IsZero(), "expires_at", "must be provided")
+	v.Check(offer.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+	v.Check(offer.ExpiresAt.Before(time.Now().AddDate(0, 0, maxOfferDays)), "expires_at", fmt.Sprintf("must be within %d days", maxOfferDays))
+}
+
+// ValidateOfferResponse validates a user's response to an offer. Only the user an offer was made to can
+// accept, reject or counter it, and only the user who made it can withdraw it.
+func ValidateOfferResponse(v *validator.Validator, offer *Offer, status string, userID int64) {
+	if !offer.IsOpen(time.Now()) {
+		v.AddError("offer", "is no longer open")
+		return
+	}
+
+	switch status {
+	case OfferWithdrawn:
+		v.Check(userID == offer.MadeBy, "offer", "can only be withdrawn by the user who made it")
+	default:
+		v.Check(userID == offer.Recipient(), "offer", fmt.Sprintf("can only be %s by the user it was made to", status))
+	}
+}
+
+// OfferModel struct wraps a sql.DB connection pool.
+type OfferModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a new offer starting a negotiation, returning ErrDuplicateOffer if the buyer already has an
+// open offer on the property. Pending offers of the buyer on the property that have passed their expiry are
+// marked expired first.
+func (m OfferModel) Insert(offer *Offer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE offers
+	SET status = 'expired', version = version + 1
+	WHERE property_id = $1 AND buyer_id = $2 AND status = 'pending' AND expires_at <= NOW()`
+
+	_, err = tx.ExecContext(ctx, query, offer.PropertyID, offer.BuyerID)
+	if err != nil {
+		return err
+	}
+
+	err = insertOffer(ctx, tx, offer)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertOffer inserts an offer within a transaction.
+func insertOffer(ctx context.Context, tx *sql.Tx, offer *Offer) error {
+	query := `
+	INSERT INTO offers (property_id, buyer_id, made_by, root_id, parent_id, amount, conditions, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id, created_at, status, version`
+
+	args := []interface{}{
+		offer.PropertyID,
+		offer.BuyerID,
+		offer.MadeBy,
+		offer.RootID,
+		offer.ParentID,
+		offer.Amount,
+		pq.Array(nonNil(offer.Conditions)),
+		offer.ExpiresAt,
+	}
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&offer.ID, &offer.CreatedAt, &offer.Status, &offer.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "offers_pending_idx"`:
+			return ErrDuplicateOffer
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get fetches a specific offer.
+func (m OfferModel) Get(id int64) (*Offer, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM offers
+	INNER JOIN properties ON properties.id = offers.property_id
+	WHERE offers.id = $1`, offerColumns)
+
+	var offer Offer
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(offer.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &offer, nil
+}
+
+// GetNegotiation returns the offers of a negotiation, oldest first.
+func (m OfferModel) GetNegotiation(rootID int64) ([]*Offer, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM offers
+	INNER JOIN properties ON properties.id = offers.property_id
+	WHERE offers.id = $1 OR offers.root_id = $1
+	ORDER BY offers.id`, offerColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, rootID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOffers(rows)
+}
+
+// GetAllForBuyer returns a paginated list of the offers made by or to a buyer, newest first.
+func (m OfferModel) GetAllForBuyer(buyerID int64, filters Filters) ([]*Offer, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM offers
+	INNER JOIN properties ON properties.id = offers.property_id
+	WHERE offers.buyer_id = $1
+	ORDER BY offers.created_at DESC, offers.id DESC
+	LIMIT $2 OFFSET $3`, offerColumns)
+
+	return m.query(query, filters, buyerID, filters.limit(), filters.offset())
+}
+
+// GetAllForProperty returns a paginated list of the offers on a property, optionally with a specific
+// status, newest first.
+func (m OfferModel) GetAllForProperty(propertyID int64, status string, filters Filters) ([]*Offer, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM offers
+	INNER JOIN properties ON properties.id = offers.property_id
+	WHERE offers.property_id = $1 AND (offers.status = $2 OR $2 = '')
+	ORDER BY offers.created_at DESC, offers.id DESC
+	LIMIT $3 OFFSET $4`, offerColumns)
+
+	return m.query(query, filters, propertyID, status, filters.limit(), filters.offset())
+}
+
+// query runs a paginated query returning offers.
+func (m OfferModel) query(query string, filters Filters, args ...interface{}) ([]*Offer, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	offers := []*Offer{}
+
+	for rows.Next() {
+		var offer Offer
+		err := rows.Scan(append([]interface{}{&totalRecords}, offer.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		offers = append(offers, &offer)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return offers, metadata, nil
+}
+
+// Accept accepts an offer in one transaction: the offer is marked accepted, its property is put under offer
+// and the other pending offers on the property are declined and returned. ErrPropertyUnavailable is returned
+// if the property is no longer published, ErrPropertyAuctioned if it has a scheduled auction, and
+// ErrEditConflict if the offer has changed since it was fetched or has expired.
+func (m OfferModel) Accept(offer *Offer) ([]*Offer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+
+	err = tx.QueryRowContext(ctx, `SELECT status FROM properties WHERE id = $1 FOR UPDATE`, offer.PropertyID).Scan(&status)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if status != StatusPublished {
+		return nil, ErrPropertyUnavailable
+	}
+
+	var auctioned bool
+
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM auctions WHERE property_id = $1 AND status = 'scheduled')`, offer.PropertyID).Scan(&auctioned)
+	if err != nil {
+		return nil, err
+	}
+
+	if auctioned {
+		return nil, ErrPropertyAuctioned
+	}
+
+	err = respondToOffer(ctx, tx, offer, OfferAccepted)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	UPDATE properties
+	SET status = $1, updated_at = NOW(), version = version + 1
+	WHERE id = $2`
+
+	_, err = tx.ExecContext(ctx, query, StatusUnderOffer, offer.PropertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	query = fmt.Sprintf(`
+	UPDATE offers
+	SET status = 'declined', responded_at = NOW(), version = offers.version + 1
+	FROM properties
+	WHERE offers.property_id = $1 AND offers.status = 'pending' AND properties.id = offers.property_id
+	RETURNING %s`, offerColumns)
+
+	rows, err := tx.QueryContext(ctx, query, offer.PropertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	declined, err := scanOffers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return declined, tx.Commit()
+}
+
+// Counter counters an offer in one transaction: the offer is marked countered and the counter-offer is
+// inserted into its negotiation. ErrEditConflict is returned if the offer has changed since it was fetched
+// or has expired.
+func (m OfferModel) Counter(offer, counter *Offer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = respondToOffer(ctx, tx, offer, OfferCountered)
+	if err != nil {
+		return err
+	}
+
+	rootID, parentID := offer.NegotiationID(), offer.ID
+	counter.RootID, counter.ParentID = &rootID, &parentID
+
+	err = insertOffer(ctx, tx, counter)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Respond rejects or withdraws an offer, returning ErrEditConflict if it has changed since it was fetched
+// or has expired.
+func (m OfferModel) Respond(offer *Offer, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = respondToOffer(ctx, tx, offer, status)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// respondToOffer changes the status of a pending offer that has not expired within a transaction.
+func respondToOffer(ctx context.Context, tx *sql.Tx, offer *Offer, status string) error {
+	query := `
+	UPDATE offers
+	SET status = $1, responded_at = NOW(), version = version + 1
+	WHERE id = $2 AND version = $3 AND status = 'pending' AND expires_at > NOW()
+	RETURNING responded_at, version`
+
+	err := tx.QueryRowContext(ctx, query, status, offer.ID, offer.Version).Scan(&offer.RespondedAt, &offer.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	offer.Status = status
+
+	return nil
+}
+
+// ExpireDue marks the pending offers that have passed their expiry as expired and returns them.
+func (m OfferModel) ExpireDue() ([]*Offer, error) {
+	query := fmt.Sprintf(`
+	UPDATE offers
+	SET status = 'expired', version = offers.version + 1
+	FROM properties
+	WHERE offers.status = 'pending' AND offers.expires_at <= NOW() AND properties.id = offers.property_id
+	RETURNING %s`, offerColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOffers(rows)
+}
+
+// scanOffers scans and closes rows of offers.
+func scanOffers(rows *sql.Rows) ([]*Offer, error) {
+	defer rows.Close()
+
+	offers := []*Offer{}
+
+	for rows.Next() {
+		var offer Offer
+		err := rows.Scan(offer.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, &offer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return offers, nil
+}
+
+// offerColumns lists the offer columns in the order expected by Offer.scanTargets.
+const offerColumns = `offers.id, offers.created_at, offers.property_id, properties.title, coalesce(properties.user_id, 0),
+	offers.buyer_id, offers.made_by, offers.root_id, offers.parent_id, offers.amount, offers.conditions, offers.expires_at,
+	offers.status, offers.responded_at, offers.version`
+
+// scanTargets returns pointers to the offer fields in the order of offerColumns.
+func (offer *Offer) scanTargets() []interface{} {
+	return []interface{}{
+		&offer.ID,
+		&offer.CreatedAt,
+		&offer.PropertyID,
+		&offer.PropertyTitle,
+		&offer.SellerID,
+		&offer.BuyerID,
+		&offer.MadeBy,
+		&offer.RootID,
+		&offer.ParentID,
+		&offer.Amount,
+		pq.Array(&offer.Conditions),
+		&offer.ExpiresAt,
+		&offer.Status,
+		&offer.RespondedAt,
+		&offer.Version,
+	}
+}
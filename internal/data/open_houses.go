@@ -0,0 +1,501 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// ErrDuplicateRSVP is returned when a user responds to an open house they have already responded to.
+var ErrDuplicateRSVP = errors.New("duplicate rsvp")
+
+// Open house statuses.
+const (
+	OpenHouseScheduled = "scheduled"
+	OpenHouseCancelled = "cancelled"
+)
+
+// RSVP statuses.
+const (
+	RSVPConfirmed  = "confirmed"
+	RSVPWaitlisted = "waitlisted"
+	RSVPCancelled  = "cancelled"
+)
+
+// Values of the open_house listing filter.
+const (
+	OpenHouseThisWeekend = "this_weekend"
+	OpenHouseUpcoming    = "upcoming"
+)
+
+// OpenHouse contains an open house event held at a listing.
+type OpenHouse struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	PropertyID    int64     `json:"property_id"`
+	PropertyTitle string    `json:"property_title"`
+	AgentID       int64     `json:"-"`
+	StartsAt      time.Time `json:"starts_at"`
+	EndsAt        time.Time `json:"ends_at"`
+	TimeZone      string    `json:"time_zone"`
+	Capacity      int       `json:"capacity"`
+	Attending     int       `json:"attending"`
+	Waitlisted    int       `json:"waitlisted"`
+	SpotsLeft     int       `json:"spots_left"`
+	Status        string    `json:"status"`
+	RSVPStatus    string    `json:"rsvp_status,omitempty"`
+	RSVPVersion   int32     `json:"-"`
+	LastModified  time.Time `json:"-"`
+	Version       int32     `json:"version"`
+}
+
+// Local sets the open house's times to its time zone.
+func (openHouse *OpenHouse) Local() {
+	loc, err := time.LoadLocation(openHouse.TimeZone)
+	if err != nil {
+		return
+	}
+	openHouse.StartsAt = openHouse.StartsAt.In(loc)
+	openHouse.EndsAt = openHouse.EndsAt.In(loc)
+}
+
+// When describes the time of the open house in its time zone, for notifications.
+func (openHouse *OpenHouse) When() string {
+	startsAt, endsAt := openHouse.StartsAt, openHouse.EndsAt
+	if loc, err := time.LoadLocation(openHouse.TimeZone); err == nil {
+		startsAt, endsAt = startsAt.In(loc), endsAt.In(loc)
+	}
+	return fmt.Sprintf("%s-%s %s", startsAt.Format("Monday 2 January 2006, 15:04"), endsAt.Format("15:04"), startsAt.Format("MST"))
+}
+
+// ValidateOpenHouse validates an open house based on set validation criteria.
+func ValidateOpenHouse(v *validator.Validator, openHouse *OpenHouse) {
+	v.Check(!openHouse.StartsAt.IsZero(), "starts_at", "must be provided")
+	v.Check(!openHouse.EndsAt.IsZero(), "ends_at", "must be provided")
+	v.Check(openHouse.StartsAt.After(time.Now()), "starts_at", "must be in the future")
+	v.Check(openHouse.EndsAt.After(openHouse.StartsAt), "ends_at", "must be after starts_at")
+	v.Check(openHouse.EndsAt.Sub(openHouse.StartsAt) <= 12*time.Hour, "ends_at", "must be at most 12 hours after starts_at")
+	v.Check(openHouse.Capacity > 0, "capacity", "must be greater than zero")
+	v.Check(openHouse.Capacity <= 1000, "capacity", "must not be more than 1000")
+	ValidateTimeZone(v, openHouse.TimeZone)
+}
+
+// RSVP contains a user's response to an open house.
+type RSVP struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	OpenHouseID int64     `json:"open_house_id"`
+	UserID      int64     `json:"user_id"`
+	Guests      int       `json:"guests"`
+	Status      string    `json:"status"`
+}
+
+// ValidateRSVP validates an RSVP based on set validation criteria.
+func ValidateRSVP(v *validator.Validator, rsvp *RSVP) {
+	v.Check(rsvp.Guests > 0, "guests", "must be greater than zero")
+	v.Check(rsvp.Guests <= 10, "guests", "must not be more than 10")
+}
+
+// OpenHouseModel struct wraps a sql.DB connection pool.
+type OpenHouseModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new open house.
+func (m OpenHouseModel) Insert(openHouse *OpenHouse) error {
+	query := `
+	INSERT INTO open_houses (property_id, starts_at, ends_at, time_zone, capacity)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, status, version`
+
+	args := []interface{}{openHouse.PropertyID, openHouse.StartsAt, openHouse.EndsAt, openHouse.TimeZone, openHouse.Capacity}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&openHouse.ID, &openHouse.CreatedAt, &openHouse.Status, &openHouse.Version)
+}
+
+// Get fetches a specific open house.
+func (m OpenHouseModel) Get(id int64) (*OpenHouse, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM open_houses
+	INNER JOIN properties ON properties.id = open_houses.property_id
+	CROSS JOIN LATERAL (%s) AS rsvp_counts
+	WHERE open_houses.id = $1`, openHouseColumns, rsvpCountsQuery)
+
+	var openHouse OpenHouse
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(openHouse.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	openHouse.Local()
+
+	return &openHouse, nil
+}
+
+// GetUpcomingForProperty returns the scheduled open houses of a property that have not ended, soonest first.
+func (m OpenHouseModel) GetUpcomingForProperty(propertyID int64) ([]*OpenHouse, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM open_houses
+	INNER JOIN properties ON properties.id = open_houses.property_id
+	CROSS JOIN LATERAL (%s) AS rsvp_counts
+	WHERE open_houses.property_id = $1 AND open_houses.status = 'scheduled' AND open_houses.ends_at > NOW()
+	ORDER BY open_houses.starts_at
+	LIMIT 100`, openHouseColumns, rsvpCountsQuery)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	openHouses := []*OpenHouse{}
+
+	for rows.Next() {
+		var openHouse OpenHouse
+		err := rows.Scan(openHouse.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		openHouse.Local()
+		openHouses = append(openHouses, &openHouse)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return openHouses, nil
+}
+
+// GetForCalendar returns the open houses that ended after a point in time and are held at a user's listings
+// or that the user responded to, with the user's RSVP status and version and when either the open house or
+// the RSVP last changed. Cancelled open houses and RSVPs are included so that calendar clients can remove them.
+func (m OpenHouseModel) GetForCalendar(userID int64, since time.Time) ([]*OpenHouse, error) {
+	query := fmt.Sprintf(`
+	SELECT %s, coalesce(open_house_rsvps.status, ''), coalesce(open_house_rsvps.version, 0),
+		greatest(open_houses.updated_at, open_house_rsvps.updated_at)
+	FROM open_houses
+	INNER JOIN properties ON properties.id = open_houses.property_id
+	CROSS JOIN LATERAL (%s) AS rsvp_counts
+	LEFT JOIN open_house_rsvps ON open_house_rsvps.open_house_id = open_houses.id AND open_house_rsvps.user_id = $1
+	WHERE (properties.user_id = $1 OR open_house_rsvps.id IS NOT NULL)
+	AND open_houses.ends_at > $2
+	ORDER BY open_houses.starts_at
+	LIMIT 1000`, openHouseColumns, rsvpCountsQuery)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	openHouses := []*OpenHouse{}
+
+	for rows.Next() {
+		var openHouse OpenHouse
+		err := rows.Scan(append(openHouse.scanTargets(), &openHouse.RSVPStatus, &openHouse.RSVPVersion, &openHouse.LastModified)...)
+		if err != nil {
+			return nil, err
+		}
+		openHouses = append(openHouses, &openHouse)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return openHouses, nil
+}
+
+// Cancel cancels a scheduled open house.
+func (m OpenHouseModel) Cancel(openHouse *OpenHouse) error {
+	query := `
+	UPDATE open_houses
+	SET status = 'cancelled', updated_at = NOW(), version = version + 1
+	WHERE id = $1 AND version = $2 AND status = 'scheduled'
+	RETURNING status, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, openHouse.ID, openHouse.Version).Scan(&openHouse.Status, &openHouse.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAttendees returns the users with a confirmed or waitlisted RSVP to an open house.
+func (m OpenHouseModel) GetAttendees(openHouseID int64) ([]*User, error) {
+	query := `
+	SELECT users.id, users.created_at, users.name, users.email
+	FROM users
+	INNER JOIN open_house_rsvps ON open_house_rsvps.user_id = users.id
+	WHERE open_house_rsvps.open_house_id = $1 AND open_house_rsvps.status <> 'cancelled'
+	ORDER BY users.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, openHouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&user.ID, &user.CreatedAt, &user.Name, &user.Email)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// InsertRSVP responds to a scheduled open house that has not ended, locking the open house so that
+// concurrent responses cannot exceed its capacity. The RSVP is confirmed when its guests fit in the
+// remaining capacity and nobody is waiting ahead of it, and waitlisted otherwise. A user who cancelled
+// their RSVP may respond again; ErrDuplicateRSVP is returned if their RSVP is still active.
+func (m OpenHouseModel) InsertRSVP(rsvp *RSVP) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	capacity, err := lockOpenHouse(ctx, tx, rsvp.OpenHouseID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	SELECT
+		coalesce(sum(guests) FILTER (WHERE status = 'confirmed'), 0),
+		count(*) FILTER (WHERE status = 'waitlisted')
+	FROM open_house_rsvps
+	WHERE open_house_id = $1 AND user_id <> $2`
+
+	var attending, waitlisted int
+
+	err = tx.QueryRowContext(ctx, query, rsvp.OpenHouseID, rsvp.UserID).Scan(&attending, &waitlisted)
+	if err != nil {
+		return err
+	}
+
+	rsvp.Status = RSVPWaitlisted
+	if waitlisted == 0 && attending+rsvp.Guests <= capacity {
+		rsvp.Status = RSVPConfirmed
+	}
+
+	query = `
+	INSERT INTO open_house_rsvps (open_house_id, user_id, guests, status)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (open_house_id, user_id) DO UPDATE
+	SET guests = EXCLUDED.guests, status = EXCLUDED.status, created_at = NOW(), updated_at = NOW(),
+		version = open_house_rsvps.version + 1
+	WHERE open_house_rsvps.status = 'cancelled'
+	RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, rsvp.OpenHouseID, rsvp.UserID, rsvp.Guests, rsvp.Status).Scan(&rsvp.ID, &rsvp.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrDuplicateRSVP
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CancelRSVP cancels a user's active RSVP to an open house. Freed capacity is offered to waitlisted RSVPs
+// in the order they were made, stopping at the first that does not fit, and the promoted RSVPs are returned.
+func (m OpenHouseModel) CancelRSVP(openHouseID, userID int64) ([]*RSVP, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	capacity, err := lockOpenHouse(ctx, tx, openHouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	UPDATE open_house_rsvps
+	SET status = 'cancelled', updated_at = NOW(), version = version + 1
+	WHERE open_house_id = $1 AND user_id = $2 AND status <> 'cancelled'`
+
+	result, err := tx.ExecContext(ctx, query, openHouseID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	query = `
+	SELECT id, created_at, open_house_id, user_id, guests, status
+	FROM open_house_rsvps
+	WHERE open_house_id = $1 AND status IN ('confirmed', 'waitlisted')
+	ORDER BY status = 'waitlisted', created_at, id`
+
+	rows, err := tx.QueryContext(ctx, query, openHouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attending := 0
+	promoted := []*RSVP{}
+
+	for rows.Next() {
+		var rsvp RSVP
+		err := rows.Scan(&rsvp.ID, &rsvp.CreatedAt, &rsvp.OpenHouseID, &rsvp.UserID, &rsvp.Guests, &rsvp.Status)
+		if err != nil {
+			return nil, err
+		}
+		if rsvp.Status == RSVPConfirmed {
+			attending += rsvp.Guests
+			continue
+		}
+		if attending+rsvp.Guests > capacity {
+			break
+		}
+		attending += rsvp.Guests
+		rsvp.Status = RSVPConfirmed
+		promoted = append(promoted, &rsvp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, rsvp := range promoted {
+		_, err = tx.ExecContext(ctx, `UPDATE open_house_rsvps SET status = 'confirmed', updated_at = NOW(), version = version + 1 WHERE id = $1`, rsvp.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return promoted, nil
+}
+
+// lockOpenHouse locks a scheduled open house that has not ended for the rest of a transaction and
+// returns its capacity, or ErrRecordNotFound when the open house can no longer be responded to.
+func lockOpenHouse(ctx context.Context, tx *sql.Tx, id int64) (int, error) {
+	query := `
+	SELECT capacity
+	FROM open_houses
+	WHERE id = $1 AND status = 'scheduled' AND ends_at > NOW()
+	FOR UPDATE`
+
+	var capacity int
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(&capacity)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return capacity, nil
+}
+
+// openHouseColumns lists the open house columns in the order expected by OpenHouse.scanTargets.
+const openHouseColumns = `open_houses.id, open_houses.created_at, open_houses.property_id, properties.title, coalesce(properties.user_id, 0),
+	open_houses.starts_at, open_houses.ends_at, open_houses.time_zone, open_houses.capacity, rsvp_counts.attending,
+	rsvp_counts.waitlisted, greatest(open_houses.capacity - rsvp_counts.attending, 0), open_houses.status, open_houses.version`
+
+// rsvpCountsQuery counts the confirmed guests and waitlisted RSVPs of the open house joined to it.
+const rsvpCountsQuery = `
+	SELECT coalesce(sum(guests) FILTER (WHERE status = 'confirmed'), 0) AS attending,
+		count(*) FILTER (WHERE status = 'waitlisted') AS waitlisted
+	FROM open_house_rsvps
+	WHERE open_house_rsvps.open_house_id = open_houses.id`
+
+// scanTargets returns pointers to the open house fields in the order of openHouseColumns.
+func (openHouse *OpenHouse) scanTargets() []interface{} {
+	return []interface{}{
+		&openHouse.ID,
+		&openHouse.CreatedAt,
+		&openHouse.PropertyID,
+		&openHouse.PropertyTitle,
+		&openHouse.AgentID,
+		&openHouse.StartsAt,
+		&openHouse.EndsAt,
+		&openHouse.TimeZone,
+		&openHouse.Capacity,
+		&openHouse.Attending,
+		&openHouse.Waitlisted,
+		&openHouse.SpotsLeft,
+		&openHouse.Status,
+		&openHouse.Version,
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/emzola/realty/internal/validator"
@@ -14,22 +17,52 @@ import (
 
 // Property contains information about a property
 type Property struct {
-	ID          int64             `json:"id"`
-	CreatedAt   time.Time         `json:"created_at,omitempty"`
-	Title       string            `json:"title"`
-	Description string            `json:"description"`
-	City        string            `json:"city"`
-	Location    string            `json:"location"`
-	Latitude    float64           `json:"latitude,omitempty"`
-	Longitude   float64           `json:"longitude,omitempty"`
-	Type        []string          `json:"type,omitempty"`
-	Category    []string          `json:"category,omitempty"`
-	Features    Features				  `json:"features,omitempty"`
-	Price       float64           `json:"price"`
-	Currency    []string          `json:"currency"`
-	Nearby      Nearby 						`json:"nearby,omitempty"`
-	Amenities   []string          `json:"amenities,omitempty"`
-	Version     int32             `json:"version"`
+	ID            int64         `json:"id"`
+	UserID        int64         `json:"user_id,omitempty"`
+	CreatedAt     time.Time     `json:"created_at,omitempty"`
+	Title         string        `json:"title"`
+	Description   string        `json:"description"`
+	City          string        `json:"city"`
+	Location      string        `json:"location"`
+	Latitude      float64       `json:"latitude,omitempty"`
+	Longitude     float64       `json:"longitude,omitempty"`
+	Type          []string      `json:"type,omitempty"`
+	Category      []string      `json:"category,omitempty"`
+	Features      Features      `json:"features,omitempty"`
+	Price         float64       `json:"price"`
+	Currency      []string      `json:"currency"`
+	Nearby        Nearby        `json:"nearby,omitempty"`
+	Amenities     []string      `json:"amenities,omitempty"`
+	Status        string        `json:"status"`
+	UpdatedAt     time.Time     `json:"updated_at,omitempty"`
+	Version       int32         `json:"version"`
+	IsFavourite   *bool         `json:"is_favourite,omitempty"`
+	AgentVerified bool          `json:"agent_verified"`
+	Agent         *AgentProfile `json:"agent,omitempty"`
+}
+
+// Property statuses. Only published properties appear in public listings and saved search alerts.
+// Listings awaiting review by a moderator are pending_review, and listings with an accepted offer are under_offer.
+const (
+	StatusPublished     = "published"
+	StatusUnpublished   = "unpublished"
+	StatusUnderOffer    = "under_offer"
+	StatusSold          = "sold"
+	StatusLet           = "let"
+	StatusPendingReview = "pending_review"
+)
+
+// PermittedStatuses lists the statuses a property can be set to by its owner.
+var PermittedStatuses = []string{StatusPublished, StatusUnpublished, StatusUnderOffer, StatusSold, StatusLet}
+
+// ValidatePropertyStatusChange validates a change of a property's status by its owner. A listing pending
+// review can only be withdrawn, by unpublishing it, until a moderator has reviewed it.
+func ValidatePropertyStatusChange(v *validator.Validator, from, to string) {
+	if from == to {
+		return
+	}
+	v.Check(validator.In(to, PermittedStatuses...), "status", "must be a permitted status")
+	v.Check(from != StatusPendingReview || to == StatusUnpublished, "status", "can only be changed to unpublished while the listing is pending review")
 }
 
 // Features contains features of a property
@@ -48,6 +81,30 @@ func (a *Features) Scan(value interface{}) error {
 	return json.Unmarshal(b, &a)
 }
 
+// SizeFeatureKeys lists the feature keys that may hold the floor area of a property in square metres.
+var SizeFeatureKeys = []string{"size", "area", "floor_area"}
+
+// Float returns the numeric value of the first of the keys present in the features. Numeric strings
+// with a trailing unit, such as "120 sqm", are accepted. It returns false if no key holds a number.
+func (a Features) Float(keys ...string) (float64, bool) {
+	for _, key := range keys {
+		switch value := a[key].(type) {
+		case float64:
+			return value, true
+		case string:
+			field := strings.Fields(value)
+			if len(field) == 0 {
+				continue
+			}
+			f, err := strconv.ParseFloat(strings.ReplaceAll(field[0], ",", ""), 64)
+			if err == nil {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
 // Nearby contains information about a nearby facilities
 type Nearby map[string]interface{}
 
@@ -87,8 +144,94 @@ func ValidateProperty(v *validator.Validator, property *Property) {
 	v.Check(len(property.Nearby) >= 1, "nearby", "must contain at least 1 facility")
 	v.Check(len(property.Nearby) <= 10, "nearby", "must not contain more than 10 facilities")
 	v.Check(validator.Unique(property.Amenities), "amenities", "must not contain duplicate values")
+	v.Check(validator.In(property.Status, append(PermittedStatuses, StatusPendingReview)...), "status", "must be a permitted status")
 }
 
+// PropertyFilters contains the criteria used to narrow down a property listing.
+type PropertyFilters struct {
+	City      string   `json:"city,omitempty"`
+	Location  string   `json:"location,omitempty"`
+	Type      []string `json:"type,omitempty"`
+	Category  []string `json:"category,omitempty"`
+	Amenities []string `json:"amenities,omitempty"`
+	MinPrice  float64  `json:"min_price,omitempty"`
+	MaxPrice  float64  `json:"max_price,omitempty"`
+	OpenHouse string   `json:"open_house,omitempty"`
+	CheckIn   string   `json:"check_in,omitempty"`
+	CheckOut  string   `json:"check_out,omitempty"`
+	Status    string   `json:"-"`
+}
+
+// ValidatePropertyFilters validates the criteria of a property listing.
+func ValidatePropertyFilters(v *validator.Validator, f PropertyFilters) {
+	v.Check(f.MinPrice >= 0, "min_price", "must not be a negative number")
+	v.Check(f.MaxPrice >= 0, "max_price", "must not be a negative number")
+	v.Check(f.MaxPrice == 0 || f.MaxPrice >= f.MinPrice, "max_price", "must not be less than min_price")
+	v.Check(validator.Unique(f.Amenities), "amenities", "must not contain duplicate values")
+	v.Check(f.OpenHouse == "" || validator.In(f.OpenHouse, OpenHouseThisWeekend, OpenHouseUpcoming), "open_house", "must be this_weekend or upcoming")
+	if f.CheckIn != "" || f.CheckOut != "" {
+		validateDateRange(v, f.CheckIn, f.CheckOut, "check_in", "check_out", false)
+	}
+}
+
+// propertyFilterClause is the WHERE clause shared by every query that is narrowed down by PropertyFilters.
+// Its placeholders are bound by PropertyFilters.args, so queries embedding it must number their own
+// placeholders from propertyFilterArgs + 1. A filter with a zero value matches every property. Short-lets
+// match a stay when it meets the minimum stay that NewRentalQuote applies to its check-in night. This
+// weekend is worked out in the time zone of each open house, from Saturday until Monday midnight.
+const propertyFilterClause = `
+	(lower(city) = lower($1) OR $1 = '')
+	AND (lower(location) = lower($2) OR $2 = '')
+	AND (type && $3 OR $3 = '{}')
+	AND (category && $4 OR $4 = '{}')
+	AND (amenities @> $5 OR $5 = '{}')
+	AND (price >= $6 OR $6 = 0)
+	AND (price <= $7 OR $7 = 0)
+	AND (status = $8 OR $8 = '')
+	AND ($9::text IS NULL OR EXISTS (
+		SELECT 1 FROM open_houses
+		WHERE open_houses.property_id = properties.id AND open_houses.status = 'scheduled'
+		AND open_houses.ends_at > $10::timestamptz
+		AND ($9 = 'upcoming' OR (
+			open_houses.ends_at > (date_trunc('week', $10::timestamptz AT TIME ZONE open_houses.time_zone) + interval '5 days') AT TIME ZONE open_houses.time_zone
+			AND open_houses.starts_at < (date_trunc('week', $10::timestamptz AT TIME ZONE open_houses.time_zone) + interval '7 days') AT TIME ZONE open_houses.time_zone))))
+	AND ($11::date IS NULL OR (
+		EXISTS (
+			SELECT 1 FROM rental_rates
+			WHERE rental_rates.property_id = properties.id
+			AND coalesce((
+				SELECT nullif(rental_seasons.min_nights, 0) FROM rental_seasons
+				WHERE rental_seasons.property_id = properties.id
+				AND rental_seasons.start_date <= $11::date AND rental_seasons.end_date >= $11::date
+				ORDER BY rental_seasons.start_date DESC, rental_seasons.id ASC
+				LIMIT 1), rental_rates.min_nights) <= $12::date - $11::date)
+		AND NOT EXISTS (
+			SELECT 1 FROM rental_blocks
+			WHERE rental_blocks.property_id = properties.id AND rental_blocks.start_date < $12 AND rental_blocks.end_date > $11)))`
+
+// propertyFilterArgs is the number of placeholders used by propertyFilterClause.
+const propertyFilterArgs = 12
+
+// args returns the arguments bound to the placeholders of propertyFilterClause.
+func (f PropertyFilters) args() []interface{} {
+	return []interface{}{f.City, f.Location, pq.Array(nonNil(f.Type)), pq.Array(nonNil(f.Category)), pq.Array(nonNil(f.Amenities)), f.MinPrice, f.MaxPrice, f.Status, nullString(f.OpenHouse), time.Now(), nullString(f.CheckIn), nullString(f.CheckOut)}
+}
+
+// nullString returns nil in place of an empty string, so that it is sent to PostgreSQL as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nonNil returns an empty slice in place of a nil one, so that it is sent to PostgreSQL as '{}' rather than NULL.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
 
 // PropertyModel struct wraps a sql.DB connection pool.
 type PropertyModel struct {
@@ -98,17 +241,17 @@ type PropertyModel struct {
 // Insert inserts a new record into the property table.
 func (p PropertyModel) Insert(property *Property) error {
 	query := `
-	INSERT INTO properties(title, description, city, location, latitude, longitude, type, category, features, price, currency, nearby, amenities)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
-	RETURNING id, created_at, version`
+	INSERT INTO properties(title, description, city, location, latitude, longitude, type, category, features, price, currency, nearby, amenities, status, user_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	RETURNING id, created_at, updated_at, version`
 
 
-	args := []interface{}{property.Title, property.Description, property.City, property.Location, property.Latitude, property.Longitude, pq.Array(property.Type), pq.Array(property.Category), property.Features, property.Price, pq.Array(property.Currency), property.Nearby, pq.Array(property.Amenities)}
+	args := []interface{}{property.Title, property.Description, property.City, property.Location, property.Latitude, property.Longitude, pq.Array(property.Type), pq.Array(property.Category), property.Features, property.Price, pq.Array(property.Currency), property.Nearby, pq.Array(property.Amenities), property.Status, property.UserID}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return p.DB.QueryRowContext(ctx, query, args...).Scan(&property.ID, &property.CreatedAt, &property.Version)
+	return p.DB.QueryRowContext(ctx, query, args...).Scan(&property.ID, &property.CreatedAt, &property.UpdatedAt, &property.Version)
 }
 
 // Get fetches a specific record from the properties table.
@@ -117,35 +260,17 @@ func (p PropertyModel) Get(id int64) (*Property, error) {
 		return nil, ErrRecordNotFound
 	}
 
-	query := `
-	SELECT id, created_at, title, description, city, location, latitude, longitude, type, category, features, price, currency, nearby, amenities, version
+	query := fmt.Sprintf(`
+	SELECT %s
 	FROM properties
-	WHERE id = $1`
+	WHERE properties.id = $1`, propertyColumns)
 
 	var property Property
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	err := p.DB.QueryRowContext(ctx, query, id).Scan(
-		&property.ID, 
-		&property.CreatedAt, 
-		&property.Title, 
-		&property.Description, 
-		&property.City, 
-		&property.Location, 
-		&property.Latitude, 
-		&property.Longitude, 
-		pq.Array(&property.Type), 
-		pq.Array(&property.Category), 
-		&property.Features, 
-		&property.Price, 
-		pq.Array(&property.Currency), 
-		&property.Nearby, 
-		pq.Array(&property.Amenities),
-		&property.Version,
-	)
-
+	err := p.DB.QueryRowContext(ctx, query, id).Scan(property.scanTargets()...)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -161,16 +286,16 @@ func (p PropertyModel) Get(id int64) (*Property, error) {
 // Update updates a specific record in the properties table.
 func (p PropertyModel) Update(property *Property) error {
 	query := `UPDATE properties
-	SET title = $1, description = $2, city = $3, location = $4, latitude = $5, longitude = $6, type = $7, category = $8, features = $9, price = $10, currency = $11, nearby = $12, amenities = $13, version = version + 1
-	WHERE id = $14 AND version = $15
-	RETURNING version`
+	SET title = $1, description = $2, city = $3, location = $4, latitude = $5, longitude = $6, type = $7, category = $8, features = $9, price = $10, currency = $11, nearby = $12, amenities = $13, status = $14, updated_at = NOW(), version = version + 1
+	WHERE id = $15 AND version = $16
+	RETURNING updated_at, version`
 
-	args := []interface{}{property.Title, property.Description, property.City, property.Location, property.Latitude, property.Longitude, pq.Array(property.Type), pq.Array(property.Category), property.Features, property.Price, pq.Array(property.Currency), property.Nearby, pq.Array(property.Amenities), property.ID, property.Version}
+	args := []interface{}{property.Title, property.Description, property.City, property.Location, property.Latitude, property.Longitude, pq.Array(property.Type), pq.Array(property.Category), property.Features, property.Price, pq.Array(property.Currency), property.Nearby, pq.Array(property.Amenities), property.Status, property.ID, property.Version}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel() 
 	
-	err := p.DB.QueryRowContext(ctx, query, args...).Scan(&property.Version)
+	err := p.DB.QueryRowContext(ctx, query, args...).Scan(&property.UpdatedAt, &property.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -211,3 +336,148 @@ func (p PropertyModel) Delete(id int64) error {
 
 	return nil
 }
+
+// propertyColumns lists the properties columns in the order expected by Property.scanTargets.
+const propertyColumns = `properties.id, coalesce(properties.user_id, 0), properties.created_at, properties.title, properties.description, properties.city, properties.location, properties.latitude, properties.longitude, properties.type, properties.category, properties.features, properties.price, properties.currency, properties.nearby, properties.amenities, properties.status, properties.updated_at, properties.version,
+	EXISTS (SELECT 1 FROM agent_profiles WHERE agent_profiles.user_id = properties.user_id AND agent_profiles.verified_at IS NOT NULL)`
+
+// scanTargets returns pointers to the property fields in the order of propertyColumns.
+func (property *Property) scanTargets() []interface{} {
+	return []interface{}{
+		&property.ID,
+		&property.UserID,
+		&property.CreatedAt,
+		&property.Title,
+		&property.Description,
+		&property.City,
+		&property.Location,
+		&property.Latitude,
+		&property.Longitude,
+		pq.Array(&property.Type),
+		pq.Array(&property.Category),
+		&property.Features,
+		&property.Price,
+		pq.Array(&property.Currency),
+		&property.Nearby,
+		pq.Array(&property.Amenities),
+		&property.Status,
+		&property.UpdatedAt,
+		&property.Version,
+		&property.AgentVerified,
+	}
+}
+
+// GetAll returns a paginated list of properties matching the provided filters.
+func (p PropertyModel) GetAll(propertyFilters PropertyFilters, filters Filters) ([]*Property, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM properties
+	WHERE %s
+	ORDER BY %s %s, id ASC
+	LIMIT $%d OFFSET $%d`, propertyColumns, propertyFilterClause, filters.sortColumn(), filters.sortDirection(), propertyFilterArgs+1, propertyFilterArgs+2)
+
+	args := append(propertyFilters.args(), filters.limit(), filters.offset())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	properties := []*Property{}
+
+	for rows.Next() {
+		var property Property
+		err := rows.Scan(append([]interface{}{&totalRecords}, property.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		properties = append(properties, &property)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return properties, metadata, nil
+}
+
+// GetMany fetches the records with the provided IDs from the properties table, in no particular order.
+func (p PropertyModel) GetMany(ids []int64) ([]*Property, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM properties
+	WHERE id = ANY($1)`, propertyColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := []*Property{}
+
+	for rows.Next() {
+		var property Property
+		err := rows.Scan(property.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, &property)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return properties, nil
+}
+
+// GetPublishedForUsers returns a paginated list of the published properties listed by a set of users, most
+// recently updated first.
+func (p PropertyModel) GetPublishedForUsers(userIDs []int64, filters Filters) ([]*Property, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM properties
+	WHERE user_id = ANY($1) AND status = $2
+	ORDER BY updated_at DESC, id ASC
+	LIMIT $3 OFFSET $4`, propertyColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query, pq.Array(userIDs), StatusPublished, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	properties := []*Property{}
+
+	for rows.Next() {
+		var property Property
+		err := rows.Scan(append([]interface{}{&totalRecords}, property.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		properties = append(properties, &property)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return properties, metadata, nil
+}
@@ -0,0 +1,438 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// ErrDuplicateApplication is returned when a user applies for a property they have an open application for.
+var ErrDuplicateApplication = errors.New("duplicate application")
+
+// Rental application statuses.
+const (
+	ApplicationSubmitted   = "submitted"
+	ApplicationShortlisted = "shortlisted"
+	ApplicationApproved    = "approved"
+	ApplicationDeclined    = "declined"
+	ApplicationWithdrawn   = "withdrawn"
+)
+
+// PermittedEmploymentStatuses lists the employment statuses an applicant can declare.
+var PermittedEmploymentStatuses = []string{"employed", "self_employed", "student", "retired", "unemployed"}
+
+// MaxApplicationDocuments is the number of documents that can be uploaded with an application.
+const MaxApplicationDocuments = 10
+
+// PermittedDocumentKinds lists the kinds of documents that can be uploaded with an application.
+var PermittedDocumentKinds = []string{"payslip", "id", "bank_statement", "reference", "other"}
+
+// applicationTransitions lists the statuses each application status can change to, and whether the
+// landlord or the applicant makes the change. Approved, declined and withdrawn applications are closed.
+var applicationTransitions = map[string]map[string]string{
+	ApplicationSubmitted: {
+		ApplicationShortlisted: "landlord",
+		ApplicationApproved:    "landlord",
+		ApplicationDeclined:    "landlord",
+		ApplicationWithdrawn:   "applicant",
+	},
+	ApplicationShortlisted: {
+		ApplicationApproved:  "landlord",
+		ApplicationDeclined:  "landlord",
+		ApplicationWithdrawn: "applicant",
+	},
+}
+
+// RentalApplication contains a tenant's application to rent a property.
+type RentalApplication struct {
+	ID               int64                  `json:"id"`
+	CreatedAt        time.Time              `json:"created_at"`
+	PropertyID       int64                  `json:"property_id"`
+	PropertyTitle    string                 `json:"property_title"`
+	LandlordID       int64                  `json:"landlord_id"`
+	ApplicantID      int64                  `json:"applicant_id"`
+	EmploymentStatus string                 `json:"employment_status"`
+	Employer         string                 `json:"employer,omitempty"`
+	JobTitle         string                 `json:"job_title,omitempty"`
+	AnnualIncome     float64                `json:"annual_income"`
+	MoveInDate       string                 `json:"move_in_date"`
+	Occupants        int                    `json:"occupants"`
+	Message          string                 `json:"message,omitempty"`
+	Status           string                 `json:"status"`
+	DecisionReason   string                 `json:"decision_reason,omitempty"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+	Version          int32                  `json:"version"`
+	Documents        []*ApplicationDocument `json:"documents,omitempty"`
+}
+
+// IsOpen reports whether the application can still change status.
+func (application *RentalApplication) IsOpen() bool {
+	return len(applicationTransitions[application.Status]) > 0
+}
+
+// CanView reports whether a user is the applicant or the landlord of the application, who are the only users
+// allowed to see it and its documents.
+func (application *RentalApplication) CanView(userID int64) bool {
+	return userID != 0 && (userID == application.ApplicantID || userID == application.LandlordID)
+}
+
+// ValidateRentalApplication validates a rental application based on set validation criteria.
+func ValidateRentalApplication(v *validator.Validator, application *RentalApplication) {
+	v.Check(application.ApplicantID != application.LandlordID, "property", "must not be your own listing")
+	v.Check(validator.In(application.EmploymentStatus, PermittedEmploymentStatuses...), "employment_status", "must be employed, self_employed, student, retired or unemployed")
+	v.Check(len(application.Employer) <= 200, "employer", "must not be more than 200 bytes long")
+	v.Check(len(application.JobTitle) <= 200, "job_title", "must not be more than 200 bytes long")
+	v.Check(application.AnnualIncome >= 0, "annual_income", "must not be a negative number")
+	v.Check(application.Occupants > 0, "occupants", "must be greater than zero")
+	v.Check(application.Occupants <= 20, "occupants", "must not be more than 20")
+	v.Check(len(application.Message) <= 2000, "message", "must not be more than 2000 bytes long")
+
+	moveIn, err := time.Parse(dateLayout, application.MoveInDate)
+	if err != nil {
+		v.AddError("move_in_date", "must be a date in the YYYY-MM-DD format")
+		return
+	}
+	v.Check(!moveIn.Before(today()), "move_in_date", "must not be in the past")
+}
+
+// ValidateApplicationTransition validates a change of an application's status by its landlord, or by its
+// applicant when byApplicant is true.
+func ValidateApplicationTransition(v *validator.Validator, application *RentalApplication, to, reason string, byApplicant bool) {
+	v.Check(len(reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+
+	actor, ok := applicationTransitions[application.Status][to]
+	if !ok {
+		v.AddError("status", fmt.Sprintf("a %s application cannot be %s", application.Status, to))
+		return
+	}
+
+	if byApplicant != (actor == "applicant") {
+		v.AddError("status", fmt.Sprintf("only the %s can mark an application %s", actor, to))
+	}
+}
+
+// ApplicationDocument contains a document uploaded with a rental application. The document itself is kept
+// in private storage under StorageKey.
+type ApplicationDocument struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	ApplicationID int64     `json:"application_id"`
+	Kind          string    `json:"kind"`
+	Filename      string    `json:"filename"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	StorageKey    string    `json:"-"`
+}
+
+// ValidateApplicationDocument validates a document based on set validation criteria.
+func ValidateApplicationDocument(v *validator.Validator, document *ApplicationDocument) {
+	v.Check(validator.In(document.Kind, PermittedDocumentKinds...), "kind", "must be payslip, id, bank_statement, reference or other")
+	v.Check(document.Filename != "", "file", "must be provided")
+	v.Check(len(document.Filename) <= 255, "file", "must have a name of at most 255 bytes")
+	v.Check(validator.In(document.ContentType, "application/pdf", "image/jpeg", "image/png"), "file", "must be a PDF, JPEG or PNG file")
+}
+
+// RentalApplicationModel struct wraps a sql.DB connection pool.
+type RentalApplicationModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a new record into the rental_applications table, returning ErrDuplicateApplication if the
+// applicant already has an open or approved application for the property.
+func (m RentalApplicationModel) Insert(application *RentalApplication) error {
+	query := `
+	INSERT INTO rental_applications (property_id, applicant_id, employment_status, employer, job_title, annual_income,
+		move_in_date, occupants, message)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id, created_at, status, updated_at, version`
+
+	args := []interface{}{
+		application.PropertyID,
+		application.ApplicantID,
+		application.EmploymentStatus,
+		application.Employer,
+		application.JobTitle,
+		application.AnnualIncome,
+		application.MoveInDate,
+		application.Occupants,
+		application.Message,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&application.ID, &application.CreatedAt, &application.Status, &application.UpdatedAt, &application.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "rental_applications_active_idx"`:
+			return ErrDuplicateApplication
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get fetches a specific rental application.
+func (m RentalApplicationModel) Get(id int64) (*RentalApplication, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM rental_applications
+	INNER JOIN properties ON properties.id = rental_applications.property_id
+	WHERE rental_applications.id = $1`, applicationColumns)
+
+	var application RentalApplication
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(application.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &application, nil
+}
+
+// GetAllForApplicant returns a paginated list of a user's rental applications, newest first.
+func (m RentalApplicationModel) GetAllForApplicant(applicantID int64, filters Filters) ([]*RentalApplication, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM rental_applications
+	INNER JOIN properties ON properties.id = rental_applications.property_id
+	WHERE rental_applications.applicant_id = $1
+	ORDER BY rental_applications.created_at DESC, rental_applications.id DESC
+	LIMIT $2 OFFSET $3`, applicationColumns)
+
+	return m.query(query, filters, applicantID, filters.limit(), filters.offset())
+}
+
+// GetAllForProperty returns a paginated list of the rental applications for a property, optionally with a
+// specific status, oldest first.
+func (m RentalApplicationModel) GetAllForProperty(propertyID int64, status string, filters Filters) ([]*RentalApplication, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM rental_applications
+	INNER JOIN properties ON properties.id = rental_applications.property_id
+	WHERE rental_applications.property_id = $1 AND (rental_applications.status = $2 OR $2 = '')
+	ORDER BY rental_applications.created_at ASC, rental_applications.id ASC
+	LIMIT $3 OFFSET $4`, applicationColumns)
+
+	return m.query(query, filters, propertyID, status, filters.limit(), filters.offset())
+}
+
+// query runs a paginated query returning rental applications.
+func (m RentalApplicationModel) query(query string, filters Filters, args ...interface{}) ([]*RentalApplication, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	applications := []*RentalApplication{}
+
+	for rows.Next() {
+		var application RentalApplication
+		err := rows.Scan(append([]interface{}{&totalRecords}, application.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		applications = append(applications, &application)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return applications, metadata, nil
+}
+
+// UpdateStatus changes the status of an application, returning ErrEditConflict if it has changed since it was fetched.
+func (m RentalApplicationModel) UpdateStatus(application *RentalApplication, status, reason string) error {
+	query := `
+	UPDATE rental_applications
+	SET status = $1, decision_reason = $2, updated_at = NOW(), version = version + 1
+	WHERE id = $3 AND version = $4
+	RETURNING updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, status, reason, application.ID, application.Version).Scan(&application.UpdatedAt, &application.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	application.Status = status
+	application.DecisionReason = reason
+
+	return nil
+}
+
+// InsertDocument inserts a new record into the rental_application_documents table.
+func (m RentalApplicationModel) InsertDocument(document *ApplicationDocument) error {
+	query := `
+	INSERT INTO rental_application_documents (application_id, kind, filename, content_type, size, storage_key)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at`
+
+	args := []interface{}{document.ApplicationID, document.Kind, document.Filename, document.ContentType, document.Size, document.StorageKey}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&document.ID, &document.CreatedAt)
+}
+
+// CountDocuments returns the number of documents uploaded with an application.
+func (m RentalApplicationModel) CountDocuments(applicationID int64) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+
+	err := m.DB.QueryRowContext(ctx, `SELECT count(*) FROM rental_application_documents WHERE application_id = $1`, applicationID).Scan(&count)
+
+	return count, err
+}
+
+// GetDocuments returns the documents uploaded with an application.
+func (m RentalApplicationModel) GetDocuments(applicationID int64) ([]*ApplicationDocument, error) {
+	query := `
+	SELECT id, created_at, application_id, kind, filename, content_type, size, storage_key
+	FROM rental_application_documents
+	WHERE application_id = $1
+	ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	documents := []*ApplicationDocument{}
+
+	for rows.Next() {
+		var document ApplicationDocument
+		err := rows.Scan(&document.ID, &document.CreatedAt, &document.ApplicationID, &document.Kind, &document.Filename, &document.ContentType, &document.Size, &document.StorageKey)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, &document)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// GetDocument fetches a specific document.
+func (m RentalApplicationModel) GetDocument(id int64) (*ApplicationDocument, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT id, created_at, application_id, kind, filename, content_type, size, storage_key
+	FROM rental_application_documents
+	WHERE id = $1`
+
+	var document ApplicationDocument
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&document.ID, &document.CreatedAt, &document.ApplicationID, &document.Kind, &document.Filename, &document.ContentType, &document.Size, &document.StorageKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &document, nil
+}
+
+// DeleteDocument deletes a specific document record.
+func (m RentalApplicationModel) DeleteDocument(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM rental_application_documents WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// applicationColumns lists the rental application columns in the order expected by RentalApplication.scanTargets.
+const applicationColumns = `rental_applications.id, rental_applications.created_at, rental_applications.property_id,
+	properties.title, coalesce(properties.user_id, 0), rental_applications.applicant_id, rental_applications.employment_status,
+	rental_applications.employer, rental_applications.job_title, rental_applications.annual_income,
+	to_char(rental_applications.move_in_date, 'YYYY-MM-DD'), rental_applications.occupants, rental_applications.message,
+	rental_applications.status, rental_applications.decision_reason, rental_applications.updated_at, rental_applications.version`
+
+// scanTargets returns pointers to the rental application fields in the order of applicationColumns.
+func (application *RentalApplication) scanTargets() []interface{} {
+	return []interface{}{
+		&application.ID,
+		&application.CreatedAt,
+		&application.PropertyID,
+		&application.PropertyTitle,
+		&application.LandlordID,
+		&application.ApplicantID,
+		&application.EmploymentStatus,
+		&application.Employer,
+		&application.JobTitle,
+		&application.AnnualIncome,
+		&application.MoveInDate,
+		&application.Occupants,
+		&application.Message,
+		&application.Status,
+		&application.DecisionReason,
+		&application.UpdatedAt,
+		&application.Version,
+	}
+}
@@ -0,0 +1,495 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// dateLayout is the layout of the dates of rental seasons, blocks and stays.
+const dateLayout = "2006-01-02"
+
+// maxStayNights is the longest stay that can be quoted or searched for.
+const maxStayNights = 365
+
+// Rental block kinds.
+const (
+	BlockBlocked = "blocked"
+	BlockBooked  = "booked"
+)
+
+// RentalRate contains the base nightly pricing and stay rules of a short-let property. A property is
+// offered as a short-let once it has a rate.
+type RentalRate struct {
+	PropertyID        int64     `json:"property_id"`
+	NightlyRate       float64   `json:"nightly_rate"`
+	MinNights         int       `json:"min_nights"`
+	MaxGuests         int       `json:"max_guests"`
+	IncludedGuests    int       `json:"included_guests"`
+	ExtraGuestFee     float64   `json:"extra_guest_fee"`
+	CleaningFee       float64   `json:"cleaning_fee"`
+	ServiceFeePercent float64   `json:"service_fee_percent"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	Version           int32     `json:"version"`
+}
+
+// ValidateRentalRate validates a rental rate based on set validation criteria.
+func ValidateRentalRate(v *validator.Validator, rate *RentalRate) {
+	v.Check(rate.NightlyRate > 0, "nightly_rate", "must be a positive number")
+	v.Check(rate.MinNights > 0, "min_nights", "must be greater than zero")
+	v.Check(rate.MinNights <= maxStayNights, "min_nights", "must not be more than 365")
+	v.Check(rate.MaxGuests > 0, "max_guests", "must be greater than zero")
+	v.Check(rate.MaxGuests <= 50, "max_guests", "must not be more than 50")
+	v.Check(rate.IncludedGuests > 0, "included_guests", "must be greater than zero")
+	v.Check(rate.IncludedGuests <= rate.MaxGuests, "included_guests", "must not be more than max_guests")
+	v.Check(rate.ExtraGuestFee >= 0, "extra_guest_fee", "must not be a negative number")
+	v.Check(rate.CleaningFee >= 0, "cleaning_fee", "must not be a negative number")
+	v.Check(rate.ServiceFeePercent >= 0, "service_fee_percent", "must not be a negative number")
+	v.Check(rate.ServiceFeePercent <= 100, "service_fee_percent", "must not be more than 100")
+}
+
+// Season contains a nightly rate that overrides the base rate for the nights from its start date to its
+// end date inclusive. A non-zero minimum stay overrides the base minimum for stays starting in the season.
+type Season struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	PropertyID  int64     `json:"property_id"`
+	Name        string    `json:"name,omitempty"`
+	StartDate   string    `json:"start_date"`
+	EndDate     string    `json:"end_date"`
+	NightlyRate float64   `json:"nightly_rate"`
+	MinNights   int       `json:"min_nights,omitempty"`
+}
+
+// ValidateSeason validates a season based on set validation criteria.
+func ValidateSeason(v *validator.Validator, season *Season) {
+	v.Check(len(season.Name) <= 100, "name", "must not be more than 100 bytes long")
+	validateDateRange(v, season.StartDate, season.EndDate, "start_date", "end_date", true)
+	v.Check(season.NightlyRate > 0, "nightly_rate", "must be a positive number")
+	v.Check(season.MinNights >= 0, "min_nights", "must not be a negative number")
+	v.Check(season.MinNights <= maxStayNights, "min_nights", "must not be more than 365")
+}
+
+// RentalBlock contains a range of nights during which a short-let property cannot be booked, from its start
+// date up to but excluding its end date, so that a stay may check in on the day a block ends. Blocks imported
+// from a calendar feed hold the feed ID and the UID of the event they were imported from.
+type RentalBlock struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	PropertyID int64     `json:"property_id"`
+	StartDate  string    `json:"start_date"`
+	EndDate    string    `json:"end_date"`
+	Kind       string    `json:"kind"`
+	Note       string    `json:"note,omitempty"`
+	FeedID     int64     `json:"feed_id,omitempty"`
+	UID        string    `json:"-"`
+}
+
+// ValidateRentalBlock validates a rental block based on set validation criteria.
+func ValidateRentalBlock(v *validator.Validator, block *RentalBlock) {
+	validateDateRange(v, block.StartDate, block.EndDate, "start_date", "end_date", false)
+	v.Check(validator.In(block.Kind, BlockBlocked, BlockBooked), "kind", "must be blocked or booked")
+	v.Check(len(block.Note) <= 500, "note", "must not be more than 500 bytes long")
+}
+
+// ValidateStay validates the check-in and check-out dates and number of guests of a stay.
+func ValidateStay(v *validator.Validator, checkIn, checkOut string, guests int) {
+	if validateDateRange(v, checkIn, checkOut, "check_in", "check_out", false) {
+		start, _ := time.Parse(dateLayout, checkIn)
+		v.Check(!start.Before(today()), "check_in", "must not be in the past")
+	}
+	v.Check(guests > 0, "guests", "must be greater than zero")
+}
+
+// ValidateCalendarRange validates the dates between which the availability of a short-let property is shown.
+func ValidateCalendarRange(v *validator.Validator, from, to string) {
+	validateDateRange(v, from, to, "from", "to", false)
+}
+
+// validateDateRange checks that both dates of a range are provided in the YYYY-MM-DD format, that the end
+// follows the start, or is the same day when inclusive is true, and that the range spans at most a year.
+// It reports whether the range is valid.
+func validateDateRange(v *validator.Validator, startDate, endDate, startKey, endKey string, inclusive bool) bool {
+	start, err := time.Parse(dateLayout, startDate)
+	if err != nil {
+		v.AddError(startKey, "must be a date in the YYYY-MM-DD format")
+		return false
+	}
+
+	end, err := time.Parse(dateLayout, endDate)
+	if err != nil {
+		v.AddError(endKey, "must be a date in the YYYY-MM-DD format")
+		return false
+	}
+
+	if inclusive {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	switch {
+	case !end.After(start) && inclusive:
+		v.AddError(endKey, "must not be before "+startKey)
+		return false
+	case !end.After(start):
+		v.AddError(endKey, "must be after "+startKey)
+		return false
+	case nights(start, end) > maxStayNights:
+		v.AddError(endKey, "must be at most 365 days after "+startKey)
+		return false
+	}
+
+	return true
+}
+
+// today returns midnight UTC of the current date, for comparisons with dates without a time.
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// nights returns the number of nights between two dates.
+func nights(start, end time.Time) int {
+	return int(math.Round(end.Sub(start).Hours() / 24))
+}
+
+// NightPrice contains the price of one night of a stay.
+type NightPrice struct {
+	Date   string  `json:"date"`
+	Rate   float64 `json:"rate"`
+	Season string  `json:"season,omitempty"`
+}
+
+// RentalQuote contains the price breakdown of a stay at a short-let property.
+type RentalQuote struct {
+	PropertyID    int64         `json:"property_id"`
+	CheckIn       string        `json:"check_in"`
+	CheckOut      string        `json:"check_out"`
+	Nights        int           `json:"nights"`
+	Guests        int           `json:"guests"`
+	MinNights     int           `json:"min_nights"`
+	Currency      string        `json:"currency,omitempty"`
+	NightlyPrices []*NightPrice `json:"nightly_prices"`
+	Accommodation float64       `json:"accommodation"`
+	ExtraGuestFee float64       `json:"extra_guest_fee"`
+	CleaningFee   float64       `json:"cleaning_fee"`
+	ServiceFee    float64       `json:"service_fee"`
+	Total         float64       `json:"total"`
+}
+
+// NewRentalQuote prices a stay with valid dates. Each night is charged at the rate of the latest starting
+// season covering it, or the base rate outside seasons. Guests beyond those included are charged the extra
+// guest fee per night, and the service fee is a percentage of the rest of the price. The minimum stay is
+// that of the season covering the check-in night, if it sets one.
+func NewRentalQuote(rate *RentalRate, seasons []*Season, checkIn, checkOut string, guests int) *RentalQuote {
+	quote := &RentalQuote{
+		PropertyID:    rate.PropertyID,
+		CheckIn:       checkIn,
+		CheckOut:      checkOut,
+		Guests:        guests,
+		MinNights:     rate.MinNights,
+		NightlyPrices: []*NightPrice{},
+	}
+
+	start, _ := time.Parse(dateLayout, checkIn)
+	end, _ := time.Parse(dateLayout, checkOut)
+
+	for night := start; night.Before(end); night = night.AddDate(0, 0, 1) {
+		date := night.Format(dateLayout)
+		price := &NightPrice{Date: date, Rate: rate.NightlyRate}
+
+		var current *Season
+		for _, season := range seasons {
+			if season.StartDate <= date && date <= season.EndDate && (current == nil || season.StartDate > current.StartDate) {
+				current = season
+			}
+		}
+
+		if current != nil {
+			price.Rate = current.NightlyRate
+			price.Season = current.Name
+			if night.Equal(start) && current.MinNights > 0 {
+				quote.MinNights = current.MinNights
+			}
+		}
+
+		quote.NightlyPrices = append(quote.NightlyPrices, price)
+		quote.Accommodation += price.Rate
+	}
+
+	quote.Nights = len(quote.NightlyPrices)
+
+	if extraGuests := guests - rate.IncludedGuests; extraGuests > 0 {
+		quote.ExtraGuestFee = float64(extraGuests*quote.Nights) * rate.ExtraGuestFee
+	}
+
+	quote.CleaningFee = rate.CleaningFee
+	quote.Accommodation = roundMoney(quote.Accommodation)
+	quote.ExtraGuestFee = roundMoney(quote.ExtraGuestFee)
+
+	subtotal := quote.Accommodation + quote.ExtraGuestFee + quote.CleaningFee
+	quote.ServiceFee = roundMoney(subtotal * rate.ServiceFeePercent / 100)
+	quote.Total = roundMoney(subtotal + quote.ServiceFee)
+
+	return quote
+}
+
+// roundMoney rounds an amount to two decimal places.
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// RentalModel struct wraps a sql.DB connection pool.
+type RentalModel struct {
+	DB *sql.DB
+}
+
+// GetRate fetches the rental rate of a property, returning ErrRecordNotFound if it is not a short-let.
+func (m RentalModel) GetRate(propertyID int64) (*RentalRate, error) {
+	query := `
+	SELECT property_id, nightly_rate, min_nights, max_guests, included_guests, extra_guest_fee, cleaning_fee,
+		service_fee_percent, updated_at, version
+	FROM rental_rates
+	WHERE property_id = $1`
+
+	var rate RentalRate
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, propertyID).Scan(
+		&rate.PropertyID,
+		&rate.NightlyRate,
+		&rate.MinNights,
+		&rate.MaxGuests,
+		&rate.IncludedGuests,
+		&rate.ExtraGuestFee,
+		&rate.CleaningFee,
+		&rate.ServiceFeePercent,
+		&rate.UpdatedAt,
+		&rate.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &rate, nil
+}
+
+// SetRate creates or replaces the rental rate of a property. A rate with a non-zero version replaces the
+// existing rate only if it has not been changed since it was fetched, returning ErrEditConflict otherwise.
+func (m RentalModel) SetRate(rate *RentalRate) error {
+	query := `
+	INSERT INTO rental_rates (property_id, nightly_rate, min_nights, max_guests, included_guests, extra_guest_fee,
+		cleaning_fee, service_fee_percent)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (property_id) DO UPDATE
+	SET nightly_rate = EXCLUDED.nightly_rate, min_nights = EXCLUDED.min_nights, max_guests = EXCLUDED.max_guests,
+		included_guests = EXCLUDED.included_guests, extra_guest_fee = EXCLUDED.extra_guest_fee,
+		cleaning_fee = EXCLUDED.cleaning_fee, service_fee_percent = EXCLUDED.service_fee_percent,
+		updated_at = NOW(), version = rental_rates.version + 1
+	WHERE $9 = 0 OR rental_rates.version = $9
+	RETURNING updated_at, version`
+
+	args := []interface{}{
+		rate.PropertyID,
+		rate.NightlyRate,
+		rate.MinNights,
+		rate.MaxGuests,
+		rate.IncludedGuests,
+		rate.ExtraGuestFee,
+		rate.CleaningFee,
+		rate.ServiceFeePercent,
+		rate.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&rate.UpdatedAt, &rate.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InsertSeason inserts a new record into the rental_seasons table.
+func (m RentalModel) InsertSeason(season *Season) error {
+	query := `
+	INSERT INTO rental_seasons (property_id, name, start_date, end_date, nightly_rate, min_nights)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at`
+
+	args := []interface{}{season.PropertyID, season.Name, season.StartDate, season.EndDate, season.NightlyRate, season.MinNights}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&season.ID, &season.CreatedAt)
+}
+
+// GetSeasons returns the seasons of a property that end on or after a date, in order of their start date.
+func (m RentalModel) GetSeasons(propertyID int64, from string) ([]*Season, error) {
+	query := `
+	SELECT id, created_at, property_id, name, to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD'),
+		nightly_rate, min_nights
+	FROM rental_seasons
+	WHERE property_id = $1 AND end_date >= $2::date
+	ORDER BY start_date, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, propertyID, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seasons := []*Season{}
+
+	for rows.Next() {
+		var season Season
+		err := rows.Scan(&season.ID, &season.CreatedAt, &season.PropertyID, &season.Name, &season.StartDate, &season.EndDate, &season.NightlyRate, &season.MinNights)
+		if err != nil {
+			return nil, err
+		}
+		seasons = append(seasons, &season)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return seasons, nil
+}
+
+// DeleteSeason deletes a season of a property owned by a user.
+func (m RentalModel) DeleteSeason(id, ownerID int64) error {
+	return m.delete(`
+	DELETE FROM rental_seasons
+	USING properties
+	WHERE rental_seasons.id = $1 AND properties.id = rental_seasons.property_id AND properties.user_id = $2`, id, ownerID)
+}
+
+// InsertBlock inserts a new record into the rental_blocks table.
+func (m RentalModel) InsertBlock(block *RentalBlock) error {
+	query := `
+	INSERT INTO rental_blocks (property_id, start_date, end_date, kind, note)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at`
+
+	args := []interface{}{block.PropertyID, block.StartDate, block.EndDate, block.Kind, block.Note}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&block.ID, &block.CreatedAt)
+}
+
+// GetBlocks returns the blocks of a property overlapping the nights from one date up to but excluding
+// another, in order of their start date.
+func (m RentalModel) GetBlocks(propertyID int64, from, to string) ([]*RentalBlock, error) {
+	query := `
+	SELECT id, created_at, property_id, to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD'), kind, note,
+		coalesce(feed_id, 0)
+	FROM rental_blocks
+	WHERE property_id = $1 AND start_date < $3::date AND end_date > $2::date
+	ORDER BY start_date, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, propertyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []*RentalBlock{}
+
+	for rows.Next() {
+		var block RentalBlock
+		err := rows.Scan(&block.ID, &block.CreatedAt, &block.PropertyID, &block.StartDate, &block.EndDate, &block.Kind, &block.Note, &block.FeedID)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, &block)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// DeleteBlock deletes a block of a property owned by a user. Blocks imported from a calendar feed are
+// removed by the feed instead.
+func (m RentalModel) DeleteBlock(id, ownerID int64) error {
+	return m.delete(`
+	DELETE FROM rental_blocks
+	USING properties
+	WHERE rental_blocks.id = $1 AND rental_blocks.feed_id IS NULL
+	AND properties.id = rental_blocks.property_id AND properties.user_id = $2`, id, ownerID)
+}
+
+// IsAvailable reports whether none of the nights of a stay at a property are blocked or booked.
+func (m RentalModel) IsAvailable(propertyID int64, checkIn, checkOut string) (bool, error) {
+	query := `
+	SELECT NOT EXISTS (
+		SELECT 1 FROM rental_blocks
+		WHERE property_id = $1 AND start_date < $3::date AND end_date > $2::date
+	)`
+
+	var available bool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, propertyID, checkIn, checkOut).Scan(&available)
+	if err != nil {
+		return false, err
+	}
+
+	return available, nil
+}
+
+// delete runs a delete query for a record of a property owned by a user, returning ErrRecordNotFound if no record was deleted.
+func (m RentalModel) delete(query string, id, ownerID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package data
+
+import "testing"
+
+func TestNewRentalQuote(t *testing.T) {
+	rate := &RentalRate{
+		PropertyID:        1,
+		NightlyRate:       100,
+		MinNights:         2,
+		IncludedGuests:    2,
+		ExtraGuestFee:     15,
+		CleaningFee:       40,
+		ServiceFeePercent: 10,
+	}
+	seasons := []*Season{
+		{Name: "Summer", StartDate: "2024-07-01", EndDate: "2024-08-31", NightlyRate: 150, MinNights: 5},
+		{Name: "Festival", StartDate: "2024-08-10", EndDate: "2024-08-12", NightlyRate: 250},
+	}
+
+	tests := []struct {
+		name          string
+		checkIn       string
+		checkOut      string
+		guests        int
+		wantNights    int
+		wantMinNights int
+		wantRates     []float64
+		wantSeasons   []string
+		wantTotal     float64
+	}{
+		{
+			name:          "Base rate",
+			checkIn:       "2024-06-10",
+			checkOut:      "2024-06-12",
+			guests:        2,
+			wantNights:    2,
+			wantMinNights: 2,
+			wantRates:     []float64{100, 100},
+			wantSeasons:   []string{"", ""},
+			wantTotal:     264, // (200 + 40) * 1.1
+		},
+		{
+			name:          "Into a season",
+			checkIn:       "2024-06-29",
+			checkOut:      "2024-07-02",
+			guests:        3,
+			wantNights:    3,
+			wantMinNights: 2,
+			wantRates:     []float64{100, 100, 150},
+			wantSeasons:   []string{"", "", "Summer"},
+			wantTotal:     478.5, // (350 + 3*15 + 40) * 1.1
+		},
+		{
+			name:          "Latest starting season wins",
+			checkIn:       "2024-08-09",
+			checkOut:      "2024-08-11",
+			guests:        1,
+			wantNights:    2,
+			wantMinNights: 5,
+			wantRates:     []float64{150, 250},
+			wantSeasons:   []string{"Summer", "Festival"},
+			wantTotal:     484, // (400 + 40) * 1.1
+		},
+		{
+			name:          "Season without a minimum stay",
+			checkIn:       "2024-08-10",
+			checkOut:      "2024-08-11",
+			guests:        2,
+			wantNights:    1,
+			wantMinNights: 2,
+			wantRates:     []float64{250},
+			wantSeasons:   []string{"Festival"},
+			wantTotal:     319, // (250 + 40) * 1.1
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote := NewRentalQuote(rate, seasons, tt.checkIn, tt.checkOut, tt.guests)
+
+			if quote.Nights != tt.wantNights {
+				t.Errorf("got %d nights; want %d", quote.Nights, tt.wantNights)
+			}
+			if quote.MinNights != tt.wantMinNights {
+				t.Errorf("got minimum stay of %d nights; want %d", quote.MinNights, tt.wantMinNights)
+			}
+			if quote.Total != tt.wantTotal {
+				t.Errorf("got total %v; want %v", quote.Total, tt.wantTotal)
+			}
+
+			if len(quote.NightlyPrices) != len(tt.wantRates) {
+				t.Fatalf("got %d nightly prices; want %d", len(quote.NightlyPrices), len(tt.wantRates))
+			}
+			for i, price := range quote.NightlyPrices {
+				if price.Rate != tt.wantRates[i] || price.Season != tt.wantSeasons[i] {
+					t.Errorf("got %s at %v (%q); want %v (%q)", price.Date, price.Rate, price.Season, tt.wantRates[i], tt.wantSeasons[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// Listing report reason codes.
+const (
+	ReportScam       = "scam"
+	ReportSold       = "sold"
+	ReportLet        = "let"
+	ReportInaccurate = "inaccurate"
+	ReportDuplicate  = "duplicate"
+	ReportOffensive  = "offensive"
+	ReportOther      = "other"
+)
+
+// PermittedReportReasons lists the reason codes a listing can be reported for.
+var PermittedReportReasons = []string{ReportScam, ReportSold, ReportLet, ReportInaccurate, ReportDuplicate, ReportOffensive, ReportOther}
+
+// ListingReport contains a report that a listing is a scam, no longer available or otherwise inappropriate.
+// Reporter identifies the user or anonymous client that made the report, so that each reporter is counted once.
+type ListingReport struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	PropertyID int64     `json:"property_id"`
+	Reporter   string    `json:"-"`
+	UserID     int64     `json:"-"`
+	Reason     string    `json:"reason"`
+	Comment    string    `json:"comment,omitempty"`
+}
+
+// ValidateListingReport validates a listing report based on set validation criteria.
+func ValidateListingReport(v *validator.Validator, report *ListingReport) {
+	v.Check(report.Reason != "", "reason", "must be provided")
+	v.Check(validator.In(report.Reason, PermittedReportReasons...), "reason", "must be a permitted reason")
+	v.Check(report.Reason != ReportOther || report.Comment != "", "comment", "must be provided when the reason is other")
+	v.Check(len(report.Comment) <= 1000, "comment", "must not be more than 1000 bytes long")
+}
+
+// ReportSummary aggregates the reports made about a listing. UserReporters counts the reporters who were
+// signed in, while Reporters also counts anonymous ones.
+type ReportSummary struct {
+	PropertyID     int64          `json:"property_id"`
+	Title          string         `json:"title"`
+	Status         string         `json:"status"`
+	Reporters      int            `json:"reporters"`
+	UserReporters  int            `json:"user_reporters"`
+	Reasons        map[string]int `json:"reasons"`
+	LastReportedAt time.Time      `json:"last_reported_at"`
+}
+
+// String describes the summary as the number of reporters and a breakdown of the reasons, most common first.
+func (s ReportSummary) String() string {
+	reasons := make([]string, 0, len(s.Reasons))
+	for reason := range s.Reasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if s.Reasons[reasons[i]] != s.Reasons[reasons[j]] {
+			return s.Reasons[reasons[i]] > s.Reasons[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+
+	for i, reason := range reasons {
+		reasons[i] = fmt.Sprintf("%s (%d)", reason, s.Reasons[reason])
+	}
+
+	return fmt.Sprintf("reported by %d users, %d signed in: %s", s.Reporters, s.UserReporters, strings.Join(reasons, ", "))
+}
+
+// ReportModel struct wraps a sql.DB connection pool.
+type ReportModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a new record into the listing_reports table. A reporter who reports the same listing again
+// replaces their earlier report, which counts again if it had been resolved.
+func (m ReportModel) Insert(report *ListingReport) error {
+	query := `
+	INSERT INTO listing_reports (property_id, reporter, user_id, reason, comment)
+	VALUES ($1, $2, NULLIF($3, 0), $4, $5)
+	ON CONFLICT (property_id, reporter)
+	DO UPDATE SET reason = EXCLUDED.reason, comment = EXCLUDED.comment, created_at = NOW(), resolved_at = NULL
+	RETURNING id, created_at`
+
+	args := []interface{}{report.PropertyID, report.Reporter, report.UserID, report.Reason, report.Comment}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&report.ID, &report.CreatedAt)
+}
+
+// GetSummary aggregates the unresolved reports made about a listing. It returns ErrRecordNotFound if the
+// listing has no unresolved reports.
+func (m ReportModel) GetSummary(propertyID int64) (*ReportSummary, error) {
+	summaries, _, err := m.getSummaries(propertyID, Filters{Page: 1, PageSize: 1})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(summaries) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return summaries[0], nil
+}
+
+// GetAllSummaries returns a paginated list of the listings with unresolved reports, most reported first.
+func (m ReportModel) GetAllSummaries(filters Filters) ([]*ReportSummary, Metadata, error) {
+	return m.getSummaries(0, filters)
+}
+
+// getSummaries aggregates the unresolved reports of a listing, or of every listing when propertyID is 0.
+func (m ReportModel) getSummaries(propertyID int64, filters Filters) ([]*ReportSummary, Metadata, error) {
+	query := `
+	WITH counts AS (
+		SELECT property_id, reason, count(*) AS reporters, count(user_id) AS user_reporters,
+			max(created_at) AS last_reported_at
+		FROM listing_reports
+		WHERE resolved_at IS NULL AND (property_id = $1 OR $1 = 0)
+		GROUP BY property_id, reason
+	)
+	SELECT count(*) OVER(), counts.property_id, properties.title, properties.status,
+		sum(counts.reporters)::integer, sum(counts.user_reporters)::integer, json_object_agg(counts.reason, counts.reporters), max(counts.last_reported_at)
+	FROM counts
+	INNER JOIN properties ON properties.id = counts.property_id
+	GROUP BY counts.property_id, properties.title, properties.status
+	ORDER BY sum(counts.reporters) DESC, max(counts.last_reported_at) DESC
+	LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, propertyID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	summaries := []*ReportSummary{}
+
+	for rows.Next() {
+		var summary ReportSummary
+		var reasons []byte
+
+		err := rows.Scan(
+			&totalRecords,
+			&summary.PropertyID,
+			&summary.Title,
+			&summary.Status,
+			&summary.Reporters,
+			&summary.UserReporters,
+			&reasons,
+			&summary.LastReportedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		err = json.Unmarshal(reasons, &summary.Reasons)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		summaries = append(summaries, &summary)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return summaries, metadata, nil
+}
@@ -0,0 +1,344 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Saved search alert frequencies.
+const (
+	FrequencyInstant = "instant"
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+)
+
+// SavedSearch contains the listing query parameters a user has saved under a name.
+type SavedSearch struct {
+	ID        int64           `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	UserID    int64           `json:"-"`
+	Name      string          `json:"name"`
+	Query     string          `json:"query"`
+	Filters   PropertyFilters `json:"filters"`
+	Frequency string          `json:"frequency"`
+	Version   int32           `json:"version"`
+}
+
+func (f PropertyFilters) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+func (f *PropertyFilters) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, &f)
+}
+
+// ValidateSavedSearch validates a saved search based on set validation criteria.
+func ValidateSavedSearch(v *validator.Validator, savedSearch *SavedSearch) {
+	v.Check(savedSearch.Name != "", "name", "must be provided")
+	v.Check(len(savedSearch.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(len(savedSearch.Query) <= 2048, "query", "must not be more than 2048 bytes long")
+	v.Check(validator.In(savedSearch.Frequency, FrequencyInstant, FrequencyDaily, FrequencyWeekly), "frequency", "must be instant, daily or weekly")
+	ValidatePropertyFilters(v, savedSearch.Filters)
+}
+
+// SavedSearchDigest contains the properties matched by a saved search that its owner has not yet been notified about.
+type SavedSearchDigest struct {
+	SavedSearchID int64
+	SearchName    string
+	Name          string
+	Email         string
+	Properties    []*Property
+}
+
+// SavedSearchModel struct wraps a sql.DB connection pool.
+type SavedSearchModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a new record into the saved_searches table.
+func (s SavedSearchModel) Insert(savedSearch *SavedSearch) error {
+	query := `
+	INSERT INTO saved_searches (user_id, name, query, filters, frequency)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, version`
+
+	args := []interface{}{savedSearch.UserID, savedSearch.Name, savedSearch.Query, savedSearch.Filters, savedSearch.Frequency}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return s.DB.QueryRowContext(ctx, query, args...).Scan(&savedSearch.ID, &savedSearch.CreatedAt, &savedSearch.Version)
+}
+
+// GetAllForUser returns the saved searches belonging to a user.
+func (s SavedSearchModel) GetAllForUser(userID int64) ([]*SavedSearch, error) {
+	query := `
+	SELECT id, created_at, user_id, name, query, filters, frequency, version
+	FROM saved_searches
+	WHERE user_id = $1
+	ORDER BY id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	savedSearches := []*SavedSearch{}
+
+	for rows.Next() {
+		var savedSearch SavedSearch
+		err := rows.Scan(
+			&savedSearch.ID,
+			&savedSearch.CreatedAt,
+			&savedSearch.UserID,
+			&savedSearch.Name,
+			&savedSearch.Query,
+			&savedSearch.Filters,
+			&savedSearch.Frequency,
+			&savedSearch.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		savedSearches = append(savedSearches, &savedSearch)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return savedSearches, nil
+}
+
+// Delete deletes a specific saved search belonging to a user.
+func (s SavedSearchModel) Delete(id, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM saved_searches
+	WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// MatchNew records the published properties created or updated since each saved search was last
+// evaluated, up to the cutoff time, as pending matches. It returns the number of new matches.
+func (s SavedSearchModel) MatchNew(cutoff time.Time) (int64, error) {
+	query := `
+	SELECT id, filters, last_matched_at
+	FROM saved_searches
+	WHERE last_matched_at < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	type pendingSearch struct {
+		id            int64
+		filters       PropertyFilters
+		lastMatchedAt time.Time
+	}
+
+	var pending []pendingSearch
+
+	for rows.Next() {
+		var search pendingSearch
+		err := rows.Scan(&search.id, &search.filters, &search.lastMatchedAt)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, search)
+	}
+
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var matched int64
+
+	for _, search := range pending {
+		n, err := s.match(search.id, search.filters, search.lastMatchedAt, cutoff)
+		if err != nil {
+			return matched, err
+		}
+		matched += n
+	}
+
+	return matched, nil
+}
+
+// match records the published properties matching a saved search's filters that were updated in the
+// (since, cutoff] window, and moves the saved search's last_matched_at forward to the cutoff.
+func (s SavedSearchModel) match(savedSearchID int64, filters PropertyFilters, since, cutoff time.Time) (int64, error) {
+	filters.Status = StatusPublished
+
+	query := fmt.Sprintf(`
+	INSERT INTO saved_search_matches (saved_search_id, property_id)
+	SELECT $%d, id
+	FROM properties
+	WHERE %s
+	AND updated_at > $%d AND updated_at <= $%d
+	ON CONFLICT DO NOTHING`, propertyFilterArgs+1, propertyFilterClause, propertyFilterArgs+2, propertyFilterArgs+3)
+
+	args := append(filters.args(), savedSearchID, since, cutoff)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE saved_searches SET last_matched_at = $1 WHERE id = $2`, cutoff, savedSearchID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// GetDueDigests returns the pending matches of every saved search whose alert is due: instant alerts
+// are always due, while daily and weekly digests are due once a day or a week has passed since the last one.
+func (s SavedSearchModel) GetDueDigests(now time.Time) ([]*SavedSearchDigest, error) {
+	query := `
+	SELECT saved_searches.id, saved_searches.name, users.name, users.email, properties.id, properties.title, properties.city, properties.location, properties.price, properties.currency
+	FROM saved_searches
+	INNER JOIN users ON users.id = saved_searches.user_id
+	INNER JOIN saved_search_matches ON saved_search_matches.saved_search_id = saved_searches.id
+	INNER JOIN properties ON properties.id = saved_search_matches.property_id
+	WHERE saved_search_matches.notified_at IS NULL
+	AND properties.status = $2
+	AND (saved_searches.frequency = 'instant'
+		OR (saved_searches.frequency = 'daily' AND saved_searches.last_notified_at <= $1::timestamptz - INTERVAL '1 day')
+		OR (saved_searches.frequency = 'weekly' AND saved_searches.last_notified_at <= $1::timestamptz - INTERVAL '7 days'))
+	ORDER BY saved_searches.id, saved_search_matches.matched_at, properties.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, query, now, StatusPublished)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	digests := []*SavedSearchDigest{}
+
+	for rows.Next() {
+		var digest SavedSearchDigest
+		var property Property
+
+		err := rows.Scan(
+			&digest.SavedSearchID,
+			&digest.SearchName,
+			&digest.Name,
+			&digest.Email,
+			&property.ID,
+			&property.Title,
+			&property.City,
+			&property.Location,
+			&property.Price,
+			pq.Array(&property.Currency),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if n := len(digests); n > 0 && digests[n-1].SavedSearchID == digest.SavedSearchID {
+			digests[n-1].Properties = append(digests[n-1].Properties, &property)
+			continue
+		}
+
+		digest.Properties = []*Property{&property}
+		digests = append(digests, &digest)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return digests, nil
+}
+
+// MarkNotified records that the owner of a saved search has been notified about a set of matched properties.
+func (s SavedSearchModel) MarkNotified(savedSearchID int64, propertyIDs []int64, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE saved_search_matches
+	SET notified_at = $1
+	WHERE saved_search_id = $2 AND property_id = ANY($3)`
+
+	_, err = tx.ExecContext(ctx, query, now, savedSearchID, pq.Array(propertyIDs))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE saved_searches SET last_notified_at = $1 WHERE id = $2`, now, savedSearchID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,244 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// SimilarityWeights contains the relative weight of each signal used to score how similar two properties are.
+type SimilarityWeights struct {
+	Distance  float64
+	Type      float64
+	Category  float64
+	Price     float64
+	Features  float64
+	Amenities float64
+}
+
+// SimilarProperty contains a property recommended as comparable to another, with the reasons it was chosen.
+type SimilarProperty struct {
+	Property   *Property `json:"property"`
+	Score      float64   `json:"score"`
+	DistanceKm *float64  `json:"distance_km,omitempty"`
+	Reasons    []string  `json:"reasons"`
+}
+
+// similarFeatureKeys lists the numeric features compared between properties, along with the keys they may be stored under.
+var similarFeatureKeys = [][]string{{"bedrooms"}, {"bathrooms"}, SizeFeatureKeys}
+
+// Similarity scores how similar a candidate is to a target property. Each signal is scored between 0 and 1
+// and the score is their weighted average, so it also lies between 0 and 1. Properties further away than
+// radiusKm score 0 on distance, and prices more than 50% apart score 0 on price.
+func Similarity(target, candidate *Property, weights SimilarityWeights, radiusKm float64, rates ExchangeRates) *SimilarProperty {
+	similar := &SimilarProperty{Property: candidate, Reasons: []string{}}
+
+	var score, totalWeight float64
+	add := func(weight, value float64) {
+		score += weight * value
+		totalWeight += weight
+	}
+
+	// Distance
+	if distance, ok := target.DistanceTo(candidate); ok {
+		similar.DistanceKm = &distance
+		add(weights.Distance, math.Max(0, 1-distance/radiusKm))
+		if distance <= radiusKm {
+			similar.Reasons = append(similar.Reasons, fmt.Sprintf("%.1f km away", distance))
+		}
+	} else {
+		add(weights.Distance, 0)
+	}
+
+	// Type and category
+	if value, ok := sharedValue(target.Type, candidate.Type); ok {
+		add(weights.Type, 1)
+		similar.Reasons = append(similar.Reasons, fmt.Sprintf("same type (%s)", value))
+	} else {
+		add(weights.Type, 0)
+	}
+
+	if value, ok := sharedValue(target.Category, candidate.Category); ok {
+		add(weights.Category, 1)
+		similar.Reasons = append(similar.Reasons, fmt.Sprintf("same category (%s)", value))
+	} else {
+		add(weights.Category, 0)
+	}
+
+	// Price band
+	if candidatePrice, ok := candidatePriceIn(target, candidate, rates); ok && target.Price > 0 {
+		difference := math.Abs(candidatePrice-target.Price) / target.Price
+		add(weights.Price, math.Max(0, 1-difference/0.5))
+		if difference <= 0.2 {
+			similar.Reasons = append(similar.Reasons, fmt.Sprintf("price within %.0f%%", math.Ceil(difference*100)))
+		}
+	} else {
+		add(weights.Price, 0)
+	}
+
+	// Feature overlap
+	var featureScore float64
+	var featureCount int
+	for _, keys := range similarFeatureKeys {
+		a, aOK := target.Features.Float(keys...)
+		b, bOK := candidate.Features.Float(keys...)
+		if !aOK || !bOK || math.Max(a, b) <= 0 {
+			continue
+		}
+		featureCount++
+		featureScore += 1 - math.Abs(a-b)/math.Max(a, b)
+		switch {
+		case a == b:
+			similar.Reasons = append(similar.Reasons, fmt.Sprintf("same %s (%g)", keys[0], b))
+		case math.Abs(a-b)/math.Max(a, b) <= 0.15:
+			similar.Reasons = append(similar.Reasons, fmt.Sprintf("similar %s (%g)", keys[0], b))
+		}
+	}
+	if featureCount > 0 {
+		add(weights.Features, featureScore/float64(featureCount))
+	} else {
+		add(weights.Features, 0)
+	}
+
+	// Amenity Jaccard similarity
+	if shared, union := jaccard(target.Amenities, candidate.Amenities); union > 0 {
+		add(weights.Amenities, float64(shared)/float64(union))
+		if shared > 0 {
+			similar.Reasons = append(similar.Reasons, fmt.Sprintf("shares %d of %d amenities", shared, union))
+		}
+	} else {
+		add(weights.Amenities, 0)
+	}
+
+	if totalWeight > 0 {
+		similar.Score = math.Round(score/totalWeight*1000) / 1000
+	}
+
+	return similar
+}
+
+// sharedValue returns the first value present in both slices.
+func sharedValue(a, b []string) (string, bool) {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return x, true
+			}
+		}
+	}
+	return "", false
+}
+
+// jaccard returns the sizes of the intersection and the union of two sets of values.
+func jaccard(a, b []string) (intersection, union int) {
+	setA := make(map[string]bool, len(a))
+	for _, value := range a {
+		setA[value] = true
+	}
+
+	setB := make(map[string]bool, len(b))
+	for _, value := range b {
+		setB[value] = true
+	}
+
+	for value := range setA {
+		if setB[value] {
+			intersection++
+		}
+	}
+
+	return intersection, len(setA) + len(setB) - intersection
+}
+
+// candidatePriceIn returns the price of the candidate converted to the currency of the target property.
+func candidatePriceIn(target, candidate *Property, rates ExchangeRates) (float64, bool) {
+	if len(target.Currency) == 0 {
+		return 0, false
+	}
+	return candidate.PriceIn(target.Currency[0], rates)
+}
+
+// GetSimilar returns up to limit published properties ranked by their similarity to a property. Candidates
+// are the published properties in the same city or within radiusKm of the property, of which the nearest
+// 500 are ranked.
+func (p PropertyModel) GetSimilar(target *Property, weights SimilarityWeights, radiusKm float64, rates ExchangeRates, limit int) ([]*SimilarProperty, error) {
+	// Approximate the radius with a bounding box, which the precise distance score then refines. Candidates
+	// are ordered by their squared distance on a plane scaled to the latitude of the property, which is
+	// enough to find the nearest.
+	lonScale := math.Max(math.Cos(target.Latitude*math.Pi/180), 0.01)
+	latDelta := radiusKm / 111.0
+	lonDelta := radiusKm / (111.0 * lonScale)
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM properties
+	WHERE status = $1
+	AND id <> $2
+	AND (lower(city) = lower($3)
+		OR ($4 AND latitude BETWEEN $5 AND $6 AND longitude BETWEEN $7 AND $8))
+	ORDER BY CASE WHEN $4 AND (latitude <> 0 OR longitude <> 0)
+		THEN power(latitude - $9::float8, 2) + power((longitude - $10::float8) * $11, 2) END ASC NULLS LAST,
+		updated_at DESC
+	LIMIT 500`, propertyColumns)
+
+	args := []interface{}{
+		StatusPublished,
+		target.ID,
+		target.City,
+		target.HasCoordinates(),
+		target.Latitude - latDelta,
+		target.Latitude + latDelta,
+		target.Longitude - lonDelta,
+		target.Longitude + lonDelta,
+		target.Latitude,
+		target.Longitude,
+		lonScale,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []*Property{}
+
+	for rows.Next() {
+		var property Property
+		err := rows.Scan(property.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, &property)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rankSimilar(target, candidates, weights, radiusKm, rates, limit), nil
+}
+
+// rankSimilar scores candidates by their similarity to a target property and returns up to limit of them,
+// most similar first. Candidates with equal scores keep their order.
+func rankSimilar(target *Property, candidates []*Property, weights SimilarityWeights, radiusKm float64, rates ExchangeRates, limit int) []*SimilarProperty {
+	similar := make([]*SimilarProperty, 0, len(candidates))
+	for _, candidate := range candidates {
+		similar = append(similar, Similarity(target, candidate, weights, radiusKm, rates))
+	}
+
+	sort.SliceStable(similar, func(i, j int) bool {
+		return similar[i].Score > similar[j].Score
+	})
+
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+
+	return similar
+}
@@ -0,0 +1,140 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRankSimilar(t *testing.T) {
+	weights := SimilarityWeights{Distance: 1, Type: 1, Category: 1, Price: 1, Features: 1, Amenities: 1}
+	rates := ExchangeRates{"GBP": 1.27, "USD": 1}
+
+	target := &Property{
+		ID:        100,
+		Latitude:  51.5,
+		Longitude: -0.12,
+		Type:      []string{"flat"},
+		Category:  []string{"sale"},
+		Price:     500000,
+		Currency:  []string{"GBP"},
+		Features:  Features{"bedrooms": 2.0, "size": 80.0},
+		Amenities: []string{"parking", "garden"},
+	}
+
+	identical := &Property{
+		ID:        1,
+		Latitude:  51.5,
+		Longitude: -0.12,
+		Type:      []string{"flat"},
+		Category:  []string{"sale"},
+		Price:     500000,
+		Currency:  []string{"GBP"},
+		Features:  Features{"bedrooms": 2.0, "size": 80.0},
+		Amenities: []string{"garden", "parking"},
+	}
+	nearby := &Property{
+		ID:        2,
+		Latitude:  51.51,
+		Longitude: -0.12,
+		Type:      []string{"flat"},
+		Category:  []string{"rent"},
+		Price:     550000,
+		Currency:  []string{"GBP"},
+		Features:  Features{"bedrooms": 2.0, "size": 90.0},
+		Amenities: []string{"parking"},
+	}
+	elsewhere := &Property{
+		ID:       3,
+		Type:     []string{"house"},
+		Category: []string{"sale"},
+		Price:    635000,
+		Currency: []string{"USD"},
+	}
+	unrelated := &Property{
+		ID:        4,
+		Latitude:  52.5,
+		Longitude: -1.9,
+		Type:      []string{"flat"},
+		Category:  []string{"rent"},
+		Price:     100000,
+		Currency:  []string{"EUR"},
+	}
+
+	candidates := []*Property{elsewhere, identical, unrelated, nearby}
+
+	tests := []struct {
+		name    string
+		limit   int
+		wantIDs []int64
+	}{
+		{name: "All candidates", limit: 10, wantIDs: []int64{1, 2, 3, 4}},
+		{name: "Limited", limit: 2, wantIDs: []int64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			similar := rankSimilar(target, candidates, weights, 5, rates, tt.limit)
+
+			var ids []int64
+			for _, s := range similar {
+				ids = append(ids, s.Property.ID)
+				if s.Score < 0 || s.Score > 1 {
+					t.Errorf("property %d: got score %v; want between 0 and 1", s.Property.ID, s.Score)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("got order %v; want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	weights := SimilarityWeights{Distance: 1, Type: 1, Category: 1, Price: 1, Features: 1, Amenities: 1}
+	rates := ExchangeRates{"GBP": 1.27, "USD": 1}
+
+	target := &Property{
+		Type:     []string{"flat"},
+		Category: []string{"sale"},
+		Price:    500000,
+		Currency: []string{"GBP"},
+	}
+
+	tests := []struct {
+		name        string
+		candidate   *Property
+		wantScore   float64
+		wantReasons []string
+	}{
+		{
+			name:        "Same type, category and converted price",
+			candidate:   &Property{Type: []string{"flat"}, Category: []string{"sale"}, Price: 635000, Currency: []string{"USD"}},
+			wantScore:   0.5,
+			wantReasons: []string{"same type (flat)", "same category (sale)", "price within 0%"},
+		},
+		{
+			name:        "Price more than 50% apart",
+			candidate:   &Property{Type: []string{"house"}, Category: []string{"sale"}, Price: 800000, Currency: []string{"GBP"}},
+			wantScore:   0.167,
+			wantReasons: []string{"same category (sale)"},
+		},
+		{
+			name:        "Unsupported currency",
+			candidate:   &Property{Type: []string{"house"}, Price: 500000, Currency: []string{"EUR"}},
+			wantScore:   0,
+			wantReasons: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			similar := Similarity(target, tt.candidate, weights, 5, rates)
+			if similar.Score != tt.wantScore {
+				t.Errorf("got score %v; want %v", similar.Score, tt.wantScore)
+			}
+			if !reflect.DeepEqual(similar.Reasons, tt.wantReasons) {
+				t.Errorf("got reasons %q; want %q", similar.Reasons, tt.wantReasons)
+			}
+		})
+	}
+}
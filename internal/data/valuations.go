@@ -0,0 +1,245 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Adjustments applied to a comparable's price for each bedroom or bathroom it has more or less than the valued property.
+const (
+	bedroomAdjustment  = 0.05
+	bathroomAdjustment = 0.03
+)
+
+// activeListingDiscount discounts the asking price of active listings, which tend to sell below asking, relative to sold comparables.
+const activeListingDiscount = 0.05
+
+// Comparable contains a property used to value another, with its price adjusted for the differences between the two.
+type Comparable struct {
+	PropertyID    int64    `json:"property_id"`
+	Title         string   `json:"title"`
+	Status        string   `json:"status"`
+	Price         float64  `json:"price"`
+	AdjustedPrice float64  `json:"adjusted_price"`
+	DistanceKm    *float64 `json:"distance_km,omitempty"`
+	Weight        float64  `json:"weight"`
+}
+
+// Valuation contains an estimated price range for a property along with the comparables it was derived from.
+type Valuation struct {
+	Currency    string        `json:"currency"`
+	Estimate    float64       `json:"estimate"`
+	Low         float64       `json:"low"`
+	High        float64       `json:"high"`
+	Confidence  float64       `json:"confidence"`
+	Comparables []*Comparable `json:"comparables"`
+}
+
+// ValidateValuationSubject validates the attributes of a property to be valued.
+func ValidateValuationSubject(v *validator.Validator, subject *Property, rates ExchangeRates) {
+	v.Check(subject.City != "" || subject.HasCoordinates(), "city", "must be provided when latitude and longitude are not")
+	v.Check(subject.Latitude >= -90 && subject.Latitude <= 90, "latitude", "must be between -90 and 90")
+	v.Check(subject.Longitude >= -180 && subject.Longitude <= 180, "longitude", "must be between -180 and 180")
+	v.Check(len(subject.Type) <= 1, "type", "must not contain more than 1 type")
+	v.Check(len(subject.Category) <= 1, "category", "must not contain more than 1 category")
+	v.Check(len(subject.Currency) == 1, "currency", "must contain 1 currency")
+	if len(subject.Currency) == 1 {
+		v.Check(rates.Supports(subject.Currency[0]), "currency", "must be a supported currency")
+	}
+}
+
+// ValuationModel struct wraps a sql.DB connection pool.
+type ValuationModel struct {
+	DB *sql.DB
+}
+
+// Estimate values a property from the sold and active listings of the same type and category within radiusKm
+// of it, or in the same city when it has no coordinates. Each comparable's price is converted to the subject's
+// currency and adjusted for differences in size, bedrooms and bathrooms. The estimate is the weighted mean of
+// the adjusted prices, the range spans one weighted standard deviation either side, and the confidence grows
+// with the number of comparables and shrinks with their dispersion. It returns ErrRecordNotFound if there are
+// no comparables.
+func (m ValuationModel) Estimate(subject *Property, radiusKm float64, rates ExchangeRates) (*Valuation, error) {
+	comparables, err := m.comparables(subject, radiusKm)
+	if err != nil {
+		return nil, err
+	}
+
+	return estimate(subject, comparables, radiusKm, rates)
+}
+
+// estimate values a property from a list of comparable properties, as described by Estimate.
+func estimate(subject *Property, comparables []*Property, radiusKm float64, rates ExchangeRates) (*Valuation, error) {
+	currency := subject.Currency[0]
+	subjectSize, subjectHasSize := subject.Features.Float(SizeFeatureKeys...)
+
+	valuation := &Valuation{Currency: currency, Comparables: []*Comparable{}}
+
+	for _, property := range comparables {
+		price, ok := property.PriceIn(currency, rates)
+		if !ok || price <= 0 {
+			continue
+		}
+
+		comparable := &Comparable{
+			PropertyID:    property.ID,
+			Title:         property.Title,
+			Status:        property.Status,
+			Price:         property.Price,
+			AdjustedPrice: price,
+			Weight:        1,
+		}
+
+		if distance, ok := subject.DistanceTo(property); ok {
+			if distance > radiusKm {
+				continue
+			}
+			comparable.DistanceKm = &distance
+			comparable.Weight /= 1 + distance
+		}
+
+		if property.Status != StatusSold {
+			comparable.AdjustedPrice *= 1 - activeListingDiscount
+			comparable.Weight *= 0.8
+		}
+
+		// Size adjustment, using the comparable's price per square metre.
+		if size, ok := property.Features.Float(SizeFeatureKeys...); ok && subjectHasSize && size > 0 {
+			comparable.AdjustedPrice += (subjectSize - size) * comparable.AdjustedPrice / size
+			comparable.Weight *= 1 - math.Min(math.Abs(subjectSize-size)/math.Max(subjectSize, size), 0.9)
+		}
+
+		// Feature adjustments for each bedroom and bathroom of difference.
+		factor := 1.0
+		for key, adjustment := range map[string]float64{"bedrooms": bedroomAdjustment, "bathrooms": bathroomAdjustment} {
+			a, aOK := subject.Features.Float(key)
+			b, bOK := property.Features.Float(key)
+			if aOK && bOK {
+				factor += (a - b) * adjustment
+			}
+		}
+		comparable.AdjustedPrice *= math.Max(factor, 0.5)
+
+		if comparable.AdjustedPrice <= 0 || comparable.Weight <= 0 {
+			continue
+		}
+
+		comparable.AdjustedPrice = math.Round(comparable.AdjustedPrice)
+		valuation.Comparables = append(valuation.Comparables, comparable)
+	}
+
+	if len(valuation.Comparables) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	sort.SliceStable(valuation.Comparables, func(i, j int) bool {
+		return valuation.Comparables[i].Weight > valuation.Comparables[j].Weight
+	})
+	if len(valuation.Comparables) > 20 {
+		valuation.Comparables = valuation.Comparables[:20]
+	}
+
+	var totalWeight, weightedSum float64
+	for _, comparable := range valuation.Comparables {
+		totalWeight += comparable.Weight
+		weightedSum += comparable.Weight * comparable.AdjustedPrice
+	}
+	mean := weightedSum / totalWeight
+
+	var variance float64
+	for _, comparable := range valuation.Comparables {
+		variance += comparable.Weight * math.Pow(comparable.AdjustedPrice-mean, 2)
+	}
+	stdDev := math.Sqrt(variance / totalWeight)
+
+	// A single comparable gives no measure of dispersion, so assume a wide range.
+	if len(valuation.Comparables) == 1 {
+		stdDev = mean * 0.2
+	}
+
+	coefficientOfVariation := stdDev / mean
+	coverage := math.Min(float64(len(valuation.Comparables))/10, 1)
+	consistency := math.Max(0, 1-coefficientOfVariation*2)
+
+	valuation.Estimate = math.Round(mean)
+	valuation.Low = math.Round(math.Max(mean-stdDev, 0))
+	valuation.High = math.Round(mean + stdDev)
+	valuation.Confidence = math.Round((0.5*coverage+0.5*consistency)*100) / 100
+
+	for _, comparable := range valuation.Comparables {
+		comparable.Weight = math.Round(comparable.Weight/totalWeight*1000) / 1000
+	}
+
+	return valuation, nil
+}
+
+// comparables returns up to 200 sold and published properties of the same type and category as the subject
+// that lie within a bounding box around it, nearest first, or in its city when it has no coordinates, most
+// recently updated first.
+func (m ValuationModel) comparables(subject *Property, radiusKm float64) ([]*Property, error) {
+	lonScale := math.Max(math.Cos(subject.Latitude*math.Pi/180), 0.01)
+	latDelta := radiusKm / 111.0
+	lonDelta := radiusKm / (111.0 * lonScale)
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM properties
+	WHERE status = ANY($1)
+	AND id <> $2
+	AND (type && $3 OR $3 = '{}')
+	AND (category && $4 OR $4 = '{}')
+	AND (($5 AND latitude BETWEEN $6 AND $7 AND longitude BETWEEN $8 AND $9)
+		OR (NOT $5 AND lower(city) = lower($10)))
+	ORDER BY CASE WHEN $5 THEN power(latitude - $11::float8, 2) + power((longitude - $12::float8) * $13, 2) END ASC,
+		updated_at DESC
+	LIMIT 200`, propertyColumns)
+
+	args := []interface{}{
+		pq.Array([]string{StatusSold, StatusPublished}),
+		subject.ID,
+		pq.Array(nonNil(subject.Type)),
+		pq.Array(nonNil(subject.Category)),
+		subject.HasCoordinates(),
+		subject.Latitude - latDelta,
+		subject.Latitude + latDelta,
+		subject.Longitude - lonDelta,
+		subject.Longitude + lonDelta,
+		subject.City,
+		subject.Latitude,
+		subject.Longitude,
+		lonScale,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := []*Property{}
+
+	for rows.Next() {
+		var property Property
+		err := rows.Scan(property.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, &property)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return properties, nil
+}
@@ -0,0 +1,108 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	rates := ExchangeRates{"GBP": 1.27, "USD": 1}
+
+	subject := &Property{
+		Latitude:  51.5,
+		Longitude: -0.12,
+		Type:      []string{"flat"},
+		Currency:  []string{"GBP"},
+		Features:  Features{"size": 100.0, "bedrooms": 2.0},
+	}
+
+	comparable := func(status string, price float64, currency string, features Features) *Property {
+		return &Property{
+			Latitude:  51.5,
+			Longitude: -0.12,
+			Status:    status,
+			Price:     price,
+			Currency:  []string{currency},
+			Features:  features,
+		}
+	}
+	same := Features{"size": 100.0, "bedrooms": 2.0}
+
+	tests := []struct {
+		name           string
+		comparables    []*Property
+		wantEstimate   float64
+		wantLow        float64
+		wantHigh       float64
+		wantConfidence float64
+		wantErr        error
+	}{
+		{
+			name:           "Single comparable",
+			comparables:    []*Property{comparable(StatusSold, 300000, "GBP", same)},
+			wantEstimate:   300000,
+			wantLow:        240000,
+			wantHigh:       360000,
+			wantConfidence: 0.35,
+		},
+		{
+			name:           "Converted currency",
+			comparables:    []*Property{comparable(StatusSold, 381000, "USD", same)},
+			wantEstimate:   300000,
+			wantLow:        240000,
+			wantHigh:       360000,
+			wantConfidence: 0.35,
+		},
+		{
+			name: "Range spans one standard deviation",
+			comparables: []*Property{
+				comparable(StatusSold, 200000, "GBP", same),
+				comparable(StatusSold, 400000, "GBP", same),
+			},
+			wantEstimate:   300000,
+			wantLow:        200000,
+			wantHigh:       400000,
+			wantConfidence: 0.27,
+		},
+		{
+			name:           "Active listing adjusted for size and bedrooms",
+			comparables:    []*Property{comparable(StatusPublished, 200000, "GBP", Features{"size": 50.0, "bedrooms": 1.0})},
+			wantEstimate:   399000, // 200000 * 0.95 * 100/50 * 1.05
+			wantLow:        319200,
+			wantHigh:       478800,
+			wantConfidence: 0.35,
+		},
+		{
+			name: "No usable comparables",
+			comparables: []*Property{
+				{Latitude: 52.5, Longitude: -1.9, Status: StatusSold, Price: 300000, Currency: []string{"GBP"}},
+				comparable(StatusSold, 300000, "EUR", same),
+			},
+			wantErr: ErrRecordNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valuation, err := estimate(subject, tt.comparables, 5, rates)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if valuation.Estimate != tt.wantEstimate {
+				t.Errorf("got estimate %v; want %v", valuation.Estimate, tt.wantEstimate)
+			}
+			if valuation.Low != tt.wantLow || valuation.High != tt.wantHigh {
+				t.Errorf("got range %v-%v; want %v-%v", valuation.Low, valuation.High, tt.wantLow, tt.wantHigh)
+			}
+			if valuation.Confidence != tt.wantConfidence {
+				t.Errorf("got confidence %v; want %v", valuation.Confidence, tt.wantConfidence)
+			}
+			if valuation.Currency != "GBP" {
+				t.Errorf("got currency %q; want %q", valuation.Currency, "GBP")
+			}
+		})
+	}
+}
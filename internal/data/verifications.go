@@ -0,0 +1,400 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// ErrDuplicateVerification is returned when an agent submits their licence while an earlier submission is
+// still awaiting review.
+var ErrDuplicateVerification = errors.New("duplicate verification")
+
+// Licence verification statuses.
+const (
+	VerificationPending  = "pending"
+	VerificationApproved = "approved"
+	VerificationRejected = "rejected"
+)
+
+// MaxVerificationDocuments is the number of supporting documents that can be submitted with a licence.
+const MaxVerificationDocuments = 5
+
+// LicenceVerification contains an agent's request to have their licence verified by an admin.
+type LicenceVerification struct {
+	ID             int64                   `json:"id"`
+	CreatedAt      time.Time               `json:"created_at"`
+	UserID         int64                   `json:"user_id"`
+	AgentName      string                  `json:"agent_name"`
+	LicenceNumber  string                  `json:"licence_number"`
+	Authority      string                  `json:"authority"`
+	Status         string                  `json:"status"`
+	ReviewerID     int64                   `json:"reviewer_id,omitempty"`
+	DecisionReason string                  `json:"decision_reason,omitempty"`
+	ReviewedAt     *time.Time              `json:"reviewed_at,omitempty"`
+	Version        int32                   `json:"version"`
+	Documents      []*VerificationDocument `json:"documents,omitempty"`
+}
+
+// VerificationDocument contains a supporting document submitted with a licence. The document itself is kept
+// in private storage under StorageKey.
+type VerificationDocument struct {
+	ID             int64     `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	VerificationID int64     `json:"verification_id"`
+	Filename       string    `json:"filename"`
+	ContentType    string    `json:"content_type"`
+	Size           int64     `json:"size"`
+	StorageKey     string    `json:"-"`
+}
+
+// ValidateLicenceVerification validates a licence verification request and its documents based on set
+// validation criteria.
+func ValidateLicenceVerification(v *validator.Validator, verification *LicenceVerification) {
+	v.Check(verification.LicenceNumber != "", "licence_number", "must be provided")
+	v.Check(len(verification.LicenceNumber) <= 50, "licence_number", "must not be more than 50 bytes long")
+	v.Check(verification.Authority != "", "authority", "must be provided")
+	v.Check(len(verification.Authority) <= 200, "authority", "must not be more than 200 bytes long")
+	v.Check(len(verification.Documents) > 0, "files", "must contain at least one supporting document")
+	v.Check(len(verification.Documents) <= MaxVerificationDocuments, "files", fmt.Sprintf("must not contain more than %d documents", MaxVerificationDocuments))
+	for _, document := range verification.Documents {
+		v.Check(document.Filename != "", "files", "must have names")
+		v.Check(len(document.Filename) <= 255, "files", "must have names of at most 255 bytes")
+		v.Check(validator.In(document.ContentType, "application/pdf", "image/jpeg", "image/png"), "files", "must be PDF, JPEG or PNG files")
+	}
+}
+
+// ValidateVerificationQuery validates the status by which licence verifications are listed.
+func ValidateVerificationQuery(v *validator.Validator, status string) {
+	v.Check(validator.In(status, VerificationPending, VerificationApproved, VerificationRejected), "status", "must be pending, approved or rejected")
+}
+
+// ValidateVerificationDecision validates the reason given for a verification decision. A reason is required
+// when rejecting a licence.
+func ValidateVerificationDecision(v *validator.Validator, decision, reason string) {
+	v.Check(decision != VerificationRejected || reason != "", "reason", "must be provided")
+	v.Check(len(reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+}
+
+// VerificationModel struct wraps a sql.DB connection pool.
+type VerificationModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a licence verification request and its documents in one transaction, returning
+// ErrDuplicateVerification if the agent already has a request awaiting review.
+func (m VerificationModel) Insert(verification *LicenceVerification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO licence_verifications (user_id, licence_number, authority)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at, status, version`
+
+	args := []interface{}{verification.UserID, verification.LicenceNumber, verification.Authority}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&verification.ID, &verification.CreatedAt, &verification.Status, &verification.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "licence_verifications_pending_idx"`:
+			return ErrDuplicateVerification
+		default:
+			return err
+		}
+	}
+
+	query = `
+	INSERT INTO licence_verification_documents (verification_id, filename, content_type, size, storage_key)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at`
+
+	for _, document := range verification.Documents {
+		document.VerificationID = verification.ID
+		args := []interface{}{document.VerificationID, document.Filename, document.ContentType, document.Size, document.StorageKey}
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&document.ID, &document.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Get fetches a specific licence verification request.
+func (m VerificationModel) Get(id int64) (*LicenceVerification, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM licence_verifications
+	INNER JOIN users ON users.id = licence_verifications.user_id
+	WHERE licence_verifications.id = $1`, verificationColumns)
+
+	var verification LicenceVerification
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(verification.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &verification, nil
+}
+
+// GetAll returns a paginated list of the licence verification requests with a status, oldest first.
+func (m VerificationModel) GetAll(status string, filters Filters) ([]*LicenceVerification, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM licence_verifications
+	INNER JOIN users ON users.id = licence_verifications.user_id
+	WHERE licence_verifications.status = $1
+	ORDER BY licence_verifications.created_at ASC, licence_verifications.id ASC
+	LIMIT $2 OFFSET $3`, verificationColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	verifications := []*LicenceVerification{}
+
+	for rows.Next() {
+		var verification LicenceVerification
+		err := rows.Scan(append([]interface{}{&totalRecords}, verification.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		verifications = append(verifications, &verification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return verifications, metadata, nil
+}
+
+// GetAllForUser returns the licence verification requests of an agent, newest first.
+func (m VerificationModel) GetAllForUser(userID int64) ([]*LicenceVerification, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM licence_verifications
+	INNER JOIN users ON users.id = licence_verifications.user_id
+	WHERE licence_verifications.user_id = $1
+	ORDER BY licence_verifications.created_at DESC, licence_verifications.id DESC`, verificationColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	verifications := []*LicenceVerification{}
+
+	for rows.Next() {
+		var verification LicenceVerification
+		err := rows.Scan(verification.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		verifications = append(verifications, &verification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return verifications, nil
+}
+
+// Decide records an admin's decision on a licence verification request awaiting review, together with an
+// audit log entry, in one transaction. Approving a licence copies it to the agent's profile and marks them
+// as verified. ErrEditConflict is returned if the request has changed since it was fetched.
+func (m VerificationModel) Decide(verification *LicenceVerification, reviewerID int64, decision, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE licence_verifications
+	SET status = $1, reviewer_id = $2, decision_reason = $3, reviewed_at = NOW(), version = version + 1
+	WHERE id = $4 AND version = $5 AND status = 'pending'
+	RETURNING reviewed_at, version`
+
+	args := []interface{}{decision, reviewerID, reason, verification.ID, verification.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&verification.ReviewedAt, &verification.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	if decision == VerificationApproved {
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO agent_profiles (user_id, licence_number, licence_authority, verified_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET licence_number = EXCLUDED.licence_number, licence_authority = EXCLUDED.licence_authority,
+			verified_at = EXCLUDED.verified_at, updated_at = NOW(), version = agent_profiles.version + 1`,
+			verification.UserID, verification.LicenceNumber, verification.Authority)
+		if err != nil {
+			return err
+		}
+	}
+
+	entry := &AuditEntry{
+		ActorID:    reviewerID,
+		Action:     "licence_verification." + decision,
+		EntityType: "user",
+		EntityID:   verification.UserID,
+		Details: AuditDetails{
+			"verification_id": verification.ID,
+			"licence_number":  verification.LicenceNumber,
+			"authority":       verification.Authority,
+			"reason":          reason,
+		},
+	}
+
+	err = insertAuditEntry(ctx, tx, entry)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	verification.Status = decision
+	verification.ReviewerID = reviewerID
+	verification.DecisionReason = reason
+
+	return nil
+}
+
+// GetDocuments returns the documents submitted with a licence verification request.
+func (m VerificationModel) GetDocuments(verificationID int64) ([]*VerificationDocument, error) {
+	query := `
+	SELECT id, created_at, verification_id, filename, content_type, size, storage_key
+	FROM licence_verification_documents
+	WHERE verification_id = $1
+	ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, verificationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	documents := []*VerificationDocument{}
+
+	for rows.Next() {
+		var document VerificationDocument
+		err := rows.Scan(&document.ID, &document.CreatedAt, &document.VerificationID, &document.Filename, &document.ContentType, &document.Size, &document.StorageKey)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, &document)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// GetDocument fetches a specific document.
+func (m VerificationModel) GetDocument(id int64) (*VerificationDocument, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT id, created_at, verification_id, filename, content_type, size, storage_key
+	FROM licence_verification_documents
+	WHERE id = $1`
+
+	var document VerificationDocument
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&document.ID, &document.CreatedAt, &document.VerificationID, &document.Filename, &document.ContentType, &document.Size, &document.StorageKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &document, nil
+}
+
+// verificationColumns lists the licence verification columns in the order expected by
+// LicenceVerification.scanTargets.
+const verificationColumns = `licence_verifications.id, licence_verifications.created_at, licence_verifications.user_id, users.name,
+	licence_verifications.licence_number, licence_verifications.authority, licence_verifications.status,
+	coalesce(licence_verifications.reviewer_id, 0), licence_verifications.decision_reason, licence_verifications.reviewed_at,
+	licence_verifications.version`
+
+// scanTargets returns pointers to the licence verification fields in the order of verificationColumns.
+func (verification *LicenceVerification) scanTargets() []interface{} {
+	return []interface{}{
+		&verification.ID,
+		&verification.CreatedAt,
+		&verification.UserID,
+		&verification.AgentName,
+		&verification.LicenceNumber,
+		&verification.Authority,
+		&verification.Status,
+		&verification.ReviewerID,
+		&verification.DecisionReason,
+		&verification.ReviewedAt,
+		&verification.Version,
+	}
+}
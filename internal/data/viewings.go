@@ -0,0 +1,406 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/emzola/realty/internal/validator"
+)
+
+// ErrSlotUnavailable is returned when a viewing is booked in a slot that overlaps another confirmed viewing.
+var ErrSlotUnavailable = errors.New("slot unavailable")
+
+// Viewing statuses.
+const (
+	ViewingConfirmed = "confirmed"
+	ViewingCancelled = "cancelled"
+)
+
+// ViewingSlot contains a bookable viewing time. Times are expressed in the agent's time zone.
+type ViewingSlot struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	TimeZone string    `json:"time_zone"`
+}
+
+// Viewing contains an appointment for a buyer to view a property with its agent.
+type Viewing struct {
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PropertyID    int64      `json:"property_id"`
+	PropertyTitle string     `json:"property_title"`
+	AgentID       int64      `json:"agent_id"`
+	BuyerID       int64      `json:"buyer_id"`
+	StartsAt      time.Time  `json:"starts_at"`
+	EndsAt        time.Time  `json:"ends_at"`
+	TimeZone      string     `json:"time_zone"`
+	Status        string     `json:"status"`
+	Note          string     `json:"note,omitempty"`
+	CancelledAt   *time.Time `json:"cancelled_at,omitempty"`
+	CancelledBy   int64      `json:"cancelled_by,omitempty"`
+	Version       int32      `json:"version"`
+}
+
+// Local sets the viewing's times to its time zone, so that they are presented as the agent's local time.
+func (viewing *Viewing) Local() {
+	loc, err := time.LoadLocation(viewing.TimeZone)
+	if err != nil {
+		return
+	}
+	viewing.StartsAt = viewing.StartsAt.In(loc)
+	viewing.EndsAt = viewing.EndsAt.In(loc)
+}
+
+// When describes the time of the viewing in its time zone, for notifications.
+func (viewing *Viewing) When() string {
+	startsAt, endsAt := viewing.StartsAt, viewing.EndsAt
+	if loc, err := time.LoadLocation(viewing.TimeZone); err == nil {
+		startsAt, endsAt = startsAt.In(loc), endsAt.In(loc)
+	}
+	return fmt.Sprintf("%s-%s %s", startsAt.Format("Monday 2 January 2006, 15:04"), endsAt.Format("15:04"), startsAt.Format("MST"))
+}
+
+// HasParticipant reports whether a user is the buyer or the agent of the viewing.
+func (viewing *Viewing) HasParticipant(userID int64) bool {
+	return userID != 0 && (userID == viewing.BuyerID || userID == viewing.AgentID)
+}
+
+// ValidateViewing validates a viewing booking based on set validation criteria.
+func ValidateViewing(v *validator.Validator, viewing *Viewing) {
+	v.Check(!viewing.StartsAt.IsZero(), "starts_at", "must be provided")
+	v.Check(viewing.AgentID != 0, "property", "has no agent to view it with")
+	v.Check(viewing.AgentID != viewing.BuyerID, "property", "must not be your own listing")
+	v.Check(len(viewing.Note) <= 1000, "note", "must not be more than 1000 bytes long")
+}
+
+// ViewingSlots computes the slots in [from, to) that an agent's weekly rules make available, skipping
+// blacked out dates, slots starting before notBefore and slots overlapping booked viewings. Each rule's
+// times are wall-clock times in its time zone, so slots keep their local time across daylight saving
+// changes. Slots are ordered by start time.
+func ViewingSlots(rules []*AvailabilityRule, blackouts []*Blackout, booked []*Viewing, from, to, notBefore time.Time) ([]*ViewingSlot, error) {
+	blackedOut := make(map[string]bool, len(blackouts))
+	for _, blackout := range blackouts {
+		blackedOut[blackout.Date] = true
+	}
+
+	seen := make(map[int64]bool)
+	slots := []*ViewingSlot{}
+
+	for _, rule := range rules {
+		loc, err := time.LoadLocation(rule.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+
+		start, err := time.Parse("15:04", rule.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		end, err := time.Parse("15:04", rule.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		length := time.Duration(rule.SlotMinutes) * time.Minute
+
+		// Walk the local dates covering the window, including the day before in case the window starts late in the day.
+		first := from.In(loc).AddDate(0, 0, -1)
+		for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+			if int(day.Weekday()) != rule.Weekday || blackedOut[day.Format("2006-01-02")] {
+				continue
+			}
+
+			windowStart := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+			windowEnd := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+
+			for slotStart := windowStart; !slotStart.Add(length).After(windowEnd); slotStart = slotStart.Add(length) {
+				slotEnd := slotStart.Add(length)
+				if slotStart.Before(from) || !slotStart.Before(to) || slotStart.Before(notBefore) || seen[slotStart.Unix()] {
+					continue
+				}
+				if overlapsViewing(booked, slotStart, slotEnd) {
+					continue
+				}
+				seen[slotStart.Unix()] = true
+				slots = append(slots, &ViewingSlot{StartsAt: slotStart, EndsAt: slotEnd, TimeZone: rule.TimeZone})
+			}
+		}
+	}
+
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].StartsAt.Before(slots[j].StartsAt)
+	})
+
+	return slots, nil
+}
+
+// overlapsViewing reports whether any confirmed viewing overlaps the [start, end) interval.
+func overlapsViewing(viewings []*Viewing, start, end time.Time) bool {
+	for _, viewing := range viewings {
+		if viewing.Status == ViewingConfirmed && viewing.StartsAt.Before(end) && viewing.EndsAt.After(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// ViewingModel struct wraps a sql.DB connection pool.
+type ViewingModel struct {
+	DB *sql.DB
+}
+
+// Insert books a viewing. The agent's bookings are serialised with a transaction-level advisory lock, and
+// ErrSlotUnavailable is returned if the viewing overlaps another confirmed viewing with the same agent.
+func (m ViewingModel) Insert(viewing *Viewing) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The two-key form of the lock takes 32-bit keys, so the bigint agent ID is hashed.
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('viewings'), hashtext($1::text))`, viewing.AgentID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM viewings
+		WHERE agent_id = $1 AND status = 'confirmed'
+		AND starts_at < $3 AND ends_at > $2
+	)`
+
+	var overlaps bool
+
+	err = tx.QueryRowContext(ctx, query, viewing.AgentID, viewing.StartsAt, viewing.EndsAt).Scan(&overlaps)
+	if err != nil {
+		return err
+	}
+
+	if overlaps {
+		return ErrSlotUnavailable
+	}
+
+	query = `
+	INSERT INTO viewings (property_id, agent_id, buyer_id, starts_at, ends_at, time_zone, note)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at, status, version`
+
+	args := []interface{}{viewing.PropertyID, viewing.AgentID, viewing.BuyerID, viewing.StartsAt, viewing.EndsAt, viewing.TimeZone, viewing.Note}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&viewing.ID, &viewing.CreatedAt, &viewing.Status, &viewing.Version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Get fetches a specific viewing.
+func (m ViewingModel) Get(id int64) (*Viewing, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM viewings
+	INNER JOIN properties ON properties.id = viewings.property_id
+	WHERE viewings.id = $1`, viewingColumns)
+
+	var viewing Viewing
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(viewing.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	viewing.Local()
+
+	return &viewing, nil
+}
+
+// GetAllForUser returns a paginated list of the viewings a user takes part in as buyer or agent, soonest
+// first. When upcoming is true only viewings that have not ended are returned.
+func (m ViewingModel) GetAllForUser(userID int64, upcoming bool, filters Filters) ([]*Viewing, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM viewings
+	INNER JOIN properties ON properties.id = viewings.property_id
+	WHERE (viewings.buyer_id = $1 OR viewings.agent_id = $1)
+	AND (NOT $2 OR viewings.ends_at > NOW())
+	ORDER BY viewings.starts_at ASC, viewings.id ASC
+	LIMIT $3 OFFSET $4`, viewingColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, upcoming, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	viewings := []*Viewing{}
+
+	for rows.Next() {
+		var viewing Viewing
+		err := rows.Scan(append([]interface{}{&totalRecords}, viewing.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		viewing.Local()
+		viewings = append(viewings, &viewing)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return viewings, metadata, nil
+}
+
+// GetForCalendar returns the viewings a user takes part in as buyer or agent that ended after a point in time,
+// including cancelled viewings so that calendar clients can remove them.
+func (m ViewingModel) GetForCalendar(userID int64, since time.Time) ([]*Viewing, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM viewings
+	INNER JOIN properties ON properties.id = viewings.property_id
+	WHERE (viewings.buyer_id = $1 OR viewings.agent_id = $1)
+	AND viewings.ends_at > $2
+	ORDER BY viewings.starts_at
+	LIMIT 1000`, viewingColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	viewings := []*Viewing{}
+
+	for rows.Next() {
+		var viewing Viewing
+		err := rows.Scan(viewing.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		viewings = append(viewings, &viewing)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return viewings, nil
+}
+
+// GetBookedForAgent returns the confirmed viewings of an agent overlapping the [from, to) interval.
+func (m ViewingModel) GetBookedForAgent(agentID int64, from, to time.Time) ([]*Viewing, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM viewings
+	INNER JOIN properties ON properties.id = viewings.property_id
+	WHERE viewings.agent_id = $1 AND viewings.status = 'confirmed'
+	AND viewings.starts_at < $3 AND viewings.ends_at > $2
+	ORDER BY viewings.starts_at`, viewingColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, agentID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	viewings := []*Viewing{}
+
+	for rows.Next() {
+		var viewing Viewing
+		err := rows.Scan(viewing.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		viewings = append(viewings, &viewing)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return viewings, nil
+}
+
+// Cancel cancels a confirmed viewing on behalf of one of its participants.
+func (m ViewingModel) Cancel(viewing *Viewing, userID int64) error {
+	query := `
+	UPDATE viewings
+	SET status = 'cancelled', cancelled_at = NOW(), cancelled_by = $1, version = version + 1
+	WHERE id = $2 AND version = $3 AND status = 'confirmed'
+	RETURNING status, cancelled_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID, viewing.ID, viewing.Version).Scan(&viewing.Status, &viewing.CancelledAt, &viewing.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	viewing.CancelledBy = userID
+
+	return nil
+}
+
+// viewingColumns lists the viewings columns in the order expected by Viewing.scanTargets.
+const viewingColumns = `viewings.id, viewings.created_at, viewings.property_id, properties.title, viewings.agent_id,
+	viewings.buyer_id, viewings.starts_at, viewings.ends_at, viewings.time_zone, viewings.status, viewings.note,
+	viewings.cancelled_at, coalesce(viewings.cancelled_by, 0), viewings.version`
+
+// scanTargets returns pointers to the viewing fields in the order of viewingColumns.
+func (viewing *Viewing) scanTargets() []interface{} {
+	return []interface{}{
+		&viewing.ID,
+		&viewing.CreatedAt,
+		&viewing.PropertyID,
+		&viewing.PropertyTitle,
+		&viewing.AgentID,
+		&viewing.BuyerID,
+		&viewing.StartsAt,
+		&viewing.EndsAt,
+		&viewing.TimeZone,
+		&viewing.Status,
+		&viewing.Note,
+		&viewing.CancelledAt,
+		&viewing.CancelledBy,
+		&viewing.Version,
+	}
+}
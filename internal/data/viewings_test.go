@@ -0,0 +1,72 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestViewingSlots(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/London"); err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	// Clocks in London go forward an hour on Sunday 31 March 2024.
+	rules := []*AvailabilityRule{
+		{Weekday: int(time.Sunday), StartTime: "09:00", EndTime: "11:00", TimeZone: "Europe/London", SlotMinutes: 60},
+	}
+	from := time.Date(2024, 3, 24, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	utc := func(day, hour int) time.Time { return time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name      string
+		blackouts []*Blackout
+		booked    []*Viewing
+		notBefore time.Time
+		want      []time.Time
+	}{
+		{
+			name: "Daylight saving change",
+			want: []time.Time{utc(24, 9), utc(24, 10), utc(31, 8), utc(31, 9)},
+		},
+		{
+			name:      "Blackout",
+			blackouts: []*Blackout{{Date: "2024-03-24"}},
+			want:      []time.Time{utc(31, 8), utc(31, 9)},
+		},
+		{
+			name: "Booked",
+			booked: []*Viewing{
+				{StartsAt: utc(24, 9).Add(30 * time.Minute), EndsAt: utc(24, 10).Add(30 * time.Minute), Status: ViewingConfirmed},
+				{StartsAt: utc(31, 8), EndsAt: utc(31, 9), Status: ViewingCancelled},
+			},
+			want: []time.Time{utc(31, 8), utc(31, 9)},
+		},
+		{
+			name:      "Not before",
+			notBefore: utc(24, 10),
+			want:      []time.Time{utc(24, 10), utc(31, 8), utc(31, 9)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots, err := ViewingSlots(rules, tt.blackouts, tt.booked, from, to, tt.notBefore)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(slots) != len(tt.want) {
+				t.Fatalf("got %d slots; want %d", len(slots), len(tt.want))
+			}
+			for i, slot := range slots {
+				if !slot.StartsAt.Equal(tt.want[i]) {
+					t.Errorf("got slot starting at %v; want %v", slot.StartsAt.UTC(), tt.want[i])
+				}
+				if got := slot.EndsAt.Sub(slot.StartsAt); got != time.Hour {
+					t.Errorf("got slot length %v; want %v", got, time.Hour)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PropertyView records a visitor viewing the details of a property.
+type PropertyView struct {
+	PropertyID int64
+	Visitor    string
+	ViewedAt   time.Time
+}
+
+// DailyViews contains the views of a property on a day.
+type DailyViews struct {
+	Day            string `json:"day"`
+	Views          int    `json:"views"`
+	UniqueVisitors int    `json:"unique_visitors"`
+}
+
+// PropertyAnalytics contains the performance of a listing over a number of days.
+type PropertyAnalytics struct {
+	PropertyID     int64         `json:"property_id"`
+	Days           int           `json:"days"`
+	Views          int           `json:"views"`
+	UniqueVisitors int           `json:"unique_visitors"`
+	Favourites     int           `json:"favourites"`
+	Inquiries      int           `json:"inquiries"`
+	CityRank       int           `json:"city_rank"`
+	CityListings   int           `json:"city_listings"`
+	Daily          []*DailyViews `json:"daily"`
+}
+
+// ViewModel struct wraps a sql.DB connection pool.
+type ViewModel struct {
+	DB *sql.DB
+}
+
+// InsertBatch records a batch of views. Repeated views of a property by a visitor on the same day
+// are counted in a single row, which de-duplicates visitors.
+func (m ViewModel) InsertBatch(views []PropertyView) error {
+	type key struct {
+		propertyID int64
+		visitor    string
+		day        string
+	}
+
+	counts := make(map[key]int)
+	for _, view := range views {
+		counts[key{view.PropertyID, view.Visitor, view.ViewedAt.UTC().Format("2006-01-02")}]++
+	}
+
+	var propertyIDs []int64
+	var visitors, days []string
+	var totals []int64
+
+	for k, count := range counts {
+		propertyIDs = append(propertyIDs, k.propertyID)
+		visitors = append(visitors, k.visitor)
+		days = append(days, k.day)
+		totals = append(totals, int64(count))
+	}
+
+	// Views of properties deleted since they were recorded are skipped by the join.
+	query := `
+	INSERT INTO property_views (property_id, visitor, day, views)
+	SELECT batch.property_id, batch.visitor, batch.day, batch.views
+	FROM unnest($1::bigint[], $2::text[], $3::date[], $4::integer[]) AS batch(property_id, visitor, day, views)
+	INNER JOIN properties ON properties.id = batch.property_id
+	ON CONFLICT (property_id, day, visitor) DO UPDATE
+	SET views = property_views.views + EXCLUDED.views`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(propertyIDs), pq.Array(visitors), pq.Array(days), pq.Array(totals))
+	return err
+}
+
+// GetAnalytics returns the views, unique visitors, favourites, inquiries and city ranking of a property over the last
+// number of days. The rank orders the published properties of the same city by their views in that window.
+func (m ViewModel) GetAnalytics(property *Property, days int) (*PropertyAnalytics, error) {
+	analytics := &PropertyAnalytics{PropertyID: property.ID, Days: days, Daily: []*DailyViews{}}
+	since := time.Now().UTC().AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT to_char(series.day, 'YYYY-MM-DD'), coalesce(sum(property_views.views), 0), count(property_views.visitor)
+	FROM generate_series($2::date, (NOW() AT TIME ZONE 'UTC')::date, INTERVAL '1 day') AS series(day)
+	LEFT JOIN property_views ON property_views.property_id = $1 AND property_views.day = series.day::date
+	GROUP BY series.day
+	ORDER BY series.day`
+
+	rows, err := m.DB.QueryContext(ctx, query, property.ID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var daily DailyViews
+		err := rows.Scan(&daily.Day, &daily.Views, &daily.UniqueVisitors)
+		if err != nil {
+			return nil, err
+		}
+		analytics.Views += daily.Views
+		analytics.Daily = append(analytics.Daily, &daily)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+	SELECT
+		(SELECT count(DISTINCT visitor) FROM property_views WHERE property_id = $1 AND day >= $2::date),
+		(SELECT count(*) FROM favourites WHERE property_id = $1),
+		(SELECT count(*) FROM inquiries WHERE property_id = $1 AND NOT held AND created_at >= $2::date)`
+
+	err = m.DB.QueryRowContext(ctx, query, property.ID, since).Scan(&analytics.UniqueVisitors, &analytics.Favourites, &analytics.Inquiries)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+	WITH city_views AS (
+		SELECT properties.id, coalesce(sum(property_views.views), 0) AS views
+		FROM properties
+		LEFT JOIN property_views ON property_views.property_id = properties.id AND property_views.day >= $2::date
+		WHERE lower(properties.city) = lower($3)
+		AND (properties.status = $4 OR properties.id = $1)
+		GROUP BY properties.id
+	)
+	SELECT rank, total
+	FROM (
+		SELECT id, rank() OVER (ORDER BY views DESC) AS rank, count(*) OVER () AS total
+		FROM city_views
+	) ranked
+	WHERE id = $1`
+
+	err = m.DB.QueryRowContext(ctx, query, property.ID, since, property.City, StatusPublished).Scan(&analytics.CityRank, &analytics.CityListings)
+	if err != nil {
+		return nil, err
+	}
+
+	return analytics, nil
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event contains a notification published to a topic. IDs are assigned by the broker and increase
+// monotonically, so that a subscriber can resume after the last event it received.
+type Event struct {
+	ID        uint64      `json:"id"`
+	Topic     string      `json:"-"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// UserTopic returns the topic of the events addressed to a user.
+func UserTopic(userID int64) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// AuctionTopic returns the topic of the bid updates of an auction, which any user can subscribe to.
+func AuctionTopic(auctionID int64) string {
+	return fmt.Sprintf("auction:%d", auctionID)
+}
+
+// IsPublicTopic reports whether a topic can be subscribed to by any user, in addition to their own topic.
+func IsPublicTopic(topic string) bool {
+	if !strings.HasPrefix(topic, "auction:") {
+		return false
+	}
+	n, err := strconv.ParseInt(strings.TrimPrefix(topic, "auction:"), 10, 64)
+	return err == nil && n > 0 && AuctionTopic(n) == topic
+}
+
+// Broker fans events out to the subscribers of their topics. The in-process Hub delivers events to
+// subscribers of the same instance; a broker backed by PostgreSQL LISTEN/NOTIFY can implement the same
+// interface to fan events out across instances.
+type Broker interface {
+	Publish(topic, eventType string, data interface{}) error
+	Subscribe(lastEventID uint64, topics ...string) *Subscription
+}
+
+// Subscription receives the events published to a set of topics on C until it is closed.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	topics []string
+	once   sync.Once
+	close  func(*Subscription)
+}
+
+// Close stops delivery of events to the subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.close(s)
+	})
+}
@@ -0,0 +1,26 @@
+package events
+
+import "testing"
+
+func TestIsPublicTopic(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{topic: AuctionTopic(42), want: true},
+		{topic: UserTopic(42), want: false},
+		{topic: "auction:0", want: false},
+		{topic: "auction:-1", want: false},
+		{topic: "auction:042", want: false},
+		{topic: "auction:abc", want: false},
+		{topic: "auction:", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			if got := IsPublicTopic(tt.topic); got != tt.want {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Hub is an in-process Broker. It keeps a history of recent events so that subscribers reconnecting with
+// the ID of the last event they received do not miss the events published in between.
+type Hub struct {
+	mu          sync.Mutex
+	lastID      uint64
+	subscribers map[string]map[*Subscription]bool
+	history     []Event
+	historySize int
+	bufferSize  int
+}
+
+// NewHub returns a hub keeping the last historySize events and buffering up to bufferSize events for each
+// subscriber. Events published to a subscriber whose buffer is full are dropped.
+func NewHub(historySize, bufferSize int) *Hub {
+	return &Hub{
+		subscribers: make(map[string]map[*Subscription]bool),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+	}
+}
+
+// Publish delivers an event to the subscribers of a topic.
+func (h *Hub) Publish(topic, eventType string, data interface{}) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event := Event{ID: h.lastID, Topic: topic, Type: eventType, CreatedAt: time.Now(), Data: data}
+
+	if h.historySize > 0 {
+		if len(h.history) == h.historySize {
+			copy(h.history, h.history[1:])
+			h.history = h.history[:len(h.history)-1]
+		}
+		h.history = append(h.history, event)
+	}
+
+	for subscription := range h.subscribers[topic] {
+		select {
+		case subscription.ch <- event:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Subscribe returns a subscription to a set of topics. When lastEventID is not zero, the events in the
+// history published to the topics after that event are delivered first.
+func (h *Hub) Subscribe(lastEventID uint64, topics ...string) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay []Event
+	if lastEventID > 0 {
+		wanted := make(map[string]bool, len(topics))
+		for _, topic := range topics {
+			wanted[topic] = true
+		}
+		for _, event := range h.history {
+			if event.ID > lastEventID && wanted[event.Topic] {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	ch := make(chan Event, h.bufferSize+len(replay))
+	for _, event := range replay {
+		ch <- event
+	}
+
+	subscription := &Subscription{C: ch, ch: ch, topics: topics, close: h.unsubscribe}
+
+	for _, topic := range topics {
+		if h.subscribers[topic] == nil {
+			h.subscribers[topic] = make(map[*Subscription]bool)
+		}
+		h.subscribers[topic][subscription] = true
+	}
+
+	return subscription
+}
+
+// unsubscribe removes a subscription from the hub and closes its channel.
+func (h *Hub) unsubscribe(subscription *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, topic := range subscription.topics {
+		delete(h.subscribers[topic], subscription)
+		if len(h.subscribers[topic]) == 0 {
+			delete(h.subscribers, topic)
+		}
+	}
+
+	close(subscription.ch)
+}
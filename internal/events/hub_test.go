@@ -0,0 +1,143 @@
+package events
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestHubReplay(t *testing.T) {
+	hub := NewHub(3, 10)
+
+	hub.Publish("user:1", "a", nil) // 1, evicted from the history
+	hub.Publish("user:1", "b", nil) // 2
+	hub.Publish("user:2", "c", nil) // 3
+	hub.Publish("user:1", "d", nil) // 4
+
+	tests := []struct {
+		name        string
+		lastEventID uint64
+		topics      []string
+		wantIDs     []uint64
+	}{
+		{name: "No last event", lastEventID: 0, topics: []string{"user:1"}},
+		{name: "After an evicted event", lastEventID: 1, topics: []string{"user:1"}, wantIDs: []uint64{2, 4}},
+		{name: "After a recent event", lastEventID: 2, topics: []string{"user:1"}, wantIDs: []uint64{4}},
+		{name: "Several topics", lastEventID: 1, topics: []string{"user:1", "user:2"}, wantIDs: []uint64{2, 3, 4}},
+		{name: "Up to date", lastEventID: 4, topics: []string{"user:1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscription := hub.Subscribe(tt.lastEventID, tt.topics...)
+			defer subscription.Close()
+
+			if got := receiveIDs(subscription); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("got events %v; want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestHubPublish(t *testing.T) {
+	tests := []struct {
+		name       string
+		bufferSize int
+		topic      string
+		published  int
+		wantIDs    []uint64
+	}{
+		{name: "Within the buffer", bufferSize: 3, topic: "user:1", published: 2, wantIDs: []uint64{1, 2}},
+		{name: "Full buffer drops events", bufferSize: 2, topic: "user:1", published: 4, wantIDs: []uint64{1, 2}},
+		{name: "Other topic", bufferSize: 2, topic: "user:2", published: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub(0, tt.bufferSize)
+
+			subscription := hub.Subscribe(0, "user:1")
+			defer subscription.Close()
+
+			for i := 0; i < tt.published; i++ {
+				hub.Publish(tt.topic, "test", i)
+			}
+
+			if got := receiveIDs(subscription); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("got events %v; want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	hub := NewHub(10, 10)
+
+	subscription := hub.Subscribe(0, "user:1", "auction:1")
+	other := hub.Subscribe(0, "user:1")
+	defer other.Close()
+
+	subscription.Close()
+	subscription.Close()
+
+	if _, ok := <-subscription.C; ok {
+		t.Fatal("want the channel of a closed subscription to be closed")
+	}
+
+	hub.Publish("user:1", "test", nil)
+	hub.Publish("auction:1", "test", nil)
+
+	if got := receiveIDs(other); !reflect.DeepEqual(got, []uint64{1}) {
+		t.Errorf("got events %v for the remaining subscriber; want [1]", got)
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := hub.subscribers["auction:1"]; ok {
+		t.Error("want topics without subscribers to be removed")
+	}
+}
+
+func TestHubConcurrent(t *testing.T) {
+	hub := NewHub(50, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				hub.Publish("user:1", "test", j)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				subscription := hub.Subscribe(uint64(j), "user:1")
+				receiveIDs(subscription)
+				subscription.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if hub.lastID != 1000 {
+		t.Errorf("got last ID %d; want 1000", hub.lastID)
+	}
+}
+
+// receiveIDs returns the IDs of the events waiting on a subscription.
+func receiveIDs(subscription *Subscription) []uint64 {
+	var ids []uint64
+	for {
+		select {
+		case event, ok := <-subscription.C:
+			if !ok {
+				return ids
+			}
+			ids = append(ids, event.ID)
+		default:
+			return ids
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotCalendar is returned when decoding input that does not contain a VCALENDAR component.
+var ErrNotCalendar = errors.New("ical: not an iCalendar object")
+
+// maxLineLength is the length of the longest unfolded content line that is decoded.
+const maxLineLength = 1 << 20
+
+// Decode reads the first calendar from r. Only the properties held by Calendar and Event are decoded, and
+// components nested in events, such as alarms, are skipped. Recurrence rules are not expanded, so a
+// recurring event is decoded as its first occurrence.
+func Decode(r io.Reader) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		calendar *Calendar
+		event    *Event
+		depth    int // depth of the components nested in the current event
+		duration time.Duration
+	)
+
+	for i, line := range lines {
+		name, params, value, err := parseLine(line)
+		switch {
+		case err != nil && calendar == nil:
+			return nil, ErrNotCalendar
+		case err != nil:
+			return nil, fmt.Errorf("ical: line %d: %w", i+1, err)
+		}
+
+		switch {
+		case name == "BEGIN" && calendar == nil:
+			if value != "VCALENDAR" {
+				return nil, ErrNotCalendar
+			}
+			calendar = &Calendar{}
+		case calendar == nil:
+			return nil, ErrNotCalendar
+		case name == "BEGIN" && event != nil:
+			depth++
+		case name == "END" && event != nil && depth > 0:
+			depth--
+		case depth > 0:
+			// Skip the properties of components nested in the event.
+		case name == "BEGIN" && value == "VEVENT":
+			event = &Event{}
+			duration = 0
+		case name == "END" && value == "VEVENT" && event != nil:
+			if event.End.IsZero() {
+				event.End = endOf(event, duration)
+			}
+			calendar.Events = append(calendar.Events, event)
+			event = nil
+		case name == "END" && value == "VCALENDAR":
+			return calendar, nil
+		case event != nil:
+			err = event.set(name, params, value, &duration)
+			if err != nil {
+				return nil, fmt.Errorf("ical: line %d: %s: %w", i+1, name, err)
+			}
+		case name == "PRODID":
+			calendar.ProdID = value
+		case name == "METHOD":
+			calendar.Method = value
+		case name == "X-WR-CALNAME":
+			calendar.Name = unescape(value)
+		}
+	}
+
+	if calendar == nil {
+		return nil, ErrNotCalendar
+	}
+	return nil, errors.New("ical: unexpected end of calendar")
+}
+
+// set sets the event field held by a property.
+func (event *Event) set(name string, params map[string]string, value string, duration *time.Duration) error {
+	var err error
+
+	switch name {
+	case "UID":
+		event.UID = value
+	case "SEQUENCE":
+		event.Sequence, err = strconv.Atoi(value)
+	case "CREATED":
+		event.Created, _, err = parseTime(params, value)
+	case "LAST-MODIFIED":
+		event.LastModified, _, err = parseTime(params, value)
+	case "DTSTART":
+		event.Start, event.AllDay, err = parseTime(params, value)
+	case "DTEND":
+		event.End, _, err = parseTime(params, value)
+	case "DURATION":
+		*duration, err = parseDuration(value)
+	case "SUMMARY":
+		event.Summary = unescape(value)
+	case "DESCRIPTION":
+		event.Description = unescape(value)
+	case "LOCATION":
+		event.Location = unescape(value)
+	case "URL":
+		event.URL = value
+	case "STATUS":
+		event.Status = strings.ToUpper(value)
+	}
+
+	return err
+}
+
+// endOf returns the end of an event without a DTEND property: its duration after its start, or the end of
+// its start date for all-day events.
+func endOf(event *Event, duration time.Duration) time.Time {
+	switch {
+	case duration > 0:
+		return event.Start.Add(duration)
+	case event.AllDay:
+		return event.Start.AddDate(0, 0, 1)
+	default:
+		return event.Start
+	}
+}
+
+// unfold reads the content lines of r, joining folded lines.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			continue
+		case (line[0] == ' ' || line[0] == '\t') && len(lines) > 0:
+			lines[len(lines)-1] += line[1:]
+		default:
+			lines = append(lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ical: %w", err)
+	}
+
+	return lines, nil
+}
+
+// parseLine splits a content line into its upper-cased name, parameters and value. Parameter values may
+// be quoted, in which case they may contain the ':', ';' and ',' separators.
+func parseLine(line string) (string, map[string]string, string, error) {
+	var (
+		name   string
+		params map[string]string
+		quoted bool
+		start  int
+	)
+
+	key := ""
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '=' && name != "" && key == "":
+			key = strings.ToUpper(line[start:i])
+			start = i + 1
+		case c == ';' || c == ':':
+			if name == "" {
+				name = strings.ToUpper(line[:i])
+			} else if key != "" {
+				if params == nil {
+					params = make(map[string]string)
+				}
+				params[key] = strings.Trim(line[start:i], `"`)
+				key = ""
+			}
+			start = i + 1
+			if c == ':' {
+				if name == "" {
+					return "", nil, "", errors.New("missing property name")
+				}
+				return name, params, line[i+1:], nil
+			}
+		}
+	}
+
+	return "", nil, "", errors.New("missing property value")
+}
+
+// parseTime parses a DATE or DATE-TIME value, reporting whether it is a DATE. Dates are returned at midnight
+// UTC, UTC times in UTC, and local times in the location of their TZID parameter, or UTC when it is missing
+// or unknown.
+func parseTime(params map[string]string, value string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		t, err := time.Parse(dateLayout, value)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeLayout+"Z", value)
+		return t, false, err
+	}
+
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = l
+		}
+	}
+
+	t, err := time.ParseInLocation(dateTimeLayout, value, loc)
+	return t, false, err
+}
+
+// durationRX matches a DURATION value, such as P1D, PT2H30M or P2W.
+var durationRX = regexp.MustCompile(`^([+-]?)P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses a DURATION value.
+func parseDuration(value string) (time.Duration, error) {
+	m := durationRX.FindStringSubmatch(value)
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d += time.Duration(n) * unit
+	}
+
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// unescape unescapes a TEXT value.
+func unescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
@@ -0,0 +1,232 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// calendar wraps content lines in a VCALENDAR component with CRLF line endings.
+func calendar(lines ...string) string {
+	lines = append(append([]string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//Test//EN"}, lines...), "END:VCALENDAR")
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func TestDecode(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  Event
+	}{
+		{
+			name: "Folded lines",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"UID:folded@example.com",
+				"DTSTART:20240301T100000Z",
+				"DTEND:20240301T110000Z",
+				"SUMMARY:A summary that is long enough to be folded over",
+				"  more than one line\\, with escapes",
+				"\tand a tab",
+				"END:VEVENT",
+			),
+			want: Event{
+				UID:     "folded@example.com",
+				Start:   time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+				End:     time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+				Summary: "A summary that is long enough to be folded over more than one line, with escapesand a tab",
+			},
+		},
+		{
+			name: "TZID",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"UID:tzid@example.com",
+				`DTSTART;TZID="America/New_York":20240310T013000`,
+				"DTEND;TZID=America/New_York:20240310T033000",
+				"END:VEVENT",
+			),
+			want: Event{
+				UID:   "tzid@example.com",
+				Start: time.Date(2024, 3, 10, 1, 30, 0, 0, newYork),
+				End:   time.Date(2024, 3, 10, 3, 30, 0, 0, newYork),
+			},
+		},
+		{
+			name: "Unknown TZID",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"UID:unknown@example.com",
+				"DTSTART;TZID=Nowhere/Special:20240310T013000",
+				"DTEND;TZID=Nowhere/Special:20240310T033000",
+				"END:VEVENT",
+			),
+			want: Event{
+				UID:   "unknown@example.com",
+				Start: time.Date(2024, 3, 10, 1, 30, 0, 0, time.UTC),
+				End:   time.Date(2024, 3, 10, 3, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "VALUE=DATE",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"UID:date@example.com",
+				"DTSTART;VALUE=DATE:20240301",
+				"DTEND;VALUE=DATE:20240304",
+				"STATUS:cancelled",
+				"END:VEVENT",
+			),
+			want: Event{
+				UID:    "date@example.com",
+				Start:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				End:    time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+				Status: StatusCancelled,
+				AllDay: true,
+			},
+		},
+		{
+			name: "VALUE=DATE without DTEND",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"UID:day@example.com",
+				"DTSTART;VALUE=DATE:20240301",
+				"END:VEVENT",
+			),
+			want: Event{
+				UID:    "day@example.com",
+				Start:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				End:    time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+				AllDay: true,
+			},
+		},
+		{
+			name: "DURATION",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"UID:duration@example.com",
+				"DTSTART:20240301T100000Z",
+				"DURATION:P1DT2H30M",
+				"END:VEVENT",
+			),
+			want: Event{
+				UID:   "duration@example.com",
+				Start: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+				End:   time.Date(2024, 3, 2, 12, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "Nested alarm",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"UID:alarm@example.com",
+				"DTSTART:20240301T100000Z",
+				"DTEND:20240301T110000Z",
+				"BEGIN:VALARM",
+				"DESCRIPTION:Reminder",
+				"END:VALARM",
+				"END:VEVENT",
+			),
+			want: Event{
+				UID:   "alarm@example.com",
+				Start: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+				End:   time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal, err := Decode(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(cal.Events) != 1 {
+				t.Fatalf("got %d events; want 1", len(cal.Events))
+			}
+			event := cal.Events[0]
+
+			if event.UID != tt.want.UID {
+				t.Errorf("got UID %q; want %q", event.UID, tt.want.UID)
+			}
+			if !event.Start.Equal(tt.want.Start) {
+				t.Errorf("got start %v; want %v", event.Start, tt.want.Start)
+			}
+			if !event.End.Equal(tt.want.End) {
+				t.Errorf("got end %v; want %v", event.End, tt.want.End)
+			}
+			if event.Summary != tt.want.Summary {
+				t.Errorf("got summary %q; want %q", event.Summary, tt.want.Summary)
+			}
+			if event.Description != tt.want.Description {
+				t.Errorf("got description %q; want %q", event.Description, tt.want.Description)
+			}
+			if event.Status != tt.want.Status {
+				t.Errorf("got status %q; want %q", event.Status, tt.want.Status)
+			}
+			if event.AllDay != tt.want.AllDay {
+				t.Errorf("got all-day %t; want %t", event.AllDay, tt.want.AllDay)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{name: "Not a calendar", input: "<html></html>\r\n", wantErr: ErrNotCalendar},
+		{name: "Other component", input: "BEGIN:VCARD\r\nEND:VCARD\r\n", wantErr: ErrNotCalendar},
+		{name: "Empty", input: "", wantErr: ErrNotCalendar},
+		{name: "Truncated", input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:a\r\n"},
+		{name: "Invalid duration", input: calendar("BEGIN:VEVENT", "DURATION:PT", "END:VEVENT")},
+		{name: "Invalid date", input: calendar("BEGIN:VEVENT", "DTSTART;VALUE=DATE:2024-03-01", "END:VEVENT")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.input))
+			switch {
+			case err == nil:
+				t.Error("expected an error")
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("got error %v; want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"P2W", 14 * 24 * time.Hour},
+		{"P1D", 24 * time.Hour},
+		{"PT2H30M", 2*time.Hour + 30*time.Minute},
+		{"PT45S", 45 * time.Second},
+		{"-PT15M", -15 * time.Minute},
+		{"+P1DT1H", 25 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseDuration(tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
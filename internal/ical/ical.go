@@ -0,0 +1,166 @@
+// Package ical encodes and decodes calendars in the iCalendar format defined by RFC 5545.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event statuses.
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED"
+)
+
+// Calendar methods.
+const (
+	MethodPublish = "PUBLISH"
+	MethodCancel  = "CANCEL"
+)
+
+// Calendar contains a set of events.
+type Calendar struct {
+	ProdID string
+	Name   string
+	Method string
+	Events []*Event
+}
+
+// Event contains a VEVENT component. UID must stay the same across updates of an event, while Sequence
+// must increase with each update so that calendar clients replace their copy. The start and end of an
+// all-day event are dates, at midnight UTC, and its end is exclusive.
+type Event struct {
+	UID          string
+	Sequence     int
+	Created      time.Time
+	LastModified time.Time
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Location     string
+	URL          string
+	Status       string
+	AllDay       bool
+}
+
+// Encode writes the calendar to w. Times are written in UTC.
+func (c *Calendar) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	e := &encoder{w: bw}
+
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", c.ProdID)
+	e.line("CALSCALE", "GREGORIAN")
+	if c.Method != "" {
+		e.line("METHOD", c.Method)
+	}
+	if c.Name != "" {
+		e.line("X-WR-CALNAME", escape(c.Name))
+	}
+
+	stamp := time.Now()
+	for _, event := range c.Events {
+		e.event(event, stamp)
+	}
+
+	e.line("END", "VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+// String returns the encoded calendar.
+func (c *Calendar) String() string {
+	var sb strings.Builder
+	c.Encode(&sb)
+	return sb.String()
+}
+
+// encoder writes content lines, keeping the first error encountered.
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) event(event *Event, stamp time.Time) {
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", event.UID)
+	e.line("DTSTAMP", formatTime(stamp))
+	if event.AllDay {
+		e.line("DTSTART;VALUE=DATE", event.Start.Format(dateLayout))
+		e.line("DTEND;VALUE=DATE", event.End.Format(dateLayout))
+	} else {
+		e.line("DTSTART", formatTime(event.Start))
+		e.line("DTEND", formatTime(event.End))
+	}
+	e.line("SEQUENCE", fmt.Sprint(event.Sequence))
+	if !event.Created.IsZero() {
+		e.line("CREATED", formatTime(event.Created))
+	}
+	if !event.LastModified.IsZero() {
+		e.line("LAST-MODIFIED", formatTime(event.LastModified))
+	}
+	e.line("SUMMARY", escape(event.Summary))
+	if event.Description != "" {
+		e.line("DESCRIPTION", escape(event.Description))
+	}
+	if event.Location != "" {
+		e.line("LOCATION", escape(event.Location))
+	}
+	if event.URL != "" {
+		e.line("URL", event.URL)
+	}
+	if event.Status != "" {
+		e.line("STATUS", event.Status)
+	}
+	e.line("END", "VEVENT")
+}
+
+// line writes a content line, folding it so that no line is longer than 75 octets.
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+
+	line := name + ":" + value
+
+	var sb strings.Builder
+	width := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if width+size > 75 {
+			sb.WriteString("\r\n ")
+			width = 1
+		}
+		sb.WriteRune(r)
+		width += size
+	}
+	sb.WriteString("\r\n")
+
+	_, e.err = e.w.WriteString(sb.String())
+}
+
+// Layouts of DATE and DATE-TIME values.
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+)
+
+// formatTime formats a time as a UTC DATE-TIME value.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout + "Z")
+}
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
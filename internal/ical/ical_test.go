@@ -0,0 +1,78 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEncode(t *testing.T) {
+	event := &Event{
+		UID:         "viewing-1@example.com",
+		Sequence:    2,
+		Start:       time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		End:         time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+		Summary:     "Viewing; flat 2, Rue de l'Église",
+		Description: `Bring ID\nRing twice` + "\n" + strings.Repeat("Très long déscription ", 10),
+		Status:      StatusCancelled,
+	}
+	cal := &Calendar{ProdID: "-//Realty//Viewings//EN", Method: MethodCancel, Events: []*Event{event}}
+
+	encoded := cal.String()
+
+	t.Run("Folding", func(t *testing.T) {
+		if !strings.HasSuffix(encoded, "\r\n") {
+			t.Error("calendar does not end with CRLF")
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(encoded, "\r\n"), "\r\n") {
+			if len(line) > 75 {
+				t.Errorf("line is %d octets long: %q", len(line), line)
+			}
+			if !utf8.ValidString(line) {
+				t.Errorf("line splits a UTF-8 sequence: %q", line)
+			}
+		}
+	})
+
+	t.Run("Escaping", func(t *testing.T) {
+		unfolded := strings.ReplaceAll(encoded, "\r\n ", "")
+		for _, want := range []string{
+			`SUMMARY:Viewing\; flat 2\, Rue de l'Église`,
+			`DESCRIPTION:Bring ID\\nRing twice\nTrès long`,
+			"METHOD:CANCEL\r\n",
+			"STATUS:CANCELLED\r\n",
+		} {
+			if !strings.Contains(unfolded, want) {
+				t.Errorf("encoded calendar does not contain %q", want)
+			}
+		}
+	})
+
+	t.Run("Round trip", func(t *testing.T) {
+		decoded, err := Decode(strings.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if decoded.Method != MethodCancel {
+			t.Errorf("got method %q; want %q", decoded.Method, MethodCancel)
+		}
+		if len(decoded.Events) != 1 {
+			t.Fatalf("got %d events; want 1", len(decoded.Events))
+		}
+
+		got := decoded.Events[0]
+		if got.UID != event.UID || got.Sequence != event.Sequence || got.Status != event.Status {
+			t.Errorf("got UID %q, sequence %d and status %q; want %q, %d and %q", got.UID, got.Sequence, got.Status, event.UID, event.Sequence, event.Status)
+		}
+		if got.Summary != event.Summary {
+			t.Errorf("got summary %q; want %q", got.Summary, event.Summary)
+		}
+		if got.Description != event.Description {
+			t.Errorf("got description %q; want %q", got.Description, event.Description)
+		}
+		if !got.Start.Equal(event.Start) || !got.End.Equal(event.End) {
+			t.Errorf("got %v to %v; want %v to %v", got.Start, got.End, event.Start, event.End)
+		}
+	})
+}
@@ -0,0 +1,187 @@
+// Package icalsync fetches external iCalendar feeds and converts their events into blocks of nights of a
+// short-let property.
+package icalsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/ical"
+)
+
+// ErrPrivateAddress is returned when a feed URL resolves to a loopback, private or otherwise internal address.
+var ErrPrivateAddress = errors.New("icalsync: feed address is not public")
+
+// Result contains the outcome of fetching a feed. NotModified is true when the feed has not changed since the
+// validators held by the feed were received, in which case Blocks is nil.
+type Result struct {
+	NotModified  bool
+	Blocks       []*data.RentalBlock
+	ETag         string
+	LastModified string
+}
+
+// Fetcher fetches calendar feeds with an HTTP client, which can be replaced with a client of a local
+// server serving .ics fixtures.
+type Fetcher struct {
+	Client    *http.Client
+	MaxBytes  int64
+	UserAgent string
+}
+
+// NewClient returns an HTTP client for fetching feeds that gives up after a timeout. Unless allowPrivate is
+// true, the client refuses to connect to non-public addresses, so that feed URLs cannot be used to reach
+// internal services.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublic(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("icalsync: too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// isPublic reports whether an IP address is a public unicast address.
+func isPublic(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
+}
+
+// Fetch downloads a feed, sending the validators of its last response, and converts its events into blocks.
+func (f *Fetcher) Fetch(ctx context.Context, feed *data.CalendarFeed) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "text/calendar, */*;q=0.5")
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	if feed.ETag != "" {
+		req.Header.Set("If-None-Match", feed.ETag)
+	}
+	if feed.LastModified != "" {
+		req.Header.Set("If-Modified-Since", feed.LastModified)
+	}
+
+	res, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified:
+		return &Result{NotModified: true, ETag: feed.ETag, LastModified: feed.LastModified}, nil
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("icalsync: unexpected response status %s", res.Status)
+	}
+
+	body := io.Reader(res.Body)
+	if f.MaxBytes > 0 {
+		body = io.LimitReader(res.Body, f.MaxBytes+1)
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.MaxBytes > 0 && int64(len(content)) > f.MaxBytes {
+		return nil, fmt.Errorf("icalsync: feed is larger than %d bytes", f.MaxBytes)
+	}
+
+	calendar, err := ical.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Blocks:       Blocks(calendar.Events, time.Now()),
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// Blocks converts events into booked blocks, skipping cancelled events, events without a UID and events
+// that ended before now. A block covers the nights from the start date of its event up to its end date,
+// or at least the start night. Events sharing a UID are imported once.
+func Blocks(events []*ical.Event, now time.Time) []*data.RentalBlock {
+	blocks := []*data.RentalBlock{}
+	seen := make(map[string]bool)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	for _, event := range events {
+		if event.UID == "" || event.Status == ical.StatusCancelled || seen[event.UID] {
+			continue
+		}
+
+		start := date(event.Start)
+		end := date(event.End)
+		if !end.After(start) {
+			end = start.AddDate(0, 0, 1)
+		}
+
+		if !end.After(today) {
+			continue
+		}
+
+		seen[event.UID] = true
+		blocks = append(blocks, &data.RentalBlock{
+			StartDate: start.Format("2006-01-02"),
+			EndDate:   end.Format("2006-01-02"),
+			Kind:      data.BlockBooked,
+			Note:      truncate(event.Summary, 500),
+			UID:       event.UID,
+		})
+	}
+
+	return blocks
+}
+
+// date returns the date of a time in its own location, at midnight UTC.
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// truncate shortens a string to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
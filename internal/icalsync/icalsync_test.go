@@ -0,0 +1,162 @@
+package icalsync
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emzola/realty/internal/data"
+	"github.com/emzola/realty/internal/ical"
+)
+
+// newFeedServer returns a server serving the testdata fixture named by *fixture, with the fixture name as
+// its ETag.
+func newFeedServer(t *testing.T, fixture *string) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + *fixture + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Header().Set("ETag", etag)
+		http.ServeFile(w, r, filepath.Join("testdata", *fixture+".ics"))
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestFetch(t *testing.T) {
+	var fixture string
+	ts := newFeedServer(t, &fixture)
+
+	fetcher := &Fetcher{Client: NewClient(5*time.Second, true), MaxBytes: 1 << 20}
+	feed := &data.CalendarFeed{URL: ts.URL}
+
+	// Each step fetches the feed with the validators of the previous one, as a sync does.
+	steps := []struct {
+		name        string
+		fixture     string
+		notModified bool
+		wantBlocks  []data.RentalBlock
+	}{
+		{
+			name:    "Add",
+			fixture: "initial",
+			wantBlocks: []data.RentalBlock{
+				{UID: "stay-1@example.com", StartDate: "2099-07-01", EndDate: "2099-07-05"},
+				{UID: "stay-2@example.com", StartDate: "2099-07-10", EndDate: "2099-07-12"},
+			},
+		},
+		{
+			name:    "Update and cancel",
+			fixture: "updated",
+			wantBlocks: []data.RentalBlock{
+				{UID: "stay-1@example.com", StartDate: "2099-07-02", EndDate: "2099-07-06"},
+				{UID: "stay-3@example.com", StartDate: "2099-08-01", EndDate: "2099-08-08"},
+			},
+		},
+		{
+			name:    "Delete",
+			fixture: "deleted",
+			wantBlocks: []data.RentalBlock{
+				{UID: "stay-3@example.com", StartDate: "2099-08-01", EndDate: "2099-08-08"},
+			},
+		},
+		{
+			name:        "Not modified",
+			fixture:     "deleted",
+			notModified: true,
+		},
+	}
+
+	for _, step := range steps {
+		fixture = step.fixture
+
+		result, err := fetcher.Fetch(context.Background(), feed)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+
+		if result.NotModified != step.notModified {
+			t.Errorf("%s: got NotModified %t; want %t", step.name, result.NotModified, step.notModified)
+		}
+		if want := `"` + step.fixture + `"`; result.ETag != want {
+			t.Errorf("%s: got ETag %s; want %s", step.name, result.ETag, want)
+		}
+
+		if len(result.Blocks) != len(step.wantBlocks) {
+			t.Fatalf("%s: got %d blocks; want %d", step.name, len(result.Blocks), len(step.wantBlocks))
+		}
+		for i, block := range result.Blocks {
+			want := step.wantBlocks[i]
+			if block.UID != want.UID || block.StartDate != want.StartDate || block.EndDate != want.EndDate {
+				t.Errorf("%s: got block %s %s to %s; want %s %s to %s", step.name, block.UID, block.StartDate, block.EndDate, want.UID, want.StartDate, want.EndDate)
+			}
+			if block.Kind != data.BlockBooked {
+				t.Errorf("%s: got kind %q; want %q", step.name, block.Kind, data.BlockBooked)
+			}
+		}
+
+		feed.ETag, feed.LastModified = result.ETag, result.LastModified
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	fixture := "initial"
+	ts := newFeedServer(t, &fixture)
+
+	t.Run("Private address", func(t *testing.T) {
+		fetcher := &Fetcher{Client: NewClient(5*time.Second, false)}
+
+		_, err := fetcher.Fetch(context.Background(), &data.CalendarFeed{URL: ts.URL})
+		if !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("got error %v; want %v", err, ErrPrivateAddress)
+		}
+	})
+
+	t.Run("Too large", func(t *testing.T) {
+		fetcher := &Fetcher{Client: NewClient(5*time.Second, true), MaxBytes: 64}
+
+		_, err := fetcher.Fetch(context.Background(), &data.CalendarFeed{URL: ts.URL})
+		if err == nil {
+			t.Error("expected an error for a feed larger than MaxBytes")
+		}
+	})
+}
+
+func TestBlocks(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	events := []*ical.Event{
+		{UID: "past", Start: day(1), End: day(10)},
+		{UID: "current", Start: day(8), End: day(11)},
+		{UID: "current", Start: day(20), End: day(22)},
+		{UID: "", Start: day(12), End: day(14)},
+		{UID: "cancelled", Start: day(12), End: day(14), Status: ical.StatusCancelled},
+		{UID: "timed", Start: time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC), End: time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)},
+	}
+
+	want := []data.RentalBlock{
+		{UID: "current", StartDate: "2024-03-08", EndDate: "2024-03-11"},
+		{UID: "timed", StartDate: "2024-03-15", EndDate: "2024-03-16"},
+	}
+
+	blocks := Blocks(events, now)
+	if len(blocks) != len(want) {
+		t.Fatalf("got %d blocks; want %d", len(blocks), len(want))
+	}
+	for i, block := range blocks {
+		if block.UID != want[i].UID || block.StartDate != want[i].StartDate || block.EndDate != want[i].EndDate {
+			t.Errorf("got block %s %s to %s; want %s %s to %s", block.UID, block.StartDate, block.EndDate, want[i].UID, want[i].StartDate, want[i].EndDate)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"text/template"
+	"time"
+
+	htmltemplate "html/template"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+// Mailer sends templated emails. Each template file must define a "subject", a "plainBody" and an "htmlBody" template.
+type Mailer interface {
+	Send(recipient, templateFile string, data interface{}, attachments ...Attachment) error
+}
+
+// Attachment contains a file attached to an email.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// SMTP is a Mailer that delivers emails through an SMTP server, such as a local SMTP sink during development.
+type SMTP struct {
+	addr   string
+	auth   smtp.Auth
+	sender string
+	from   string
+}
+
+// NewSMTP returns an SMTP mailer. The sender may include a display name, such as
+// "Realty <no-reply@realty.com>". Authentication is skipped when no username is provided.
+func NewSMTP(host string, port int, username, password, sender string) (SMTP, error) {
+	address, err := mail.ParseAddress(sender)
+	if err != nil {
+		return SMTP{}, err
+	}
+
+	m := SMTP{
+		addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+		sender: sender,
+		from:   address.Address,
+	}
+
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m, nil
+}
+
+// Send renders a template file and delivers it to the recipient with any attachments, retrying up to three times.
+func (m SMTP) Send(recipient, templateFile string, data interface{}, attachments ...Attachment) error {
+	msg, err := m.render(recipient, templateFile, data, attachments)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= 3; i++ {
+		err = smtp.SendMail(m.addr, m.auth, m.from, []string{recipient}, msg)
+		if err == nil {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return err
+}
+
+// render executes a template file and builds a multipart/alternative message with plain text and HTML bodies.
+// When there are attachments, the message is wrapped in a multipart/mixed message along with them.
+func (m SMTP) render(recipient, templateFile string, data interface{}, attachments []Attachment) ([]byte, error) {
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlTmpl, err := htmltemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := new(bytes.Buffer)
+	mixed := multipart.NewWriter(msg)
+
+	fmt.Fprintf(msg, "From: %s\r\n", m.sender)
+	fmt.Fprintf(msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject.String()))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "MIME-Version: 1.0\r\n")
+
+	alternative := new(bytes.Buffer)
+	body := multipart.NewWriter(alternative)
+
+	parts := []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", plainBody.Bytes()},
+		{"text/html; charset=utf-8", htmlBody.Bytes()},
+	}
+
+	for _, part := range parts {
+		w, err := body.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		_, err = w.Write(part.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	alternativeType := fmt.Sprintf("multipart/alternative; boundary=%s", body.Boundary())
+
+	// Without attachments the alternative bodies make up the whole message.
+	if len(attachments) == 0 {
+		fmt.Fprintf(msg, "Content-Type: %s\r\n\r\n", alternativeType)
+		msg.Write(alternative.Bytes())
+		return msg.Bytes(), nil
+	}
+
+	fmt.Fprintf(msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mixed.Boundary())
+
+	w, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {alternativeType}})
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(alternative.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range attachments {
+		w, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := base64.NewEncoder(base64.StdEncoding, &lineWrapper{w: w})
+		_, err = encoder.Write(attachment.Content)
+		if err != nil {
+			return nil, err
+		}
+		err = encoder.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = mixed.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return msg.Bytes(), nil
+}
+
+// lineWrapper breaks base64 output into lines of 76 characters, as required by RFC 2045.
+type lineWrapper struct {
+	w      io.Writer
+	column int
+}
+
+func (lw *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := 76 - lw.column
+		if n > len(p) {
+			n = len(p)
+		}
+		_, err := lw.w.Write(p[:n])
+		if err != nil {
+			return written, err
+		}
+		written += n
+		lw.column += n
+		p = p[n:]
+		if lw.column == 76 {
+			_, err = lw.w.Write([]byte("\r\n"))
+			if err != nil {
+				return written, err
+			}
+			lw.column = 0
+		}
+	}
+	return written, nil
+}
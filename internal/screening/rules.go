@@ -0,0 +1,151 @@
+package screening
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//go:embed "disposable_domains.txt"
+var disposableDomainsFS embed.FS
+
+var nonWordRX = regexp.MustCompile(`[^\pL\pN]+`)
+
+var linkRX = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9-]+\.(?:com|net|org|info|biz|xyz|top|io|ru|cn)\b`)
+
+// LinkCount flags content containing more links than allowed, scoring each extra link.
+type LinkCount struct {
+	Max          int
+	ScorePerLink float64
+}
+
+// Check counts the links in the content.
+func (r LinkCount) Check(content Content) ([]Finding, error) {
+	links := len(linkRX.FindAllString(content.Text, -1))
+	if links <= r.Max {
+		return nil, nil
+	}
+
+	return []Finding{{
+		Rule:   "link_count",
+		Score:  float64(links-r.Max) * r.ScorePerLink,
+		Reason: fmt.Sprintf("contains %d links", links),
+	}}, nil
+}
+
+// BlockedWords flags content containing any of a list of blocked words or phrases.
+type BlockedWords struct {
+	Words []string
+	Score float64
+}
+
+// Check looks for each blocked word in the content, matching whole words regardless of case.
+func (r BlockedWords) Check(content Content) ([]Finding, error) {
+	text := " " + normalize(nonWordRX.ReplaceAllString(content.Text, " ")) + " "
+
+	var findings []Finding
+	for _, word := range r.Words {
+		word = normalize(word)
+		if word != "" && strings.Contains(text, " "+word+" ") {
+			findings = append(findings, Finding{
+				Rule:   "blocked_words",
+				Score:  r.Score,
+				Reason: fmt.Sprintf("contains blocked word %q", word),
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// SubmissionCounter counts the submissions of a kind made since a point in time by the same submitter
+// (the same email address or user) or with the same text.
+type SubmissionCounter interface {
+	CountRecentSubmissions(kind, email string, userID int64, text string, since time.Time) (int, error)
+}
+
+// RepeatedSubmissions flags content when the same submitter or text has been submitted more than Max times within Window.
+type RepeatedSubmissions struct {
+	Counter SubmissionCounter
+	Window  time.Duration
+	Max     int
+	Score   float64
+}
+
+// Check counts recent submissions similar to the content.
+func (r RepeatedSubmissions) Check(content Content) ([]Finding, error) {
+	count, err := r.Counter.CountRecentSubmissions(content.Kind, content.Email, content.UserID, content.Text, time.Now().Add(-r.Window))
+	if err != nil {
+		return nil, err
+	}
+
+	if count <= r.Max {
+		return nil, nil
+	}
+
+	return []Finding{{
+		Rule:   "repeated_submissions",
+		Score:  r.Score,
+		Reason: fmt.Sprintf("%d similar submissions in the last %s", count, r.Window),
+	}}, nil
+}
+
+// DisposableEmail flags content submitted from a disposable email domain.
+type DisposableEmail struct {
+	Domains map[string]bool
+	Score   float64
+}
+
+// Check looks up the domain of the submitter's email address.
+func (r DisposableEmail) Check(content Content) ([]Finding, error) {
+	at := strings.LastIndex(content.Email, "@")
+	if at < 0 {
+		return nil, nil
+	}
+
+	domain := strings.ToLower(content.Email[at+1:])
+	if !r.Domains[domain] {
+		return nil, nil
+	}
+
+	return []Finding{{
+		Rule:   "disposable_email",
+		Score:  r.Score,
+		Reason: fmt.Sprintf("uses disposable email domain %s", domain),
+	}}, nil
+}
+
+// LoadDisposableDomains reads a list of disposable email domains, one per line, from a file. Blank lines and
+// lines starting with # are ignored. When path is empty the list bundled with the application is used.
+func LoadDisposableDomains(path string) (map[string]bool, error) {
+	var r io.ReadCloser
+	var err error
+
+	if path == "" {
+		r, err = disposableDomainsFS.Open("disposable_domains.txt")
+	} else {
+		r, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	domains := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains[line] = true
+	}
+
+	return domains, scanner.Err()
+}
@@ -0,0 +1,184 @@
+package screening
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLinkCount(t *testing.T) {
+	rule := LinkCount{Max: 1, ScorePerLink: 0.5}
+
+	tests := []struct {
+		name      string
+		text      string
+		wantScore float64
+	}{
+		{name: "No links", text: "Bright two bedroom flat near the park"},
+		{name: "Within the limit", text: "Floor plan at https://example.com/plan.pdf"},
+		{name: "One extra link", text: "See www.example.com and https://example.net/photos", wantScore: 0.5},
+		{name: "Bare domains", text: "cheap-homes.xyz, best-deals.top and more.ru", wantScore: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := rule.Check(Content{Text: tt.text})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := totalScore(findings); got != tt.wantScore {
+				t.Errorf("got score %v; want %v", got, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestBlockedWords(t *testing.T) {
+	rule := BlockedWords{Words: []string{"wire transfer", "Guaranteed"}, Score: 1}
+
+	tests := []struct {
+		name         string
+		text         string
+		wantFindings int
+	}{
+		{name: "Clean", text: "Spacious family home with a garden"},
+		{name: "Phrase across punctuation", text: "Pay by WIRE\n-transfer only", wantFindings: 1},
+		{name: "Both words", text: "Guaranteed returns, wire transfer the deposit", wantFindings: 2},
+		{name: "Part of a word", text: "Unguaranteed parking nearby"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := rule.Check(Content{Text: tt.text})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(findings) != tt.wantFindings {
+				t.Errorf("got %d findings; want %d", len(findings), tt.wantFindings)
+			}
+		})
+	}
+}
+
+type stubCounter struct {
+	count int
+	err   error
+}
+
+func (c stubCounter) CountRecentSubmissions(kind, email string, userID int64, text string, since time.Time) (int, error) {
+	return c.count, c.err
+}
+
+func TestRepeatedSubmissions(t *testing.T) {
+	errCount := errors.New("count failed")
+
+	tests := []struct {
+		name      string
+		counter   stubCounter
+		wantScore float64
+		wantErr   error
+	}{
+		{name: "Below the limit", counter: stubCounter{count: 2}},
+		{name: "At the limit", counter: stubCounter{count: 3}},
+		{name: "Over the limit", counter: stubCounter{count: 4}, wantScore: 0.8},
+		{name: "Counter error", counter: stubCounter{err: errCount}, wantErr: errCount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := RepeatedSubmissions{Counter: tt.counter, Window: time.Hour, Max: 3, Score: 0.8}
+
+			findings, err := rule.Check(Content{Kind: KindInquiry, Text: "Is it still available?"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+			if got := totalScore(findings); got != tt.wantScore {
+				t.Errorf("got score %v; want %v", got, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestDisposableEmail(t *testing.T) {
+	rule := DisposableEmail{Domains: map[string]bool{"mailinator.com": true}, Score: 0.6}
+
+	tests := []struct {
+		name      string
+		email     string
+		wantScore float64
+	}{
+		{name: "No email", email: ""},
+		{name: "Regular domain", email: "alice@example.com"},
+		{name: "Disposable domain", email: "alice@mailinator.com", wantScore: 0.6},
+		{name: "Upper case domain", email: "alice@Mailinator.COM", wantScore: 0.6},
+		{name: "Subdomain", email: "alice@eu.mailinator.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := rule.Check(Content{Email: tt.email})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := totalScore(findings); got != tt.wantScore {
+				t.Errorf("got score %v; want %v", got, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestLoadDisposableDomains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	err := os.WriteFile(path, []byte("# Comment\n\n  Trash.example \nspam.example\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		wantDomains []string
+		wantMissing []string
+	}{
+		{
+			name:        "Bundled list",
+			wantDomains: []string{"10minutemail.com", "33mail.com"},
+			wantMissing: []string{"# disposable email domains flagged by the disposableemail rule."},
+		},
+		{
+			name:        "File",
+			path:        path,
+			wantDomains: []string{"trash.example", "spam.example"},
+			wantMissing: []string{"# comment", "", "10minutemail.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domains, err := LoadDisposableDomains(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, domain := range tt.wantDomains {
+				if !domains[domain] {
+					t.Errorf("want %q in the list", domain)
+				}
+			}
+			for _, domain := range tt.wantMissing {
+				if domains[domain] {
+					t.Errorf("want %q not in the list", domain)
+				}
+			}
+		})
+	}
+}
+
+func totalScore(findings []Finding) float64 {
+	var score float64
+	for _, finding := range findings {
+		score += finding.Score
+	}
+	return score
+}
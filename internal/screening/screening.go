@@ -0,0 +1,89 @@
+package screening
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Content kinds that can be screened.
+const (
+	KindProperty = "property"
+	KindInquiry  = "inquiry"
+)
+
+// Content contains a user submission to be screened.
+type Content struct {
+	Kind   string
+	Text   string
+	Email  string
+	UserID int64
+}
+
+// Finding is a reason a rule considers content suspicious, and how much it adds to the content's score.
+type Finding struct {
+	Rule   string  `json:"rule"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+// Result contains the findings of a screening, their total score, and whether the content should be held for moderation.
+type Result struct {
+	Score    float64   `json:"score"`
+	Findings []Finding `json:"findings"`
+	Held     bool      `json:"held"`
+}
+
+// Reasons returns the reasons of each finding.
+func (r Result) Reasons() []string {
+	reasons := make([]string, len(r.Findings))
+	for i, finding := range r.Findings {
+		reasons[i] = finding.Reason
+	}
+	return reasons
+}
+
+// Rule is a single check applied to content. A rule returns no findings when the content looks legitimate.
+type Rule interface {
+	Check(content Content) ([]Finding, error)
+}
+
+// Pipeline runs content through a series of rules and holds content whose total score reaches a threshold.
+type Pipeline struct {
+	threshold float64
+	rules     []Rule
+}
+
+// New returns a pipeline that holds content scoring at least threshold across the rules.
+func New(threshold float64, rules ...Rule) *Pipeline {
+	return &Pipeline{threshold: threshold, rules: rules}
+}
+
+// Use adds rules to the pipeline.
+func (p *Pipeline) Use(rules ...Rule) {
+	p.rules = append(p.rules, rules...)
+}
+
+// Screen runs content through every rule of the pipeline.
+func (p *Pipeline) Screen(content Content) (Result, error) {
+	result := Result{Findings: []Finding{}}
+
+	for _, rule := range p.rules {
+		findings, err := rule.Check(content)
+		if err != nil {
+			return Result{}, fmt.Errorf("screening: %w", err)
+		}
+		for _, finding := range findings {
+			result.Score += finding.Score
+			result.Findings = append(result.Findings, finding)
+		}
+	}
+
+	result.Held = result.Score >= p.threshold
+
+	return result, nil
+}
+
+// normalize lower-cases text and collapses whitespace, so that rules compare content consistently.
+func normalize(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
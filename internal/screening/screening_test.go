@@ -0,0 +1,81 @@
+package screening
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type ruleFunc func(content Content) ([]Finding, error)
+
+func (f ruleFunc) Check(content Content) ([]Finding, error) {
+	return f(content)
+}
+
+func TestPipelineScreen(t *testing.T) {
+	errRule := errors.New("rule failed")
+
+	flag := func(score float64, reason string) Rule {
+		return ruleFunc(func(Content) ([]Finding, error) {
+			return []Finding{{Rule: "test", Score: score, Reason: reason}}, nil
+		})
+	}
+	pass := ruleFunc(func(Content) ([]Finding, error) { return nil, nil })
+	fail := ruleFunc(func(Content) ([]Finding, error) { return nil, errRule })
+
+	tests := []struct {
+		name        string
+		rules       []Rule
+		wantScore   float64
+		wantReasons []string
+		wantHeld    bool
+		wantErr     error
+	}{
+		{name: "No findings", rules: []Rule{pass, pass}, wantReasons: []string{}},
+		{name: "Below the threshold", rules: []Rule{flag(0.5, "a"), pass}, wantScore: 0.5, wantReasons: []string{"a"}},
+		{name: "At the threshold", rules: []Rule{flag(0.5, "a"), flag(0.5, "b")}, wantScore: 1, wantReasons: []string{"a", "b"}, wantHeld: true},
+		{name: "Rule error", rules: []Rule{flag(2, "a"), fail}, wantErr: errRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := New(1)
+			pipeline.Use(tt.rules...)
+
+			result, err := pipeline.Screen(Content{Kind: KindProperty, Text: "Two bedroom flat"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if result.Score != tt.wantScore {
+				t.Errorf("got score %v; want %v", result.Score, tt.wantScore)
+			}
+			if result.Held != tt.wantHeld {
+				t.Errorf("got held %v; want %v", result.Held, tt.wantHeld)
+			}
+			if got := result.Reasons(); !reflect.DeepEqual(got, tt.wantReasons) {
+				t.Errorf("got reasons %q; want %q", got, tt.wantReasons)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "", want: ""},
+		{text: "  Wire\tTRANSFER \n now ", want: "wire transfer now"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := normalize(tt.text); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package storage stores private files, such as documents uploaded with rental applications, outside the database.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrNotFound is returned when opening or deleting a file that does not exist.
+var ErrNotFound = errors.New("storage: file not found")
+
+// ErrTooLarge is returned when a file is larger than the size it is allowed to be stored with.
+var ErrTooLarge = errors.New("storage: file too large")
+
+// Store is implemented by file stores. Keys are slash-separated paths of lower-case letters, digits,
+// dashes, underscores and dots.
+type Store interface {
+	// Put stores the content of r under key, failing with ErrTooLarge if it exceeds maxBytes, and returns
+	// the number of bytes stored.
+	Put(ctx context.Context, key string, r io.Reader, maxBytes int64) (int64, error)
+	// Open returns the content stored under key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key.
+	Delete(ctx context.Context, key string) error
+}
+
+// keyRX matches a valid key.
+var keyRX = regexp.MustCompile(`^[a-z0-9_-][a-z0-9._-]*(/[a-z0-9_-][a-z0-9._-]*)*$`)
+
+// NewKey returns a key under a prefix that cannot be guessed.
+func NewKey(prefix string) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + hex.EncodeToString(b), nil
+}
+
+// Disk stores files in a directory of the local file system.
+type Disk struct {
+	root string
+}
+
+// NewDisk returns a store of files in the root directory, which is created if it does not exist.
+func NewDisk(root string) (*Disk, error) {
+	err := os.MkdirAll(root, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	return &Disk{root: root}, nil
+}
+
+// path returns the path of the file stored under key.
+func (d *Disk) path(key string) (string, error) {
+	if !keyRX.MatchString(key) || strings.Contains(key, "..") {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return filepath.Join(d.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the content to a temporary file that is renamed once complete, so that a partial file is
+// never visible under key.
+func (d *Disk) Put(ctx context.Context, key string, r io.Reader, maxBytes int64) (int64, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	n, err := io.Copy(f, io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return 0, err
+	}
+
+	if n > maxBytes {
+		return 0, ErrTooLarge
+	}
+
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if err = f.Close(); err != nil {
+		return 0, err
+	}
+
+	return n, os.Rename(f.Name(), path)
+}
+
+// Open opens the file stored under key.
+func (d *Disk) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// Delete removes the file stored under key.
+func (d *Disk) Delete(ctx context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS properties_location_prefix_idx;
+DROP INDEX IF EXISTS properties_city_prefix_idx;
+DROP INDEX IF EXISTS properties_location_trgm_idx;
+DROP INDEX IF EXISTS properties_city_trgm_idx;
+DROP FUNCTION IF EXISTS immutable_unaccent(text);
@@ -0,0 +1,12 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- unaccent() is only STABLE, so wrap it in an IMMUTABLE function that can be used in index expressions.
+CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text AS $$
+    SELECT public.unaccent('public.unaccent', $1)
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+
+CREATE INDEX IF NOT EXISTS properties_city_trgm_idx ON properties USING GIN (immutable_unaccent(lower(city)) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS properties_location_trgm_idx ON properties USING GIN (immutable_unaccent(lower(location)) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS properties_city_prefix_idx ON properties (immutable_unaccent(lower(city)) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS properties_location_prefix_idx ON properties (immutable_unaccent(lower(location)) text_pattern_ops);
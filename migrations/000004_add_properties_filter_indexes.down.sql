@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS properties_price_idx;
+DROP INDEX IF EXISTS properties_amenities_idx;
+DROP INDEX IF EXISTS properties_category_idx;
+DROP INDEX IF EXISTS properties_type_idx;
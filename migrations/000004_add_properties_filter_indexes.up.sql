@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS properties_type_idx ON properties USING GIN (type);
+CREATE INDEX IF NOT EXISTS properties_category_idx ON properties USING GIN (category);
+CREATE INDEX IF NOT EXISTS properties_amenities_idx ON properties USING GIN (amenities);
+CREATE INDEX IF NOT EXISTS properties_price_idx ON properties (price);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS properties_status_updated_at_idx;
+ALTER TABLE properties DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE properties DROP COLUMN IF EXISTS status;
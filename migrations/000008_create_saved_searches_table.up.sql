@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    name text NOT NULL,
+    query text NOT NULL,
+    filters JSONB NOT NULL,
+    frequency text NOT NULL,
+    last_matched_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_notified_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT saved_searches_frequency_check CHECK (frequency IN ('instant', 'daily', 'weekly'))
+);
+
+CREATE INDEX IF NOT EXISTS saved_searches_user_id_idx ON saved_searches (user_id);
+
+CREATE TABLE IF NOT EXISTS saved_search_matches (
+    saved_search_id bigint NOT NULL REFERENCES saved_searches ON DELETE CASCADE,
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    matched_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    notified_at timestamp(0) with time zone,
+    PRIMARY KEY (saved_search_id, property_id)
+);
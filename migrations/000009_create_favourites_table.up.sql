@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS favourites (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, property_id)
+);
+
+CREATE INDEX IF NOT EXISTS favourites_property_id_idx ON favourites (property_id);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS properties_record_event ON properties;
+DROP FUNCTION IF EXISTS record_property_event();
+DROP TABLE IF EXISTS property_events;
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS property_events (
+    id bigserial PRIMARY KEY,
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    kind text NOT NULL,
+    old_price numeric,
+    new_price numeric,
+    old_status text,
+    new_status text
+);
+
+CREATE INDEX IF NOT EXISTS property_events_property_id_idx ON property_events (property_id, kind);
+
+-- Record listing, price change and status change events whichever code path writes to properties.
+CREATE OR REPLACE FUNCTION record_property_event() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO property_events (property_id, kind, new_price, new_status)
+        VALUES (NEW.id, 'listed', NEW.price, NEW.status);
+        RETURN NEW;
+    END IF;
+
+    IF NEW.price IS DISTINCT FROM OLD.price THEN
+        INSERT INTO property_events (property_id, kind, old_price, new_price)
+        VALUES (NEW.id, 'price_change', OLD.price, NEW.price);
+    END IF;
+
+    IF NEW.status IS DISTINCT FROM OLD.status THEN
+        INSERT INTO property_events (property_id, kind, old_status, new_status)
+        VALUES (NEW.id, 'status_change', OLD.status, NEW.status);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER properties_record_event
+AFTER INSERT OR UPDATE OF price, status ON properties
+FOR EACH ROW EXECUTE FUNCTION record_property_event();
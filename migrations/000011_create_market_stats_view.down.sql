@@ -0,0 +1 @@
+DROP MATERIALIZED VIEW IF EXISTS market_stats;
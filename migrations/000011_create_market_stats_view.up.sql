@@ -0,0 +1,57 @@
+-- Market statistics per period, city, neighbourhood, property type and currency. City and neighbourhood
+-- names are normalized to title case so that differently cased spellings are counted together. Rows with an empty
+-- location or type aggregate every neighbourhood or type of the city.
+CREATE MATERIALIZED VIEW IF NOT EXISTS market_stats AS
+WITH listings AS (
+    SELECT
+        initcap(lower(properties.city)) AS city,
+        initcap(lower(properties.location)) AS location,
+        coalesce(properties.type[1], 'unknown') AS type,
+        coalesce(properties.currency[1], 'unknown') AS currency,
+        properties.created_at,
+        properties.price,
+        NULLIF(substring(coalesce(properties.features->>'size', properties.features->>'area', properties.features->>'floor_area') FROM '^[0-9]+(?:\.[0-9]+)?'), '')::numeric AS size,
+        sold.sold_at,
+        coalesce(reductions.reduced, false) AS reduced
+    FROM properties
+    LEFT JOIN LATERAL (
+        SELECT min(created_at) AS sold_at
+        FROM property_events
+        WHERE property_events.property_id = properties.id
+        AND property_events.kind = 'status_change'
+        AND property_events.new_status IN ('sold', 'let')
+    ) sold ON true
+    LEFT JOIN LATERAL (
+        SELECT bool_or(new_price < old_price) AS reduced
+        FROM property_events
+        WHERE property_events.property_id = properties.id
+        AND property_events.kind = 'price_change'
+    ) reductions ON true
+), periods AS (
+    SELECT 'weekly' AS period, date_trunc('week', created_at) AS period_start, * FROM listings
+    UNION ALL
+    SELECT 'monthly' AS period, date_trunc('month', created_at) AS period_start, * FROM listings
+)
+SELECT
+    period,
+    period_start,
+    city,
+    coalesce(location, '') AS location,
+    coalesce(type, '') AS type,
+    currency,
+    count(*) AS new_listings,
+    percentile_cont(0.5) WITHIN GROUP (ORDER BY price) AS median_price,
+    avg(price)::float8 AS mean_price,
+    percentile_cont(0.5) WITHIN GROUP (ORDER BY (price / size)::float8) FILTER (WHERE size > 0) AS median_price_per_sqm,
+    avg(extract(epoch FROM coalesce(sold_at, NOW()) - created_at) / 86400)::float8 AS avg_days_on_market,
+    avg(CASE WHEN reduced THEN 1 ELSE 0 END)::float8 AS price_reduction_rate
+FROM periods
+GROUP BY GROUPING SETS (
+    (period, period_start, city, currency, location, type),
+    (period, period_start, city, currency, location),
+    (period, period_start, city, currency, type),
+    (period, period_start, city, currency)
+);
+
+-- A unique index on plain columns is required to refresh the view concurrently.
+CREATE UNIQUE INDEX IF NOT EXISTS market_stats_key_idx ON market_stats (period, city, location, type, currency, period_start);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS properties_user_id_idx;
+ALTER TABLE properties DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,2 @@
+ALTER TABLE properties ADD COLUMN IF NOT EXISTS user_id bigint REFERENCES users ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS properties_user_id_idx ON properties (user_id);
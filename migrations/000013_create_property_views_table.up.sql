@@ -0,0 +1,10 @@
+-- Detail views are counted once per visitor and day, so the number of rows for a day is the number of unique visitors.
+CREATE TABLE IF NOT EXISTS property_views (
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    visitor text NOT NULL,
+    day date NOT NULL,
+    views integer NOT NULL DEFAULT 1,
+    PRIMARY KEY (property_id, day, visitor)
+);
+
+CREATE INDEX IF NOT EXISTS property_views_day_idx ON property_views (day);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS inquiries (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    owner_id bigint REFERENCES users ON DELETE SET NULL,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    name text NOT NULL,
+    email citext NOT NULL,
+    phone text NOT NULL DEFAULT '',
+    message text NOT NULL,
+    contacted_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS inquiries_owner_id_idx ON inquiries (owner_id, created_at);
+CREATE INDEX IF NOT EXISTS inquiries_property_id_idx ON inquiries (property_id);
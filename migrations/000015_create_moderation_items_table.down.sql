@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS inquiries_email_idx;
+ALTER TABLE inquiries DROP COLUMN IF EXISTS held;
+DROP TABLE IF EXISTS moderation_items;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS moderation_items (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    item_type text NOT NULL CHECK (item_type IN ('property', 'inquiry')),
+    item_id bigint NOT NULL,
+    score double precision NOT NULL,
+    reasons text[] NOT NULL DEFAULT '{}',
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS moderation_items_status_idx ON moderation_items (status, created_at);
+CREATE INDEX IF NOT EXISTS moderation_items_item_idx ON moderation_items (item_type, item_id);
+
+ALTER TABLE inquiries ADD COLUMN IF NOT EXISTS held boolean NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS inquiries_email_idx ON inquiries (email, created_at);
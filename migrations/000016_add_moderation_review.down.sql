@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS audit_log;
+DROP INDEX IF EXISTS moderation_items_pending_item_idx;
+ALTER TABLE moderation_items DROP COLUMN IF EXISTS decided_at;
+ALTER TABLE moderation_items DROP COLUMN IF EXISTS decision_reason;
+ALTER TABLE moderation_items DROP COLUMN IF EXISTS moderator_id;
+DROP TABLE IF EXISTS users_permissions;
+DROP TABLE IF EXISTS permissions;
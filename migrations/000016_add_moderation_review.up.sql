@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS permissions (
+    id bigserial PRIMARY KEY,
+    code text NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS users_permissions (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    permission_id bigint NOT NULL REFERENCES permissions ON DELETE CASCADE,
+    PRIMARY KEY (user_id, permission_id)
+);
+
+INSERT INTO permissions (code)
+VALUES ('moderation:review')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE moderation_items ADD COLUMN IF NOT EXISTS moderator_id bigint REFERENCES users ON DELETE SET NULL;
+ALTER TABLE moderation_items ADD COLUMN IF NOT EXISTS decision_reason text NOT NULL DEFAULT '';
+ALTER TABLE moderation_items ADD COLUMN IF NOT EXISTS decided_at timestamp(0) with time zone;
+
+CREATE UNIQUE INDEX IF NOT EXISTS moderation_items_pending_item_idx ON moderation_items (item_type, item_id) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    actor_id bigint REFERENCES users ON DELETE SET NULL,
+    action text NOT NULL,
+    entity_type text NOT NULL,
+    entity_id bigint NOT NULL,
+    details jsonb NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity_type, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS listing_reports (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    reporter text NOT NULL,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    reason text NOT NULL CHECK (reason IN ('scam', 'sold', 'let', 'inaccurate', 'duplicate', 'offensive', 'other')),
+    comment text NOT NULL DEFAULT '',
+    UNIQUE (property_id, reporter)
+);
+
+CREATE INDEX IF NOT EXISTS listing_reports_created_at_idx ON listing_reports (created_at);
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS conversations (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    buyer_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    agent_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    last_message_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (property_id, buyer_id),
+    CHECK (buyer_id <> agent_id)
+);
+
+CREATE INDEX IF NOT EXISTS conversations_buyer_id_idx ON conversations (buyer_id, last_message_at);
+CREATE INDEX IF NOT EXISTS conversations_agent_id_idx ON conversations (agent_id, last_message_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(6) with time zone NOT NULL DEFAULT NOW(),
+    conversation_id bigint NOT NULL REFERENCES conversations ON DELETE CASCADE,
+    sender_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    body text NOT NULL,
+    read_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS messages_conversation_id_idx ON messages (conversation_id, created_at);
+CREATE INDEX IF NOT EXISTS messages_unread_idx ON messages (conversation_id, sender_id) WHERE read_at IS NULL;
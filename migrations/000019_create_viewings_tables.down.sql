@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS viewings;
+DROP TABLE IF EXISTS availability_blackouts;
+DROP TABLE IF EXISTS availability_rules;
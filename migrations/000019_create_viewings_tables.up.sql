@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS availability_rules (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    agent_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    weekday smallint NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_time time NOT NULL,
+    end_time time NOT NULL,
+    time_zone text NOT NULL,
+    slot_minutes integer NOT NULL DEFAULT 30 CHECK (slot_minutes > 0),
+    version integer NOT NULL DEFAULT 1,
+    CHECK (start_time < end_time)
+);
+
+CREATE INDEX IF NOT EXISTS availability_rules_agent_id_idx ON availability_rules (agent_id);
+
+CREATE TABLE IF NOT EXISTS availability_blackouts (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    agent_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    date date NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    UNIQUE (agent_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS viewings (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    agent_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    buyer_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    starts_at timestamp(0) with time zone NOT NULL,
+    ends_at timestamp(0) with time zone NOT NULL,
+    time_zone text NOT NULL,
+    status text NOT NULL DEFAULT 'confirmed' CHECK (status IN ('confirmed', 'cancelled')),
+    note text NOT NULL DEFAULT '',
+    cancelled_at timestamp(0) with time zone,
+    cancelled_by bigint REFERENCES users ON DELETE SET NULL,
+    version integer NOT NULL DEFAULT 1,
+    CHECK (starts_at < ends_at)
+);
+
+CREATE INDEX IF NOT EXISTS viewings_agent_id_idx ON viewings (agent_id, starts_at) WHERE status = 'confirmed';
+CREATE INDEX IF NOT EXISTS viewings_buyer_id_idx ON viewings (buyer_id, starts_at);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS open_houses (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    starts_at timestamp(0) with time zone NOT NULL,
+    ends_at timestamp(0) with time zone NOT NULL,
+    time_zone text NOT NULL,
+    capacity integer NOT NULL CHECK (capacity > 0),
+    status text NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'cancelled')),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    CHECK (starts_at < ends_at)
+);
+
+CREATE INDEX IF NOT EXISTS open_houses_property_id_idx ON open_houses (property_id, starts_at);
+CREATE INDEX IF NOT EXISTS open_houses_starts_at_idx ON open_houses (starts_at) WHERE status = 'scheduled';
+
+CREATE TABLE IF NOT EXISTS open_house_rsvps (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(6) with time zone NOT NULL DEFAULT NOW(),
+    open_house_id bigint NOT NULL REFERENCES open_houses ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    guests integer NOT NULL DEFAULT 1 CHECK (guests > 0),
+    status text NOT NULL CHECK (status IN ('confirmed', 'waitlisted', 'cancelled')),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (open_house_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS open_house_rsvps_user_id_idx ON open_house_rsvps (user_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS rental_blocks;
+DROP TABLE IF EXISTS rental_seasons;
+DROP TABLE IF EXISTS rental_rates;
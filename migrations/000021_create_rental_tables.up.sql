@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS rental_rates (
+    property_id bigint PRIMARY KEY REFERENCES properties ON DELETE CASCADE,
+    nightly_rate numeric(12, 2) NOT NULL CHECK (nightly_rate > 0),
+    min_nights integer NOT NULL DEFAULT 1 CHECK (min_nights > 0),
+    max_guests integer NOT NULL CHECK (max_guests > 0),
+    included_guests integer NOT NULL DEFAULT 1 CHECK (included_guests > 0),
+    extra_guest_fee numeric(12, 2) NOT NULL DEFAULT 0 CHECK (extra_guest_fee >= 0),
+    cleaning_fee numeric(12, 2) NOT NULL DEFAULT 0 CHECK (cleaning_fee >= 0),
+    service_fee_percent numeric(5, 2) NOT NULL DEFAULT 0 CHECK (service_fee_percent BETWEEN 0 AND 100),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS rental_seasons (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    name text NOT NULL DEFAULT '',
+    start_date date NOT NULL,
+    end_date date NOT NULL,
+    nightly_rate numeric(12, 2) NOT NULL CHECK (nightly_rate > 0),
+    min_nights integer NOT NULL DEFAULT 0 CHECK (min_nights >= 0),
+    CHECK (start_date <= end_date)
+);
+
+CREATE INDEX IF NOT EXISTS rental_seasons_property_id_idx ON rental_seasons (property_id, start_date);
+
+CREATE TABLE IF NOT EXISTS rental_blocks (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    start_date date NOT NULL,
+    end_date date NOT NULL,
+    kind text NOT NULL CHECK (kind IN ('blocked', 'booked')),
+    note text NOT NULL DEFAULT '',
+    CHECK (start_date < end_date)
+);
+
+CREATE INDEX IF NOT EXISTS rental_blocks_property_id_idx ON rental_blocks (property_id, start_date, end_date);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS rental_blocks_feed_id_uid_idx;
+ALTER TABLE rental_blocks DROP COLUMN IF EXISTS uid;
+ALTER TABLE rental_blocks DROP COLUMN IF EXISTS feed_id;
+DROP TABLE IF EXISTS calendar_feeds;
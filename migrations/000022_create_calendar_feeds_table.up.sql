@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    name text NOT NULL DEFAULT '',
+    url text NOT NULL,
+    etag text NOT NULL DEFAULT '',
+    last_modified text NOT NULL DEFAULT '',
+    last_synced_at timestamp(0) with time zone,
+    last_error text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1,
+    UNIQUE (property_id, url)
+);
+
+CREATE INDEX IF NOT EXISTS calendar_feeds_last_synced_at_idx ON calendar_feeds (last_synced_at NULLS FIRST);
+
+ALTER TABLE rental_blocks ADD COLUMN IF NOT EXISTS feed_id bigint REFERENCES calendar_feeds ON DELETE CASCADE;
+ALTER TABLE rental_blocks ADD COLUMN IF NOT EXISTS uid text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS rental_blocks_feed_id_uid_idx ON rental_blocks (feed_id, uid) WHERE feed_id IS NOT NULL;
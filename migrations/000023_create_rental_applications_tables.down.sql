@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS rental_application_documents;
+DROP TABLE IF EXISTS rental_applications;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS rental_applications (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    applicant_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    employment_status text NOT NULL CHECK (employment_status IN ('employed', 'self_employed', 'student', 'retired', 'unemployed')),
+    employer text NOT NULL DEFAULT '',
+    job_title text NOT NULL DEFAULT '',
+    annual_income numeric(14, 2) NOT NULL DEFAULT 0 CHECK (annual_income >= 0),
+    move_in_date date NOT NULL,
+    occupants integer NOT NULL DEFAULT 1 CHECK (occupants > 0),
+    message text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT 'submitted' CHECK (status IN ('submitted', 'shortlisted', 'approved', 'declined', 'withdrawn')),
+    decision_reason text NOT NULL DEFAULT '',
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS rental_applications_active_idx ON rental_applications (property_id, applicant_id)
+    WHERE status IN ('submitted', 'shortlisted', 'approved');
+CREATE INDEX IF NOT EXISTS rental_applications_applicant_id_idx ON rental_applications (applicant_id);
+
+CREATE TABLE IF NOT EXISTS rental_application_documents (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    application_id bigint NOT NULL REFERENCES rental_applications ON DELETE CASCADE,
+    kind text NOT NULL CHECK (kind IN ('payslip', 'id', 'bank_statement', 'reference', 'other')),
+    filename text NOT NULL,
+    content_type text NOT NULL,
+    size bigint NOT NULL,
+    storage_key text NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS rental_application_documents_application_id_idx ON rental_application_documents (application_id);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS offers (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    buyer_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    made_by bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    root_id bigint REFERENCES offers ON DELETE CASCADE,
+    parent_id bigint REFERENCES offers ON DELETE CASCADE,
+    amount numeric(14, 2) NOT NULL CHECK (amount > 0),
+    conditions text[] NOT NULL DEFAULT '{}',
+    expires_at timestamp(0) with time zone NOT NULL,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected', 'countered', 'withdrawn', 'declined', 'expired')),
+    responded_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS offers_pending_idx ON offers (property_id, buyer_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS offers_root_id_idx ON offers (root_id);
+CREATE INDEX IF NOT EXISTS offers_buyer_id_idx ON offers (buyer_id);
+CREATE INDEX IF NOT EXISTS offers_expires_at_idx ON offers (expires_at) WHERE status = 'pending';
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS auctions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    property_id bigint NOT NULL REFERENCES properties ON DELETE CASCADE,
+    starts_at timestamp(0) with time zone NOT NULL,
+    ends_at timestamp(0) with time zone NOT NULL,
+    scheduled_ends_at timestamp(0) with time zone NOT NULL,
+    starting_price numeric(14, 2) NOT NULL CHECK (starting_price > 0),
+    reserve_price numeric(14, 2) NOT NULL DEFAULT 0 CHECK (reserve_price >= 0),
+    min_increment numeric(14, 2) NOT NULL CHECK (min_increment > 0),
+    extension_seconds integer NOT NULL DEFAULT 0 CHECK (extension_seconds >= 0),
+    current_bid numeric(14, 2),
+    current_bidder_id bigint REFERENCES users ON DELETE SET NULL,
+    bid_count integer NOT NULL DEFAULT 0,
+    status text NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'closed', 'cancelled')),
+    winner_id bigint REFERENCES users ON DELETE SET NULL,
+    closed_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1,
+    CHECK (ends_at > starts_at)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS auctions_scheduled_idx ON auctions (property_id) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS auctions_ends_at_idx ON auctions (ends_at) WHERE status = 'scheduled';
+
+CREATE TABLE IF NOT EXISTS auction_bids (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(3) with time zone NOT NULL DEFAULT NOW(),
+    auction_id bigint NOT NULL REFERENCES auctions ON DELETE CASCADE,
+    bidder_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    amount numeric(14, 2) NOT NULL CHECK (amount > 0)
+);
+
+CREATE INDEX IF NOT EXISTS auction_bids_auction_id_idx ON auction_bids (auction_id, amount DESC);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS agency_invitations;
+DROP TABLE IF EXISTS agent_profiles;
+DROP TABLE IF EXISTS agencies;
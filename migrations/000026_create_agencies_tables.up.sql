@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS agencies (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    logo_url text NOT NULL DEFAULT '',
+    phone text NOT NULL DEFAULT '',
+    email citext NOT NULL DEFAULT '',
+    website text NOT NULL DEFAULT '',
+    city text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS agent_profiles (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    bio text NOT NULL DEFAULT '',
+    photo_url text NOT NULL DEFAULT '',
+    licence_number text NOT NULL DEFAULT '',
+    phone text NOT NULL DEFAULT '',
+    service_areas text[] NOT NULL DEFAULT '{}',
+    agency_id bigint REFERENCES agencies ON DELETE SET NULL,
+    agency_role text CHECK (agency_role IN ('admin', 'agent')),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    CHECK ((agency_id IS NULL) = (agency_role IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS agent_profiles_agency_id_idx ON agent_profiles (agency_id);
+
+CREATE TABLE IF NOT EXISTS agency_invitations (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    agency_id bigint NOT NULL REFERENCES agencies ON DELETE CASCADE,
+    email citext NOT NULL,
+    invited_by bigint REFERENCES users ON DELETE SET NULL,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'revoked')),
+    responded_at timestamp(0) with time zone
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS agency_invitations_pending_idx ON agency_invitations (agency_id, email) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS agency_invitations_email_idx ON agency_invitations (email) WHERE status = 'pending';
@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code = 'agents:verify';
+DROP TABLE IF EXISTS licence_verification_documents;
+DROP TABLE IF EXISTS licence_verifications;
+ALTER TABLE agent_profiles DROP COLUMN IF EXISTS verified_at;
+ALTER TABLE agent_profiles DROP COLUMN IF EXISTS licence_authority;
@@ -0,0 +1,34 @@
+ALTER TABLE agent_profiles ADD COLUMN IF NOT EXISTS licence_authority text NOT NULL DEFAULT '';
+ALTER TABLE agent_profiles ADD COLUMN IF NOT EXISTS verified_at timestamp(0) with time zone;
+
+CREATE TABLE IF NOT EXISTS licence_verifications (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    licence_number text NOT NULL,
+    authority text NOT NULL,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reviewer_id bigint REFERENCES users ON DELETE SET NULL,
+    decision_reason text NOT NULL DEFAULT '',
+    reviewed_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS licence_verifications_pending_idx ON licence_verifications (user_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS licence_verifications_status_idx ON licence_verifications (status, created_at);
+
+CREATE TABLE IF NOT EXISTS licence_verification_documents (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    verification_id bigint NOT NULL REFERENCES licence_verifications ON DELETE CASCADE,
+    filename text NOT NULL,
+    content_type text NOT NULL,
+    size bigint NOT NULL,
+    storage_key text NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS licence_verification_documents_verification_id_idx ON licence_verification_documents (verification_id);
+
+INSERT INTO permissions (code)
+VALUES ('agents:verify')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS listing_reports_unresolved_idx;
+ALTER TABLE listing_reports DROP COLUMN IF EXISTS resolved_at;
@@ -0,0 +1,3 @@
+ALTER TABLE listing_reports ADD COLUMN IF NOT EXISTS resolved_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS listing_reports_unresolved_idx ON listing_reports (property_id) WHERE resolved_at IS NULL;
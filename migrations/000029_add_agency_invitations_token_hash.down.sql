@@ -0,0 +1 @@
+ALTER TABLE agency_invitations DROP COLUMN IF EXISTS token_hash;
@@ -0,0 +1 @@
+ALTER TABLE agency_invitations ADD COLUMN IF NOT EXISTS token_hash bytea;
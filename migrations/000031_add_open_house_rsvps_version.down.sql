@@ -0,0 +1 @@
+ALTER TABLE open_house_rsvps DROP COLUMN IF EXISTS version;
@@ -0,0 +1 @@
+ALTER TABLE open_house_rsvps ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- Listings created before properties had owners can only be changed by users with this permission.
+INSERT INTO permissions (code)
+VALUES ('properties:manage')
+ON CONFLICT DO NOTHING;